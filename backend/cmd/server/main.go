@@ -1,19 +1,28 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 	"github.com/joho/godotenv"
 
+	"github.com/temanbatin/omnichannel/internal/auth"
 	"github.com/temanbatin/omnichannel/internal/config"
 	"github.com/temanbatin/omnichannel/internal/controllers"
+	"github.com/temanbatin/omnichannel/internal/realtime"
 	"github.com/temanbatin/omnichannel/internal/repositories"
 	"github.com/temanbatin/omnichannel/internal/services"
+	"github.com/temanbatin/omnichannel/internal/storage"
+	"github.com/temanbatin/omnichannel/internal/types"
 )
 
 func main() {
@@ -36,18 +45,83 @@ func main() {
 	messageRepo := repositories.NewMessageRepository(db)
 	contactRepo := repositories.NewContactRepository(db)
 	conversationRepo := repositories.NewConversationRepository(db)
+	webhookInboxRepo := repositories.NewWebhookInboxRepository(db)
+	userRepo := repositories.NewUserRepository(db)
+	refreshTokenRepo := repositories.NewRefreshTokenRepository(db)
+	teamRepo := repositories.NewTeamRepository(db)
+	eventDeliveryRepo := repositories.NewEventDeliveryRepository(db)
+	webhookSubscriptionRepo := repositories.NewWebhookSubscriptionRepository(db)
+	assignmentRepo := repositories.NewConversationAssignmentRepository(db)
+	routingRuleRepo := repositories.NewRoutingRuleRepository(db)
+	conversationReadRepo := repositories.NewConversationReadRepository(db)
+	templateRepo := repositories.NewTemplateRepository(db)
+	broadcastRepo := repositories.NewBroadcastRepository(db)
+
+	// Initialize auth
+	if cfg.JWTSecret == "" {
+		log.Fatal("JWT_SECRET must be set")
+	}
+	tokens := auth.NewTokenManager(cfg.JWTSecret, cfg.AccessTokenTTL)
+
+	proxyHops := 0
+	if cfg.TrustProxyHeaders {
+		proxyHops = cfg.TrustedProxyHops
+	}
+	internalVerifier, err := auth.NewInternalWebhookVerifier(
+		cfg.InternalWebhookSecret,
+		cfg.InternalWebhookAPIKey,
+		cfg.InternalWebhookAllowedIPs,
+		cfg.InternalWebhookMaxSkew,
+		proxyHops,
+	)
+	if err != nil {
+		log.Fatalf("Invalid internal webhook config: %v", err)
+	}
+	if !internalVerifier.Configured() {
+		log.Println("INTERNAL_WEBHOOK_SECRET / INTERNAL_WEBHOOK_API_KEY not set, /internal/whatsapp will reject all requests")
+	}
+
+	// Initialize media storage
+	blobStore := storage.NewLocalStore(cfg.MediaStorageDir)
 
 	// Initialize services
-	messagingSvc := services.NewMessagingService(messageRepo, contactRepo, conversationRepo, cfg)
+	eventDispatcher := services.NewEventDispatcher(eventDeliveryRepo, webhookSubscriptionRepo, cfg)
+	realtimeBroker := realtime.NewBroker(db)
+	assignmentSvc := services.NewAssignmentService(conversationRepo, assignmentRepo, userRepo, teamRepo, realtimeBroker)
+	routingSvc := services.NewRoutingService(routingRuleRepo, userRepo, teamRepo, conversationRepo, assignmentSvc, cfg)
+	messagingSvc := services.NewMessagingService(db, messageRepo, contactRepo, conversationRepo, conversationReadRepo, templateRepo, broadcastRepo, blobStore, eventDispatcher, realtimeBroker, routingSvc, cfg)
+	webhookProcessor := services.NewWebhookProcessor(webhookInboxRepo, messagingSvc, cfg.WebhookWorkers, cfg.WebhookMaxAttempts)
+	authSvc := services.NewAuthService(userRepo, refreshTokenRepo, tokens, cfg)
+	userSvc := services.NewUserService(userRepo, teamRepo, refreshTokenRepo)
+	webhookSubscriptionSvc := services.NewWebhookSubscriptionService(webhookSubscriptionRepo, eventDeliveryRepo)
+	templateSvc := services.NewTemplateService(templateRepo, cfg)
+	broadcastSvc := services.NewBroadcastService(broadcastRepo, templateRepo, contactRepo, messagingSvc, eventDispatcher, cfg)
+
+	if created, err := authSvc.EnsureBootstrapAdmin(context.Background()); err != nil {
+		log.Fatalf("Failed to create bootstrap admin: %v", err)
+	} else if created {
+		log.Printf("Created bootstrap admin %s", cfg.BootstrapAdminEmail)
+	}
 
 	// Initialize controllers
 	messageCtrl := controllers.NewMessageController(messagingSvc)
-	webhookCtrl := controllers.NewWebhookController(messagingSvc, cfg)
+	webhookCtrl := controllers.NewWebhookController(webhookProcessor, internalVerifier, cfg)
+	mediaCtrl := controllers.NewMediaController(messagingSvc, blobStore)
+	webhookAdminCtrl := controllers.NewWebhookAdminController(webhookProcessor, eventDispatcher)
+	authCtrl := controllers.NewAuthController(authSvc)
+	userCtrl := controllers.NewUserController(userSvc)
+	webhookSubscriptionCtrl := controllers.NewWebhookSubscriptionController(webhookSubscriptionSvc)
+	realtimeCtrl := controllers.NewRealtimeController(messagingSvc)
+	assignmentCtrl := controllers.NewAssignmentController(assignmentSvc)
+	routingCtrl := controllers.NewRoutingController(routingSvc)
+	templateCtrl := controllers.NewTemplateController(templateSvc)
+	broadcastCtrl := controllers.NewBroadcastController(broadcastSvc)
 
 	// Setup router
 	r := chi.NewRouter()
 
 	// Middleware
+	r.Use(auth.StripQueryToken) // Keeps stream tokens out of the request log
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.RequestID)
@@ -65,23 +139,114 @@ func main() {
 
 	// API routes
 	r.Route("/api", func(r chi.Router) {
-		r.Route("/messages", func(r chi.Router) {
-			r.Get("/", messageCtrl.List)
-			r.Post("/", messageCtrl.Send)
-			r.Get("/{id}", messageCtrl.Get)
+		r.Route("/auth", func(r chi.Router) {
+			r.Post("/login", authCtrl.Login)
+			r.Post("/refresh", authCtrl.Refresh)
+			r.Post("/logout", authCtrl.Logout)
+			r.With(auth.Middleware(tokens)).Get("/me", authCtrl.Me)
 		})
 
-		r.Route("/conversations", func(r chi.Router) {
-			r.Get("/", messageCtrl.ListConversations)
-			r.Get("/{id}", messageCtrl.GetConversation)
-		})
+		// Server-Sent Events; EventSource cannot send headers, so the token may be a query parameter
+		r.With(auth.QueryToken, auth.Middleware(tokens)).Get("/stream", realtimeCtrl.Stream)
+
+		// Everything else requires a valid access token
+		r.Group(func(r chi.Router) {
+			r.Use(auth.Middleware(tokens))
+
+			r.Route("/messages", func(r chi.Router) {
+				r.Get("/", messageCtrl.List)
+				r.Post("/", messageCtrl.Send)
+				r.Get("/{id}", messageCtrl.Get)
+			})
+
+			r.Route("/conversations", func(r chi.Router) {
+				r.Get("/", messageCtrl.ListConversations)
+				r.Get("/{id}", messageCtrl.GetConversation)
+				r.Get("/{id}/messages", messageCtrl.ListMessages)
+				r.Post("/{id}/typing", messageCtrl.Typing)
+				r.Post("/{id}/read", messageCtrl.MarkRead)
+				r.Post("/{id}/template", messageCtrl.SendTemplate)
+				r.Post("/{id}/assign", assignmentCtrl.Assign)
+				r.Post("/{id}/unassign", assignmentCtrl.Unassign)
+				r.Post("/{id}/claim", assignmentCtrl.Claim)
+				r.Get("/{id}/assignments", assignmentCtrl.History)
+				r.Put("/{id}/tags", messageCtrl.UpdateTags)
+				r.Put("/{id}/status", messageCtrl.UpdateStatus)
+			})
+
+			r.Route("/contacts", func(r chi.Router) {
+				r.Get("/", messageCtrl.ListContacts)
+				r.Post("/", messageCtrl.CreateContact)
+			})
 
-		r.Route("/contacts", func(r chi.Router) {
-			r.Get("/", messageCtrl.ListContacts)
-			r.Post("/", messageCtrl.CreateContact)
+			r.Post("/media", mediaCtrl.Upload)
+
+			r.Route("/users", func(r chi.Router) {
+				r.Get("/", userCtrl.List)
+				r.Post("/", userCtrl.Create)
+				r.Put("/me/availability", userCtrl.UpdateAvailability)
+				r.Put("/{id}", userCtrl.Update)
+			})
+
+			r.Route("/teams", func(r chi.Router) {
+				r.Get("/", userCtrl.ListTeams)
+				r.Post("/", userCtrl.CreateTeam)
+			})
+
+			r.Route("/admin/webhooks", func(r chi.Router) {
+				r.Use(auth.Require(types.PermManageChannels))
+
+				r.Get("/", webhookAdminCtrl.List)
+				r.Get("/{id}", webhookAdminCtrl.Get)
+				r.Post("/{id}/replay", webhookAdminCtrl.Replay)
+			})
+
+			r.With(auth.Require(types.PermManageChannels)).Get("/admin/event-deliveries", webhookAdminCtrl.ListDeliveries)
+
+			r.Route("/routing-rules", func(r chi.Router) {
+				r.Get("/", routingCtrl.List)
+				r.Post("/", routingCtrl.Create)
+				r.Put("/{id}", routingCtrl.Update)
+				r.Delete("/{id}", routingCtrl.Delete)
+			})
+
+			r.Route("/templates", func(r chi.Router) {
+				r.Get("/", templateCtrl.List)
+				r.Post("/", templateCtrl.Create)
+				r.Post("/sync", templateCtrl.Sync)
+				r.Get("/{id}", templateCtrl.Get)
+				r.Delete("/{id}", templateCtrl.Delete)
+			})
+
+			r.Route("/broadcasts", func(r chi.Router) {
+				r.Get("/", broadcastCtrl.List)
+				r.Post("/", broadcastCtrl.Create)
+				r.Post("/preview", broadcastCtrl.Preview)
+				r.Get("/{id}", broadcastCtrl.Get)
+				r.Get("/{id}/recipients", broadcastCtrl.ListRecipients)
+				r.Post("/{id}/recipients/csv", broadcastCtrl.ImportRecipients)
+				r.Post("/{id}/schedule", broadcastCtrl.Schedule)
+				r.Post("/{id}/pause", broadcastCtrl.Pause)
+				r.Post("/{id}/resume", broadcastCtrl.Resume)
+				r.Post("/{id}/cancel", broadcastCtrl.Cancel)
+			})
+
+			r.Route("/webhook-subscriptions", func(r chi.Router) {
+				r.Use(auth.Require(types.PermManageChannels))
+
+				r.Get("/", webhookSubscriptionCtrl.List)
+				r.Post("/", webhookSubscriptionCtrl.Create)
+				r.Get("/{id}", webhookSubscriptionCtrl.Get)
+				r.Put("/{id}", webhookSubscriptionCtrl.Update)
+				r.Delete("/{id}", webhookSubscriptionCtrl.Delete)
+				r.Get("/{id}/deliveries", webhookSubscriptionCtrl.ListDeliveries)
+			})
 		})
 	})
 
+	// Stored message media, served to holders of a signed URL
+	r.Get("/media/*", mediaCtrl.Serve)
+
 	// Webhook routes (Meta verification)
 	r.Route("/webhooks", func(r chi.Router) {
 		r.Get("/whatsapp", webhookCtrl.VerifyWhatsApp)
@@ -90,17 +255,63 @@ func main() {
 		r.Post("/instagram", webhookCtrl.HandleInstagram)
 	})
 
-	// Internal webhook routes (from n8n, no signature check)
+	// Internal webhook routes (from n8n, HMAC signature or API key)
 	r.Post("/internal/whatsapp", webhookCtrl.HandleWhatsAppInternal)
 
+	// Stop background workers and drain requests on SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Start inbound webhook and outbound event workers, the realtime listener, the snooze waker,
+	// the template sync and the broadcast sender
+	var workers sync.WaitGroup
+	workers.Add(6)
+	go func() {
+		defer workers.Done()
+		webhookProcessor.Run(ctx)
+	}()
+	go func() {
+		defer workers.Done()
+		eventDispatcher.Run(ctx)
+	}()
+	go func() {
+		defer workers.Done()
+		realtimeBroker.Run(ctx)
+	}()
+	go func() {
+		defer workers.Done()
+		messagingSvc.RunSnoozeWaker(ctx)
+	}()
+	go func() {
+		defer workers.Done()
+		templateSvc.RunSync(ctx)
+	}()
+	go func() {
+		defer workers.Done()
+		broadcastSvc.RunWorker(ctx)
+	}()
+
 	// Start server
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
 
-	log.Printf("🚀 Server starting on port %s", port)
-	if err := http.ListenAndServe(":"+port, r); err != nil {
-		log.Fatalf("Server failed: %v", err)
+	srv := &http.Server{Addr: ":" + port, Handler: r}
+	go func() {
+		log.Printf("🚀 Server starting on port %s", port)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Server failed: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("Shutting down...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Server shutdown error: %v", err)
 	}
+	workers.Wait()
 }
@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/temanbatin/omnichannel/internal/types"
+)
+
+type contextKey struct{}
+
+type expiryKey struct{}
+
+type queryTokenKey struct{}
+
+// WithUser returns a copy of ctx carrying the authenticated user
+func WithUser(ctx context.Context, user *types.User) context.Context {
+	return context.WithValue(ctx, contextKey{}, user)
+}
+
+// UserFromContext returns the authenticated user, or nil for unauthenticated contexts
+// such as webhook processing
+func UserFromContext(ctx context.Context) *types.User {
+	user, _ := ctx.Value(contextKey{}).(*types.User)
+	return user
+}
+
+// WithTokenExpiry returns a copy of ctx carrying when the request's access token expires
+func WithTokenExpiry(ctx context.Context, expiresAt time.Time) context.Context {
+	return context.WithValue(ctx, expiryKey{}, expiresAt)
+}
+
+// TokenExpiryFromContext returns when the request's access token expires, or the zero
+// time for unauthenticated contexts
+func TokenExpiryFromContext(ctx context.Context) time.Time {
+	expiresAt, _ := ctx.Value(expiryKey{}).(time.Time)
+	return expiresAt
+}
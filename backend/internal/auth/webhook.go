@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrWebhookNotConfigured = errors.New("no internal webhook secret or API key configured")
+	ErrWebhookUnauthorized  = errors.New("missing or invalid credentials")
+	ErrWebhookIPNotAllowed  = errors.New("source IP not allowed")
+	ErrWebhookStale         = errors.New("timestamp missing or outside the allowed window")
+)
+
+// InternalWebhookVerifier authenticates webhooks posted by our own automations (n8n).
+//
+// A request is accepted with either:
+//   - X-Signature: sha256=<hex HMAC-SHA256 of "<X-Timestamp>.<body>"> plus X-Timestamp (unix seconds)
+//     within maxSkew of now, or
+//   - Authorization: Bearer <api key>
+//
+// A replayed signature older than maxSkew is stale. A fresher replay passes Verify; the caller
+// stores the returned signature with the event and refuses one that was already stored, so a
+// retry of a request that failed before it was stored is still accepted.
+// If allowed networks are configured the client IP must also match one of them.
+type InternalWebhookVerifier struct {
+	secret    []byte
+	apiKey    string
+	allowed   []*net.IPNet
+	maxSkew   time.Duration
+	proxyHops int
+}
+
+// NewInternalWebhookVerifier parses the allow-list (IPs or CIDRs) and builds a verifier.
+// proxyHops is the number of trusted reverse proxies in front of the server; 0 ignores
+// X-Forwarded-For.
+func NewInternalWebhookVerifier(secret, apiKey string, allowedIPs []string, maxSkew time.Duration, proxyHops int) (*InternalWebhookVerifier, error) {
+	v := &InternalWebhookVerifier{
+		secret:    []byte(secret),
+		apiKey:    apiKey,
+		maxSkew:   maxSkew,
+		proxyHops: proxyHops,
+	}
+
+	for _, entry := range allowedIPs {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed IP %q: %w", entry, err)
+		}
+		v.allowed = append(v.allowed, network)
+	}
+
+	return v, nil
+}
+
+// Configured reports whether any credential is set; without one every request is rejected
+func (v *InternalWebhookVerifier) Configured() bool {
+	return len(v.secret) > 0 || v.apiKey != ""
+}
+
+// Verify checks the request's source IP and credentials against the raw body.
+// It returns the accepted signature in hex, or "" for a request authenticated by API key.
+func (v *InternalWebhookVerifier) Verify(r *http.Request, body []byte) (string, error) {
+	if !v.Configured() {
+		return "", ErrWebhookNotConfigured
+	}
+
+	if len(v.allowed) > 0 && !v.ipAllowed(v.ClientIP(r)) {
+		return "", ErrWebhookIPNotAllowed
+	}
+
+	if signature := r.Header.Get("X-Signature"); signature != "" && len(v.secret) > 0 {
+		return v.verifySignature(r.Header.Get("X-Timestamp"), signature, body)
+	}
+
+	if v.apiKey != "" {
+		token := bearerToken(r)
+		if token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(v.apiKey)) == 1 {
+			return "", nil
+		}
+	}
+
+	return "", ErrWebhookUnauthorized
+}
+
+func (v *InternalWebhookVerifier) verifySignature(timestamp, signature string, body []byte) (string, error) {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "", ErrWebhookStale
+	}
+	skew := time.Since(time.Unix(ts, 0))
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > v.maxSkew {
+		return "", ErrWebhookStale
+	}
+
+	mac := hmac.New(sha256.New, v.secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+
+	if !hmac.Equal([]byte(strings.TrimPrefix(signature, "sha256=")), []byte(expected)) {
+		return "", ErrWebhookUnauthorized
+	}
+	return expected, nil
+}
+
+// ClientIP returns the caller's address. Behind proxyHops trusted proxies it is the entry
+// the outermost of them appended to X-Forwarded-For, counting from the right; entries further
+// left were sent by the client and cannot be trusted.
+func (v *InternalWebhookVerifier) ClientIP(r *http.Request) net.IP {
+	if v.proxyHops > 0 {
+		var hops []string
+		for _, header := range r.Header.Values("X-Forwarded-For") {
+			hops = append(hops, strings.Split(header, ",")...)
+		}
+		if len(hops) >= v.proxyHops {
+			return net.ParseIP(strings.TrimSpace(hops[len(hops)-v.proxyHops]))
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+func (v *InternalWebhookVerifier) ipAllowed(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range v.allowed {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "webhook-secret"
+
+func sign(secret, timestamp, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func signedRequest(secret string, at time.Time, body string) *http.Request {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	r := httptest.NewRequest(http.MethodPost, "/internal/whatsapp", strings.NewReader(body))
+	r.Header.Set("X-Timestamp", timestamp)
+	r.Header.Set("X-Signature", sign(secret, timestamp, body))
+	return r
+}
+
+func newVerifier(t *testing.T, apiKey string, allowed []string, proxyHops int) *InternalWebhookVerifier {
+	t.Helper()
+	v, err := NewInternalWebhookVerifier(testSecret, apiKey, allowed, 5*time.Minute, proxyHops)
+	if err != nil {
+		t.Fatalf("NewInternalWebhookVerifier: %v", err)
+	}
+	return v
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := `{"entry":[]}`
+	now := time.Now()
+
+	tests := []struct {
+		name string
+		req  func() *http.Request
+		want error
+	}{
+		{"valid", func() *http.Request { return signedRequest(testSecret, now, body) }, nil},
+		{"within skew", func() *http.Request { return signedRequest(testSecret, now.Add(-4*time.Minute), body) }, nil},
+		{"too old", func() *http.Request { return signedRequest(testSecret, now.Add(-6*time.Minute), body) }, ErrWebhookStale},
+		{"too far ahead", func() *http.Request { return signedRequest(testSecret, now.Add(6*time.Minute), body) }, ErrWebhookStale},
+		{"wrong secret", func() *http.Request { return signedRequest("other", now, body) }, ErrWebhookUnauthorized},
+		{"missing timestamp", func() *http.Request {
+			r := signedRequest(testSecret, now, body)
+			r.Header.Del("X-Timestamp")
+			return r
+		}, ErrWebhookStale},
+		{"no credentials", func() *http.Request {
+			return httptest.NewRequest(http.MethodPost, "/internal/whatsapp", strings.NewReader(body))
+		}, ErrWebhookUnauthorized},
+	}
+	for _, tt := range tests {
+		v := newVerifier(t, "", nil, 0)
+		if _, err := v.Verify(tt.req(), []byte(body)); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestVerifySignatureTamperedBody(t *testing.T) {
+	v := newVerifier(t, "", nil, 0)
+	r := signedRequest(testSecret, time.Now(), `{"entry":[]}`)
+	if _, err := v.Verify(r, []byte(`{"entry":[{}]}`)); !errors.Is(err, ErrWebhookUnauthorized) {
+		t.Errorf("err = %v, want ErrWebhookUnauthorized", err)
+	}
+}
+
+func TestVerifyReturnsSignature(t *testing.T) {
+	v := newVerifier(t, "key-123", nil, 0)
+	body := `{"entry":[]}`
+	now := time.Now()
+
+	first, err := v.Verify(signedRequest(testSecret, now, body), []byte(body))
+	if err != nil {
+		t.Fatalf("first delivery: %v", err)
+	}
+	if want := strings.TrimPrefix(sign(testSecret, strconv.FormatInt(now.Unix(), 10), body), "sha256="); first != want {
+		t.Errorf("signature = %q, want %q", first, want)
+	}
+	// A retry of the same request is accepted here; the inbox refuses to store it twice
+	if again, err := v.Verify(signedRequest(testSecret, now, body), []byte(body)); err != nil || again != first {
+		t.Errorf("retry: signature = %q, err = %v, want %q", again, err, first)
+	}
+	if resigned, err := v.Verify(signedRequest(testSecret, now.Add(time.Second), body), []byte(body)); err != nil || resigned == first {
+		t.Errorf("re-signed retry: signature = %q, err = %v, want a new signature", resigned, err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/internal/whatsapp", nil)
+	r.Header.Set("Authorization", "Bearer key-123")
+	if signature, err := v.Verify(r, nil); err != nil || signature != "" {
+		t.Errorf("API key: signature = %q, err = %v, want none", signature, err)
+	}
+}
+
+func TestVerifyAPIKey(t *testing.T) {
+	tests := []struct {
+		header string
+		want   error
+	}{
+		{"Bearer key-123", nil},
+		{"bearer key-123", nil},
+		{"Bearer key-124", ErrWebhookUnauthorized},
+		{"key-123", ErrWebhookUnauthorized},
+		{"", ErrWebhookUnauthorized},
+	}
+	for _, tt := range tests {
+		v := newVerifier(t, "key-123", nil, 0)
+		r := httptest.NewRequest(http.MethodPost, "/internal/whatsapp", nil)
+		if tt.header != "" {
+			r.Header.Set("Authorization", tt.header)
+		}
+		if _, err := v.Verify(r, nil); !errors.Is(err, tt.want) {
+			t.Errorf("Authorization %q: err = %v, want %v", tt.header, err, tt.want)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name      string
+		proxyHops int
+		forwarded []string
+		want      string
+	}{
+		{"proxy headers ignored", 0, []string{"203.0.113.7"}, "10.0.0.2"},
+		{"one proxy", 1, []string{"203.0.113.7"}, "203.0.113.7"},
+		{"spoofed entry before proxy hop", 1, []string{"198.51.100.1, 203.0.113.7"}, "203.0.113.7"},
+		{"two proxies", 2, []string{"198.51.100.1, 203.0.113.7, 10.0.0.9"}, "203.0.113.7"},
+		{"repeated header", 2, []string{"198.51.100.1", "203.0.113.7, 10.0.0.9"}, "203.0.113.7"},
+		{"fewer hops than proxies", 2, []string{"203.0.113.7"}, "10.0.0.2"},
+		{"no header", 1, nil, "10.0.0.2"},
+	}
+	for _, tt := range tests {
+		v := newVerifier(t, "", nil, tt.proxyHops)
+		r := httptest.NewRequest(http.MethodPost, "/internal/whatsapp", nil)
+		r.RemoteAddr = "10.0.0.2:51234"
+		for _, value := range tt.forwarded {
+			r.Header.Add("X-Forwarded-For", value)
+		}
+		if got := v.ClientIP(r); got.String() != tt.want {
+			t.Errorf("%s: ClientIP = %v, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyAllowList(t *testing.T) {
+	v := newVerifier(t, "key-123", []string{"203.0.113.0/24", "10.0.0.2"}, 1)
+	tests := []struct {
+		forwarded string
+		want      error
+	}{
+		{"203.0.113.7", nil},
+		{"198.51.100.1", ErrWebhookIPNotAllowed},
+		// A client cannot claim an allowed address in front of the proxy's hop
+		{"203.0.113.7, 198.51.100.1", ErrWebhookIPNotAllowed},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/internal/whatsapp", nil)
+		r.RemoteAddr = "10.0.0.2:51234"
+		r.Header.Set("X-Forwarded-For", tt.forwarded)
+		r.Header.Set("Authorization", "Bearer key-123")
+		if _, err := v.Verify(r, nil); !errors.Is(err, tt.want) {
+			t.Errorf("X-Forwarded-For %q: err = %v, want %v", tt.forwarded, err, tt.want)
+		}
+	}
+}
+
+func TestNewInternalWebhookVerifierInvalidIP(t *testing.T) {
+	if _, err := NewInternalWebhookVerifier(testSecret, "", []string{"not-an-ip"}, time.Minute, 0); err == nil {
+		t.Error("expected an error for an invalid allowed IP")
+	}
+}
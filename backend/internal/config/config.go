@@ -1,11 +1,22 @@
 package config
 
-import "os"
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type Config struct {
 	DatabaseURL string
 	Port        string
 
+	// PublicBaseURL is the externally reachable URL of this server, used for media links
+	PublicBaseURL string
+
+	// Media storage
+	MediaStorageDir string
+
 	// Meta API
 	MetaAccessToken    string
 	MetaAppSecret      string
@@ -15,7 +26,43 @@ type Config struct {
 	InstagramAccountID string
 
 	// n8n Integration
-	N8NWebhookURL string
+	N8NWebhookURL    string
+	N8NWebhookSecret string // Signs outbound events (X-Omnichannel-Signature)
+
+	// Outbound event delivery
+	EventWorkers            int
+	EventMaxAttempts        int
+	SubscriptionMaxFailures int // Consecutive failed attempts before a webhook subscription is disabled
+
+	// Internal webhook (/internal/whatsapp) authentication
+	InternalWebhookSecret     string
+	InternalWebhookAPIKey     string
+	InternalWebhookAllowedIPs []string
+	InternalWebhookMaxSkew    time.Duration
+	TrustProxyHeaders         bool
+	TrustedProxyHops          int
+
+	// Dashboard authentication
+	JWTSecret              string
+	AccessTokenTTL         time.Duration
+	RefreshTokenTTL        time.Duration
+	BootstrapAdminEmail    string
+	BootstrapAdminPassword string
+
+	// Inbound webhook queue
+	WebhookWorkers     int
+	WebhookMaxAttempts int
+
+	// Conversation routing
+	RoutingStrategy      string // Used when no routing rule matches; empty disables
+	RoutingMaxConcurrent int    // Default per-agent cap on assigned conversations
+
+	// WhatsApp templates
+	TemplateSyncInterval time.Duration // How often templates are pulled from the business account; 0 disables
+
+	// Broadcasts
+	BroadcastRatePerSecond int // Template messages sent per second across all broadcasts
+	WhatsAppTierLimit      int // Contacts a broadcast may message in a rolling 24 hours (the messaging tier); 0 is unlimited
 }
 
 func Load() *Config {
@@ -23,6 +70,10 @@ func Load() *Config {
 		DatabaseURL: getEnv("DATABASE_URL", "postgres://localhost:5432/omnichannel"),
 		Port:        getEnv("PORT", "8080"),
 
+		PublicBaseURL: os.Getenv("PUBLIC_BASE_URL"),
+
+		MediaStorageDir: getEnv("MEDIA_STORAGE_DIR", "./data/media"),
+
 		MetaAccessToken:    os.Getenv("META_ACCESS_TOKEN"),
 		MetaAppSecret:      os.Getenv("META_APP_SECRET"),
 		MetaVerifyToken:    getEnv("META_VERIFY_TOKEN", "omnichannel_verify_token"),
@@ -30,7 +81,36 @@ func Load() *Config {
 		WhatsAppBusinessID: os.Getenv("WHATSAPP_BUSINESS_ID"),
 		InstagramAccountID: os.Getenv("INSTAGRAM_ACCOUNT_ID"),
 
-		N8NWebhookURL: os.Getenv("N8N_WEBHOOK_URL"),
+		N8NWebhookURL:    os.Getenv("N8N_WEBHOOK_URL"),
+		N8NWebhookSecret: os.Getenv("N8N_WEBHOOK_SECRET"),
+
+		EventWorkers:            getEnvInt("EVENT_WORKERS", 2),
+		EventMaxAttempts:        getEnvInt("EVENT_MAX_ATTEMPTS", 10),
+		SubscriptionMaxFailures: getEnvInt("WEBHOOK_SUBSCRIPTION_MAX_FAILURES", 20),
+
+		InternalWebhookSecret:     os.Getenv("INTERNAL_WEBHOOK_SECRET"),
+		InternalWebhookAPIKey:     os.Getenv("INTERNAL_WEBHOOK_API_KEY"),
+		InternalWebhookAllowedIPs: getEnvList("INTERNAL_WEBHOOK_ALLOWED_IPS"),
+		InternalWebhookMaxSkew:    getEnvDuration("INTERNAL_WEBHOOK_MAX_SKEW", 5*time.Minute),
+		TrustProxyHeaders:         os.Getenv("TRUST_PROXY_HEADERS") == "true",
+		TrustedProxyHops:          getEnvInt("TRUSTED_PROXY_HOPS", 1),
+
+		JWTSecret:              os.Getenv("JWT_SECRET"),
+		AccessTokenTTL:         getEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL:        getEnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+		BootstrapAdminEmail:    os.Getenv("ADMIN_EMAIL"),
+		BootstrapAdminPassword: os.Getenv("ADMIN_PASSWORD"),
+
+		WebhookWorkers:     getEnvInt("WEBHOOK_WORKERS", 4),
+		WebhookMaxAttempts: getEnvInt("WEBHOOK_MAX_ATTEMPTS", 8),
+
+		RoutingStrategy:      os.Getenv("ROUTING_STRATEGY"),
+		RoutingMaxConcurrent: getEnvInt("ROUTING_MAX_CONCURRENT", 10),
+
+		TemplateSyncInterval: getEnvDuration("TEMPLATE_SYNC_INTERVAL", 15*time.Minute),
+
+		BroadcastRatePerSecond: getEnvInt("BROADCAST_RATE_PER_SECOND", 20),
+		WhatsAppTierLimit:      getEnvInt("WHATSAPP_TIER_LIMIT", 1000),
 	}
 }
 
@@ -40,3 +120,31 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+func getEnvInt(key string, fallback int) int {
+	if value := os.Getenv(key); value != "" {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return fallback
+}
+
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
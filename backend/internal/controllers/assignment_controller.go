@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/temanbatin/omnichannel/internal/services"
+	"github.com/temanbatin/omnichannel/internal/types"
+)
+
+type AssignmentController struct {
+	assignmentSvc *services.AssignmentService
+}
+
+func NewAssignmentController(assignmentSvc *services.AssignmentService) *AssignmentController {
+	return &AssignmentController{assignmentSvc: assignmentSvc}
+}
+
+// Assign assigns a conversation to an agent and/or team
+func (c *AssignmentController) Assign(w http.ResponseWriter, r *http.Request) {
+	var req types.AssignConversationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	conv, err := c.assignmentSvc.Assign(r.Context(), chi.URLParam(r, "id"), &req)
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, conv)
+}
+
+// Unassign removes the agent from a conversation
+func (c *AssignmentController) Unassign(w http.ResponseWriter, r *http.Request) {
+	conv, err := c.assignmentSvc.Unassign(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, conv)
+}
+
+// Claim assigns a conversation to the current user
+func (c *AssignmentController) Claim(w http.ResponseWriter, r *http.Request) {
+	conv, err := c.assignmentSvc.Claim(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, conv)
+}
+
+// History returns a conversation's assignment history
+func (c *AssignmentController) History(w http.ResponseWriter, r *http.Request) {
+	history, err := c.assignmentSvc.History(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"assignments": history,
+		"total":       len(history),
+	})
+}
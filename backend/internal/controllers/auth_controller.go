@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/temanbatin/omnichannel/internal/services"
+	"github.com/temanbatin/omnichannel/internal/types"
+)
+
+type AuthController struct {
+	authSvc *services.AuthService
+}
+
+func NewAuthController(authSvc *services.AuthService) *AuthController {
+	return &AuthController{authSvc: authSvc}
+}
+
+// Login exchanges email and password for an access and refresh token
+func (c *AuthController) Login(w http.ResponseWriter, r *http.Request) {
+	var req types.LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Email == "" || req.Password == "" {
+		respondError(w, http.StatusBadRequest, "Email and password are required")
+		return
+	}
+
+	tokens, err := c.authSvc.Login(r.Context(), req.Email, req.Password)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCredentials) {
+			respondError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, tokens)
+}
+
+// Refresh rotates a refresh token and issues a new access token
+func (c *AuthController) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req types.RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		respondError(w, http.StatusBadRequest, "Refresh token is required")
+		return
+	}
+
+	tokens, err := c.authSvc.Refresh(r.Context(), req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRefreshToken) {
+			respondError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, tokens)
+}
+
+// Logout revokes a refresh token
+func (c *AuthController) Logout(w http.ResponseWriter, r *http.Request) {
+	var req types.RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		respondError(w, http.StatusBadRequest, "Refresh token is required")
+		return
+	}
+
+	if err := c.authSvc.Logout(r.Context(), req.RefreshToken); err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Me returns the authenticated user
+func (c *AuthController) Me(w http.ResponseWriter, r *http.Request) {
+	user, err := c.authSvc.CurrentUser(r.Context())
+	if err != nil {
+		respondError(w, http.StatusUnauthorized, "Not authenticated")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, user)
+}
@@ -0,0 +1,195 @@
+package controllers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/temanbatin/omnichannel/internal/services"
+	"github.com/temanbatin/omnichannel/internal/types"
+)
+
+// maxRecipientCSVSize bounds a recipient CSV upload
+const maxRecipientCSVSize = 20 << 20
+
+type BroadcastController struct {
+	broadcastSvc *services.BroadcastService
+}
+
+func NewBroadcastController(broadcastSvc *services.BroadcastService) *BroadcastController {
+	return &BroadcastController{broadcastSvc: broadcastSvc}
+}
+
+// List returns broadcasts, optionally filtered by status
+func (c *BroadcastController) List(w http.ResponseWriter, r *http.Request) {
+	broadcasts, err := c.broadcastSvc.ListBroadcasts(r.Context(), types.BroadcastStatus(r.URL.Query().Get("status")))
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"broadcasts": broadcasts,
+		"total":      len(broadcasts),
+	})
+}
+
+// Get returns a single broadcast with its delivery counters
+func (c *BroadcastController) Get(w http.ResponseWriter, r *http.Request) {
+	broadcast, err := c.broadcastSvc.GetBroadcast(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, broadcast)
+}
+
+// Create creates a draft broadcast from a template and an audience of contacts and/or a tag
+func (c *BroadcastController) Create(w http.ResponseWriter, r *http.Request) {
+	var req types.CreateBroadcastRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.TemplateID == "" {
+		respondError(w, http.StatusBadRequest, "Template ID is required")
+		return
+	}
+
+	broadcast, audience, err := c.broadcastSvc.CreateBroadcast(r.Context(), &req)
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, map[string]interface{}{
+		"broadcast": broadcast,
+		"audience":  audience,
+	})
+}
+
+// Preview renders a broadcast message for a stored or sample contact
+func (c *BroadcastController) Preview(w http.ResponseWriter, r *http.Request) {
+	var req types.PreviewBroadcastRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.BroadcastID == "" && req.TemplateID == "" {
+		respondError(w, http.StatusBadRequest, "Broadcast ID or template ID is required")
+		return
+	}
+
+	preview, err := c.broadcastSvc.PreviewBroadcast(r.Context(), &req)
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, preview)
+}
+
+// ImportRecipients adds recipients from a CSV with phone and optional name columns,
+// sent either as the multipart field "file" or as a text/csv body
+func (c *BroadcastController) ImportRecipients(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRecipientCSVSize)
+
+	var body io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "File is required")
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	result, err := c.broadcastSvc.ImportRecipients(r.Context(), chi.URLParam(r, "id"), body)
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, result)
+}
+
+// ListRecipients returns a page of a broadcast's recipients, optionally filtered by status.
+// Pass next_cursor back as cursor to load the following page.
+func (c *BroadcastController) ListRecipients(w http.ResponseWriter, r *http.Request) {
+	after, err := queryCursor(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, err := c.broadcastSvc.ListRecipients(r.Context(), types.BroadcastRecipientFilter{
+		BroadcastID: chi.URLParam(r, "id"),
+		Status:      types.BroadcastRecipientStatus(r.URL.Query().Get("status")),
+		After:       after,
+		Limit:       queryInt(r, "limit", 100, 500),
+	})
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, page)
+}
+
+// Schedule starts sending a draft broadcast at scheduled_at, or now when it is omitted
+func (c *BroadcastController) Schedule(w http.ResponseWriter, r *http.Request) {
+	var req types.ScheduleBroadcastRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+
+	broadcast, err := c.broadcastSvc.ScheduleBroadcast(r.Context(), chi.URLParam(r, "id"), &req)
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, broadcast)
+}
+
+// Pause stops a scheduled or sending broadcast until it is resumed
+func (c *BroadcastController) Pause(w http.ResponseWriter, r *http.Request) {
+	broadcast, err := c.broadcastSvc.PauseBroadcast(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, broadcast)
+}
+
+// Resume continues a paused broadcast
+func (c *BroadcastController) Resume(w http.ResponseWriter, r *http.Request) {
+	broadcast, err := c.broadcastSvc.ResumeBroadcast(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, broadcast)
+}
+
+// Cancel stops a broadcast for good
+func (c *BroadcastController) Cancel(w http.ResponseWriter, r *http.Request) {
+	broadcast, err := c.broadcastSvc.CancelBroadcast(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, broadcast)
+}
@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/temanbatin/omnichannel/internal/services"
+	"github.com/temanbatin/omnichannel/internal/storage"
+	"github.com/temanbatin/omnichannel/internal/types"
+)
+
+// maxUploadSize matches the largest file WhatsApp accepts (documents)
+const maxUploadSize = 100 << 20
+
+type MediaController struct {
+	messagingSvc *services.MessagingService
+	blobStore    storage.BlobStore
+}
+
+func NewMediaController(messagingSvc *services.MessagingService, blobStore storage.BlobStore) *MediaController {
+	return &MediaController{
+		messagingSvc: messagingSvc,
+		blobStore:    blobStore,
+	}
+}
+
+// Upload accepts a multipart file and prepares it for sending on the given platform
+func (c *MediaController) Upload(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid multipart form or file too large")
+		return
+	}
+
+	platform := types.Platform(r.FormValue("platform"))
+	if platform == "" {
+		respondError(w, http.StatusBadRequest, "Platform is required")
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "File is required")
+		return
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Failed to read file")
+		return
+	}
+
+	mimeType := header.Header.Get("Content-Type")
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		mimeType = http.DetectContentType(content)
+	}
+
+	att, err := c.messagingSvc.UploadMedia(r.Context(), platform, header.Filename, mimeType, content)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, att)
+}
+
+// Serve streams a stored media file to holders of a signed URL. The URL is signed when a
+// message reaches a user allowed to read it, or when Meta needs to fetch an attachment.
+func (c *MediaController) Serve(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "*")
+	query := r.URL.Query()
+	if !c.messagingSvc.VerifyMediaURL(key, query.Get("expires"), query.Get("signature")) {
+		http.NotFound(w, r)
+		return
+	}
+
+	file, err := c.blobStore.Open(r.Context(), key)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) || errors.Is(err, storage.ErrInvalidKey) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, "Failed to open media", http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	if contentType := mime.TypeByExtension(path.Ext(key)); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	io.Copy(w, file)
+}
@@ -2,9 +2,14 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/temanbatin/omnichannel/internal/repositories"
 	"github.com/temanbatin/omnichannel/internal/services"
 	"github.com/temanbatin/omnichannel/internal/types"
 )
@@ -40,70 +45,214 @@ func (c *MessageController) Send(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Content == "" {
-		respondError(w, http.StatusBadRequest, "Content is required")
+	if req.Content == "" && req.Attachment == nil {
+		respondError(w, http.StatusBadRequest, "Content or attachment is required")
 		return
 	}
 
-	if req.Platform == "" {
-		respondError(w, http.StatusBadRequest, "Platform is required")
+	if req.ConversationID == "" && req.Platform == "" {
+		respondError(w, http.StatusBadRequest, "Platform is required without a conversation")
 		return
 	}
 
 	msg, err := c.messagingSvc.SendMessage(r.Context(), &req)
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, err.Error())
+		respondServiceError(w, err)
 		return
 	}
 
 	respondJSON(w, http.StatusOK, msg)
 }
 
-// ListConversations returns all conversations
+// ListConversations returns a page of conversations, optionally filtered by assignee
+// (mine, unassigned or a user ID), team_id, platform, status (comma-separated), tag,
+// unread=true and a since/until range on the last message. Pass next_cursor back as
+// cursor to load the following page.
 func (c *MessageController) ListConversations(w http.ResponseWriter, r *http.Request) {
-	limit := 50
-	offset := 0
+	query := r.URL.Query()
+	filter := types.ConversationFilter{
+		TeamID:     query.Get("team_id"),
+		Platform:   types.Platform(query.Get("platform")),
+		Tag:        strings.ToLower(strings.TrimSpace(query.Get("tag"))),
+		UnreadOnly: query.Get("unread") == "true",
+		Limit:      queryInt(r, "limit", 50, 200),
+	}
+	if filter.Platform != "" && !filter.Platform.Valid() {
+		respondError(w, http.StatusBadRequest, "Unknown platform: "+string(filter.Platform))
+		return
+	}
+	var err error
+	if filter.After, err = queryCursor(r); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if filter.Since, err = queryTime(r, "since"); err != nil {
+		respondError(w, http.StatusBadRequest, "since must be an RFC3339 timestamp")
+		return
+	}
+	if filter.Until, err = queryTime(r, "until"); err != nil {
+		respondError(w, http.StatusBadRequest, "until must be an RFC3339 timestamp")
+		return
+	}
+	if statuses := query.Get("status"); statuses != "" {
+		for _, status := range strings.Split(statuses, ",") {
+			status := types.ConversationStatus(strings.TrimSpace(status))
+			if !status.Valid() {
+				respondError(w, http.StatusBadRequest, "Unknown status: "+string(status))
+				return
+			}
+			filter.Statuses = append(filter.Statuses, status)
+		}
+	}
+	switch assignee := query.Get("assignee"); assignee {
+	case "":
+	case "mine":
+		filter.Mine = true
+	case "unassigned":
+		filter.Unassigned = true
+	default:
+		filter.AssigneeID = assignee
+	}
 
-	conversations, err := c.messagingSvc.ListConversations(r.Context(), limit, offset)
+	page, err := c.messagingSvc.ListConversations(r.Context(), filter)
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, err.Error())
+		respondServiceError(w, err)
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"conversations": conversations,
-		"total":         len(conversations),
-	})
+	respondJSON(w, http.StatusOK, page)
 }
 
-// GetConversation returns a conversation with messages
+// GetConversation returns a conversation with its latest messages
 func (c *MessageController) GetConversation(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
-	conversation, err := c.messagingSvc.GetConversation(r.Context(), id, 50)
+	conversation, err := c.messagingSvc.GetConversation(r.Context(), id, queryInt(r, "limit", 50, 200))
 	if err != nil {
-		respondError(w, http.StatusNotFound, "Conversation not found")
+		respondServiceError(w, err)
 		return
 	}
 
 	respondJSON(w, http.StatusOK, conversation)
 }
 
-// ListContacts returns all contacts
-func (c *MessageController) ListContacts(w http.ResponseWriter, r *http.Request) {
-	limit := 100
-	offset := 0
+// ListMessages returns a page of a conversation's messages, newest first.
+// Pass messages_next_cursor or next_cursor as cursor to load older messages.
+func (c *MessageController) ListMessages(w http.ResponseWriter, r *http.Request) {
+	after, err := queryCursor(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	contacts, err := c.messagingSvc.ListContacts(r.Context(), limit, offset)
+	page, err := c.messagingSvc.ListMessages(r.Context(), types.MessageFilter{
+		ConversationID: chi.URLParam(r, "id"),
+		After:          after,
+		Limit:          queryInt(r, "limit", 50, 200),
+	})
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, err.Error())
+		respondServiceError(w, err)
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"contacts": contacts,
-		"total":    len(contacts),
+	respondJSON(w, http.StatusOK, page)
+}
+
+// Typing broadcasts that the current user is composing a reply in a conversation
+func (c *MessageController) Typing(w http.ResponseWriter, r *http.Request) {
+	if err := c.messagingSvc.Typing(r.Context(), chi.URLParam(r, "id")); err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MarkRead acknowledges a conversation's messages and sends read receipts to the contact
+func (c *MessageController) MarkRead(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.messagingSvc.MarkConversationRead(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// SendTemplate sends an approved WhatsApp template into a conversation
+func (c *MessageController) SendTemplate(w http.ResponseWriter, r *http.Request) {
+	var req types.SendTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.TemplateID == "" {
+		respondError(w, http.StatusBadRequest, "Template ID is required")
+		return
+	}
+
+	msg, err := c.messagingSvc.SendTemplate(r.Context(), chi.URLParam(r, "id"), &req)
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, msg)
+}
+
+// UpdateTags replaces a conversation's tags
+func (c *MessageController) UpdateTags(w http.ResponseWriter, r *http.Request) {
+	var req types.UpdateTagsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	conversation, err := c.messagingSvc.UpdateTags(r.Context(), chi.URLParam(r, "id"), req.Tags)
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, conversation)
+}
+
+// UpdateStatus opens, marks pending, snoozes or resolves a conversation
+func (c *MessageController) UpdateStatus(w http.ResponseWriter, r *http.Request) {
+	var req types.UpdateConversationStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	conversation, err := c.messagingSvc.UpdateConversationStatus(r.Context(), chi.URLParam(r, "id"), &req)
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, conversation)
+}
+
+// ListContacts returns a page of contacts, newest first
+func (c *MessageController) ListContacts(w http.ResponseWriter, r *http.Request) {
+	after, err := queryCursor(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, err := c.messagingSvc.ListContacts(r.Context(), types.ContactFilter{
+		After: after,
+		Limit: queryInt(r, "limit", 100, 200),
 	})
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, page)
 }
 
 // CreateContact creates a new contact
@@ -120,7 +269,7 @@ func (c *MessageController) CreateContact(w http.ResponseWriter, r *http.Request
 	}
 
 	if err := c.messagingSvc.CreateContact(r.Context(), &contact); err != nil {
-		respondError(w, http.StatusInternalServerError, err.Error())
+		respondServiceError(w, err)
 		return
 	}
 
@@ -137,3 +286,51 @@ func respondJSON(w http.ResponseWriter, status int, data interface{}) {
 func respondError(w http.ResponseWriter, status int, message string) {
 	respondJSON(w, status, map[string]string{"error": message})
 }
+
+// respondServiceError maps service errors to HTTP status codes
+func respondServiceError(w http.ResponseWriter, err error) {
+	var windowErr *services.WindowClosedError
+	switch {
+	case errors.As(err, &windowErr):
+		respondJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+			"error":               err.Error(),
+			"code":                "window_closed",
+			"window_expires_at":   windowErr.ExpiredAt,
+			"suggested_templates": windowErr.Templates,
+		})
+	case errors.Is(err, services.ErrForbidden):
+		respondError(w, http.StatusForbidden, "You do not have access to this resource")
+	case errors.Is(err, services.ErrInvalidInput):
+		respondError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrAlreadyAssigned), errors.Is(err, repositories.ErrDuplicate),
+		errors.Is(err, services.ErrBroadcastState):
+		respondError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, pgx.ErrNoRows):
+		respondError(w, http.StatusNotFound, "Not found")
+	case errors.Is(err, services.ErrTemplatesNotConfigured):
+		respondError(w, http.StatusServiceUnavailable, err.Error())
+	default:
+		respondError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// queryInt parses a non-negative integer query parameter, capped at max when max > 0
+func queryInt(r *http.Request, key string, fallback, max int) int {
+	n, err := strconv.Atoi(r.URL.Query().Get(key))
+	if err != nil || n < 0 {
+		return fallback
+	}
+	if max > 0 && n > max {
+		return max
+	}
+	return n
+}
+
+// queryCursor decodes the optional cursor query parameter
+func queryCursor(r *http.Request) (*types.Cursor, error) {
+	token := r.URL.Query().Get("cursor")
+	if token == "" {
+		return nil, nil
+	}
+	return types.DecodeCursor(token)
+}
@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/temanbatin/omnichannel/internal/auth"
+	"github.com/temanbatin/omnichannel/internal/services"
+)
+
+// streamHeartbeat keeps idle connections open through proxies
+const streamHeartbeat = 25 * time.Second
+
+type RealtimeController struct {
+	messagingSvc *services.MessagingService
+}
+
+func NewRealtimeController(messagingSvc *services.MessagingService) *RealtimeController {
+	return &RealtimeController{messagingSvc: messagingSvc}
+}
+
+// Stream pushes inbox events to the client as Server-Sent Events until it disconnects.
+// Each event's SSE name is its type (message.created, message.status, conversation.updated, typing).
+// When the access token expires a final session.expired event is sent and the stream
+// closes, so the client refreshes its token before reconnecting.
+func (c *RealtimeController) Stream(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	// Streams outlive any server write timeout
+	rc.SetWriteDeadline(time.Time{})
+
+	sub := c.messagingSvc.SubscribeRealtime(r.Context())
+	defer c.messagingSvc.UnsubscribeRealtime(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprint(w, "retry: 3000\n: connected\n\n")
+	if err := rc.Flush(); err != nil {
+		log.Printf("Realtime stream unsupported: %v", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	expiry := time.NewTimer(time.Until(auth.TokenExpiryFromContext(r.Context())))
+	defer expiry.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-expiry.C:
+			fmt.Fprint(w, "event: session.expired\ndata: {}\n\n")
+			rc.Flush()
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case event, ok := <-sub.Events:
+			if !ok {
+				// Dropped as a slow consumer or shutting down; the client reconnects
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/temanbatin/omnichannel/internal/services"
+	"github.com/temanbatin/omnichannel/internal/types"
+)
+
+type RoutingController struct {
+	routingSvc *services.RoutingService
+}
+
+func NewRoutingController(routingSvc *services.RoutingService) *RoutingController {
+	return &RoutingController{routingSvc: routingSvc}
+}
+
+// List returns all routing rules in evaluation order
+func (c *RoutingController) List(w http.ResponseWriter, r *http.Request) {
+	rules, err := c.routingSvc.ListRules(r.Context())
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"rules": rules,
+		"total": len(rules),
+	})
+}
+
+// Create adds a routing rule
+func (c *RoutingController) Create(w http.ResponseWriter, r *http.Request) {
+	var req types.RoutingRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	rule, err := c.routingSvc.CreateRule(r.Context(), &req)
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, rule)
+}
+
+// Update replaces a routing rule
+func (c *RoutingController) Update(w http.ResponseWriter, r *http.Request) {
+	var req types.RoutingRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	rule, err := c.routingSvc.UpdateRule(r.Context(), chi.URLParam(r, "id"), &req)
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, rule)
+}
+
+// Delete removes a routing rule
+func (c *RoutingController) Delete(w http.ResponseWriter, r *http.Request) {
+	if err := c.routingSvc.DeleteRule(r.Context(), chi.URLParam(r, "id")); err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/temanbatin/omnichannel/internal/services"
+	"github.com/temanbatin/omnichannel/internal/types"
+)
+
+type TemplateController struct {
+	templateSvc *services.TemplateService
+}
+
+func NewTemplateController(templateSvc *services.TemplateService) *TemplateController {
+	return &TemplateController{templateSvc: templateSvc}
+}
+
+// List returns WhatsApp templates, optionally filtered by status
+func (c *TemplateController) List(w http.ResponseWriter, r *http.Request) {
+	templates, err := c.templateSvc.ListTemplates(r.Context(), types.TemplateStatus(r.URL.Query().Get("status")))
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"templates": templates,
+		"total":     len(templates),
+	})
+}
+
+// Get returns a single template
+func (c *TemplateController) Get(w http.ResponseWriter, r *http.Request) {
+	template, err := c.templateSvc.GetTemplate(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, template)
+}
+
+// Create submits a new template for Meta's approval
+func (c *TemplateController) Create(w http.ResponseWriter, r *http.Request) {
+	var req types.CreateTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	template, err := c.templateSvc.CreateTemplate(r.Context(), &req)
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, template)
+}
+
+// Delete removes a template from the business account and the dashboard
+func (c *TemplateController) Delete(w http.ResponseWriter, r *http.Request) {
+	if err := c.templateSvc.DeleteTemplate(r.Context(), chi.URLParam(r, "id")); err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Sync pulls templates and approval statuses from the business account now
+func (c *TemplateController) Sync(w http.ResponseWriter, r *http.Request) {
+	result, err := c.templateSvc.SyncTemplates(r.Context())
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, result)
+}
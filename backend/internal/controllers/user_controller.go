@@ -0,0 +1,116 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/temanbatin/omnichannel/internal/services"
+	"github.com/temanbatin/omnichannel/internal/types"
+)
+
+type UserController struct {
+	userSvc *services.UserService
+}
+
+func NewUserController(userSvc *services.UserService) *UserController {
+	return &UserController{userSvc: userSvc}
+}
+
+// List returns all users
+func (c *UserController) List(w http.ResponseWriter, r *http.Request) {
+	users, err := c.userSvc.ListUsers(r.Context())
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"users": users,
+		"total": len(users),
+	})
+}
+
+// Create creates a new user
+func (c *UserController) Create(w http.ResponseWriter, r *http.Request) {
+	var req types.CreateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := c.userSvc.CreateUser(r.Context(), &req)
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, user)
+}
+
+// Update changes a user's role, team, password or active flag
+func (c *UserController) Update(w http.ResponseWriter, r *http.Request) {
+	var req types.UpdateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := c.userSvc.UpdateUser(r.Context(), chi.URLParam(r, "id"), &req)
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, user)
+}
+
+// UpdateAvailability sets the current user's availability for conversation routing
+func (c *UserController) UpdateAvailability(w http.ResponseWriter, r *http.Request) {
+	var req types.UpdateAvailabilityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := c.userSvc.UpdateAvailability(r.Context(), req.Availability)
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, user)
+}
+
+// ListTeams returns all teams
+func (c *UserController) ListTeams(w http.ResponseWriter, r *http.Request) {
+	teams, err := c.userSvc.ListTeams(r.Context())
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"teams": teams,
+		"total": len(teams),
+	})
+}
+
+// CreateTeam creates a new team
+func (c *UserController) CreateTeam(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	team, err := c.userSvc.CreateTeam(r.Context(), req.Name)
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, team)
+}
@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/temanbatin/omnichannel/internal/services"
+	"github.com/temanbatin/omnichannel/internal/types"
+)
+
+type WebhookAdminController struct {
+	webhookProcessor *services.WebhookProcessor
+	eventDispatcher  *services.EventDispatcher
+}
+
+func NewWebhookAdminController(webhookProcessor *services.WebhookProcessor, eventDispatcher *services.EventDispatcher) *WebhookAdminController {
+	return &WebhookAdminController{
+		webhookProcessor: webhookProcessor,
+		eventDispatcher:  eventDispatcher,
+	}
+}
+
+// List returns recorded webhook events, filterable by source, status and time range
+func (c *WebhookAdminController) List(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := types.WebhookEventFilter{
+		Source: types.WebhookSource(query.Get("source")),
+		Status: types.WebhookEventStatus(query.Get("status")),
+		Limit:  queryInt(r, "limit", 50, 200),
+		Offset: queryInt(r, "offset", 0, -1),
+	}
+
+	var err error
+	if filter.Since, err = queryTime(r, "since"); err != nil {
+		respondError(w, http.StatusBadRequest, "since must be an RFC3339 timestamp")
+		return
+	}
+	if filter.Until, err = queryTime(r, "until"); err != nil {
+		respondError(w, http.StatusBadRequest, "until must be an RFC3339 timestamp")
+		return
+	}
+
+	events, total, err := c.webhookProcessor.ListEvents(r.Context(), filter)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"events": events,
+		"total":  total,
+	})
+}
+
+// Get returns a single webhook event with its raw payload and headers
+func (c *WebhookAdminController) Get(w http.ResponseWriter, r *http.Request) {
+	event, err := c.webhookProcessor.GetEvent(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			respondError(w, http.StatusNotFound, "Webhook event not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, event)
+}
+
+// Replay re-runs processing for a stored webhook event
+func (c *WebhookAdminController) Replay(w http.ResponseWriter, r *http.Request) {
+	event, err := c.webhookProcessor.Replay(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			respondError(w, http.StatusNotFound, "Webhook event not found")
+		case errors.Is(err, services.ErrWebhookEventBusy):
+			respondError(w, http.StatusConflict, err.Error())
+		case errors.Is(err, services.ErrWebhookUnverified):
+			respondError(w, http.StatusUnprocessableEntity, err.Error())
+		default:
+			respondError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusOK, event)
+}
+
+// ListDeliveries returns the outbound event delivery log, filterable by target, status and event type
+func (c *WebhookAdminController) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := types.EventDeliveryFilter{
+		Target:    query.Get("target"),
+		Status:    types.DeliveryStatus(query.Get("status")),
+		EventType: types.EventType(query.Get("event_type")),
+		Limit:     queryInt(r, "limit", 50, 200),
+		Offset:    queryInt(r, "offset", 0, -1),
+	}
+
+	deliveries, total, err := c.eventDispatcher.ListDeliveries(r.Context(), filter)
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"deliveries": deliveries,
+		"total":      total,
+	})
+}
+
+// queryTime parses an optional RFC3339 query parameter
+func queryTime(r *http.Request, key string) (*time.Time, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
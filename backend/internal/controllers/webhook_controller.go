@@ -5,24 +5,48 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/temanbatin/omnichannel/internal/auth"
 	"github.com/temanbatin/omnichannel/internal/config"
 	"github.com/temanbatin/omnichannel/internal/services"
 	"github.com/temanbatin/omnichannel/internal/types"
 )
 
+const (
+	// maxWebhookBodySize bounds an inbound webhook body; Meta's batches are far smaller
+	maxWebhookBodySize = 2 << 20
+	// maxRejectedPayloadSize bounds how much of an unauthenticated rejected body is recorded,
+	// so unsigned requests cannot fill the inbox
+	maxRejectedPayloadSize = 1 << 10
+	// rejectRecordLimit unauthenticated rejects per source IP are recorded each rejectRecordWindow;
+	// further ones are only logged
+	rejectRecordLimit  = 20
+	rejectRecordWindow = time.Minute
+	// rejectMaxSources bounds how many source IPs the reject limiter tracks at once
+	rejectMaxSources = 10000
+)
+
 type WebhookController struct {
-	messagingSvc *services.MessagingService
-	config       *config.Config
+	webhookProcessor *services.WebhookProcessor
+	internalVerifier *auth.InternalWebhookVerifier
+	config           *config.Config
+	rejects          *rejectLimiter
 }
 
-func NewWebhookController(messagingSvc *services.MessagingService, cfg *config.Config) *WebhookController {
+func NewWebhookController(webhookProcessor *services.WebhookProcessor, internalVerifier *auth.InternalWebhookVerifier, cfg *config.Config) *WebhookController {
 	return &WebhookController{
-		messagingSvc: messagingSvc,
-		config:       cfg,
+		webhookProcessor: webhookProcessor,
+		internalVerifier: internalVerifier,
+		config:           cfg,
+		rejects:          newRejectLimiter(rejectRecordLimit, rejectRecordWindow, rejectMaxSources),
 	}
 }
 
@@ -45,40 +69,8 @@ func (c *WebhookController) VerifyWhatsApp(w http.ResponseWriter, r *http.Reques
 
 // HandleWhatsApp handles incoming WhatsApp webhooks (POST)
 func (c *WebhookController) HandleWhatsApp(w http.ResponseWriter, r *http.Request) {
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		log.Printf("Failed to read webhook body: %v", err)
-		http.Error(w, "Failed to read body", http.StatusBadRequest)
-		return
-	}
-
-	// Verify signature if app secret is configured
-	if c.config.MetaAppSecret != "" {
-		signature := r.Header.Get("X-Hub-Signature-256")
-		if !c.verifySignature(body, signature) {
-			log.Printf("Invalid webhook signature")
-			http.Error(w, "Invalid signature", http.StatusUnauthorized)
-			return
-		}
-	}
-
-	var payload types.WebhookPayload
-	if err := json.Unmarshal(body, &payload); err != nil {
-		log.Printf("Failed to parse webhook payload: %v", err)
-		http.Error(w, "Invalid payload", http.StatusBadRequest)
-		return
-	}
-
-	// Process asynchronously to respond quickly
-	go func() {
-		if err := c.messagingSvc.ProcessIncomingWhatsApp(r.Context(), &payload); err != nil {
-			log.Printf("Failed to process WhatsApp message: %v", err)
-		}
-	}()
-
-	// Always respond 200 quickly to Meta
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("EVENT_RECEIVED"))
+	// Always respond 200 quickly to Meta, processing happens in the worker pool
+	c.receive(w, r, types.WebhookSourceWhatsApp, c.verifyMetaSignature, "EVENT_RECEIVED")
 }
 
 // VerifyInstagram handles Instagram webhook verification (GET)
@@ -100,66 +92,181 @@ func (c *WebhookController) VerifyInstagram(w http.ResponseWriter, r *http.Reque
 
 // HandleInstagram handles incoming Instagram webhooks (POST)
 func (c *WebhookController) HandleInstagram(w http.ResponseWriter, r *http.Request) {
-	body, err := io.ReadAll(r.Body)
+	c.receive(w, r, types.WebhookSourceInstagram, c.verifyMetaSignature, "EVENT_RECEIVED")
+}
+
+// HandleWhatsAppInternal handles forwarded WhatsApp webhooks from n8n
+// (HMAC signature or API key, see auth.InternalWebhookVerifier)
+func (c *WebhookController) HandleWhatsAppInternal(w http.ResponseWriter, r *http.Request) {
+	c.receive(w, r, types.WebhookSourceN8N, c.verifyInternal, "OK")
+}
+
+// receive records an inbound webhook and queues it for processing.
+// Rejected requests are still recorded so they can be inspected, but for a body that was not
+// authenticated only its start is kept, and only a limited number per source IP.
+// Oversized bodies are refused without a record.
+func (c *WebhookController) receive(
+	w http.ResponseWriter,
+	r *http.Request,
+	source types.WebhookSource,
+	verify func(*http.Request, []byte) (types.SignatureStatus, string, error),
+	ack string,
+) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodySize))
 	if err != nil {
-		log.Printf("Failed to read webhook body: %v", err)
+		log.Printf("Failed to read %s webhook body: %v", source, err)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
 		http.Error(w, "Failed to read body", http.StatusBadRequest)
 		return
 	}
 
-	// Verify signature if app secret is configured
-	if c.config.MetaAppSecret != "" {
-		signature := r.Header.Get("X-Hub-Signature-256")
-		if !c.verifySignature(body, signature) {
-			log.Printf("Invalid webhook signature")
-			http.Error(w, "Invalid signature", http.StatusUnauthorized)
+	headers := recordedHeaders(r)
+	signature, signatureHash, err := verify(r, body)
+	if err != nil {
+		log.Printf("Rejected %s webhook: %v", source, err)
+		c.reject(r, source, body, headers, signature, err.Error())
+		if errors.Is(err, auth.ErrWebhookIPNotAllowed) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
 			return
 		}
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
 	}
 
 	var payload types.WebhookPayload
 	if err := json.Unmarshal(body, &payload); err != nil {
-		log.Printf("Failed to parse webhook payload: %v", err)
+		log.Printf("Failed to parse %s webhook payload: %v", source, err)
+		c.reject(r, source, body, headers, signature, "invalid payload: "+err.Error())
 		http.Error(w, "Invalid payload", http.StatusBadRequest)
 		return
 	}
 
-	// Process asynchronously
-	go func() {
-		if err := c.messagingSvc.ProcessIncomingInstagram(r.Context(), &payload); err != nil {
-			log.Printf("Failed to process Instagram message: %v", err)
-		}
-	}()
+	// Persist before acknowledging; a non-200 makes the sender retry delivery
+	// The signature is only recorded as used once the event is stored, so a sender retrying
+	// after a failed store is not mistaken for a replay
+	_, err = c.webhookProcessor.Enqueue(r.Context(), source, body, headers, signature, signatureHash)
+	if errors.Is(err, services.ErrWebhookReplayed) {
+		log.Printf("Rejected %s webhook: %v", source, err)
+		http.Error(w, "Signature already used", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to enqueue %s webhook: %v", source, err)
+		http.Error(w, "Failed to store event", http.StatusInternalServerError)
+		return
+	}
 
 	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("EVENT_RECEIVED"))
+	w.Write([]byte(ack))
 }
 
-// HandleWhatsAppInternal handles forwarded WhatsApp webhooks from n8n (no signature check)
-func (c *WebhookController) HandleWhatsAppInternal(w http.ResponseWriter, r *http.Request) {
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		log.Printf("Failed to read internal webhook body: %v", err)
-		http.Error(w, "Failed to read body", http.StatusBadRequest)
-		return
+func (c *WebhookController) reject(r *http.Request, source types.WebhookSource, body []byte, headers map[string]string, signature types.SignatureStatus, reason string) {
+	if signature != types.SignatureValid {
+		if ip := c.internalVerifier.ClientIP(r).String(); !c.rejects.allow(ip, time.Now()) {
+			log.Printf("Not recording rejected %s webhook from %s: too many rejects", source, ip)
+			return
+		}
+		if len(body) > maxRejectedPayloadSize {
+			reason = fmt.Sprintf("%s (payload truncated from %d bytes)", reason, len(body))
+			body = body[:maxRejectedPayloadSize]
+		}
+	}
+	if err := c.webhookProcessor.Reject(r.Context(), source, body, headers, signature, reason); err != nil {
+		log.Printf("Failed to record rejected %s webhook: %v", source, err)
 	}
+}
 
-	var payload types.WebhookPayload
-	if err := json.Unmarshal(body, &payload); err != nil {
-		log.Printf("Failed to parse internal webhook payload: %v", err)
-		http.Error(w, "Invalid payload", http.StatusBadRequest)
-		return
+// rejectLimiter counts recorded rejects per source in fixed windows
+type rejectLimiter struct {
+	limit      int
+	window     time.Duration
+	maxSources int
+
+	mu      sync.Mutex
+	sources map[string]*rejectWindow
+}
+
+type rejectWindow struct {
+	start time.Time
+	count int
+}
+
+func newRejectLimiter(limit int, window time.Duration, maxSources int) *rejectLimiter {
+	return &rejectLimiter{
+		limit:      limit,
+		window:     window,
+		maxSources: maxSources,
+		sources:    make(map[string]*rejectWindow),
+	}
+}
+
+// allow reports whether another reject from source may be recorded at now. When it already
+// tracks maxSources sources with open windows it refuses new ones.
+func (l *rejectLimiter) allow(source string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	w, ok := l.sources[source]
+	if ok && now.Sub(w.start) < l.window {
+		if w.count >= l.limit {
+			return false
+		}
+		w.count++
+		return true
 	}
 
-	// Process asynchronously
-	go func() {
-		if err := c.messagingSvc.ProcessIncomingWhatsApp(r.Context(), &payload); err != nil {
-			log.Printf("Failed to process WhatsApp message from n8n: %v", err)
+	if !ok && len(l.sources) >= l.maxSources {
+		for key, w := range l.sources {
+			if now.Sub(w.start) >= l.window {
+				delete(l.sources, key)
+			}
+		}
+		if len(l.sources) >= l.maxSources {
+			return false
 		}
-	}()
+	}
+	l.sources[source] = &rejectWindow{start: now, count: 1}
+	return true
+}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("OK"))
+// verifyMetaSignature checks X-Hub-Signature-256 when an app secret is configured.
+// Meta signs only the body, so its signatures carry no replay protection of their own.
+func (c *WebhookController) verifyMetaSignature(r *http.Request, body []byte) (types.SignatureStatus, string, error) {
+	if c.config.MetaAppSecret == "" {
+		return types.SignatureSkipped, "", nil
+	}
+	if !c.verifySignature(body, r.Header.Get("X-Hub-Signature-256")) {
+		return types.SignatureInvalid, "", errors.New("invalid signature")
+	}
+	return types.SignatureValid, "", nil
+}
+
+// verifyInternal authenticates webhooks forwarded by n8n and returns the accepted HMAC
+// signature, which Enqueue refuses to store twice
+func (c *WebhookController) verifyInternal(r *http.Request, body []byte) (types.SignatureStatus, string, error) {
+	signatureHash, err := c.internalVerifier.Verify(r, body)
+	if err != nil {
+		return types.SignatureInvalid, "", err
+	}
+	return types.SignatureValid, signatureHash, nil
+}
+
+// recordedHeaders returns request headers worth keeping for inspection, without credentials
+func recordedHeaders(r *http.Request) map[string]string {
+	headers := make(map[string]string, len(r.Header))
+	for name, values := range r.Header {
+		switch http.CanonicalHeaderKey(name) {
+		case "Authorization", "Cookie", "X-Api-Key", "X-Signature":
+			headers[name] = "[redacted]"
+		default:
+			headers[name] = strings.Join(values, ", ")
+		}
+	}
+	return headers
 }
 
 // verifySignature verifies the Meta webhook signature
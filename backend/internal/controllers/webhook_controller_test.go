@@ -0,0 +1,31 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRejectLimiter(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	l := newRejectLimiter(2, time.Minute, 2)
+
+	tests := []struct {
+		source string
+		at     time.Duration
+		want   bool
+	}{
+		{"203.0.113.7", 0, true},
+		{"203.0.113.7", time.Second, true},
+		{"203.0.113.7", 2 * time.Second, false},          // Limit reached
+		{"198.51.100.1", 3 * time.Second, true},          // Counted separately
+		{"192.0.2.1", 4 * time.Second, false},            // No room for a third source
+		{"203.0.113.7", time.Minute, true},               // New window
+		{"192.0.2.1", time.Minute + 3*time.Second, true}, // 198.51.100.1's window has ended
+		{"198.51.100.1", time.Minute + 4*time.Second, false},
+	}
+	for i, tt := range tests {
+		if got := l.allow(tt.source, start.Add(tt.at)); got != tt.want {
+			t.Errorf("case %d: allow(%s) = %v, want %v", i, tt.source, got, tt.want)
+		}
+	}
+}
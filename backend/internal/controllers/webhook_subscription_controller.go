@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/temanbatin/omnichannel/internal/services"
+	"github.com/temanbatin/omnichannel/internal/types"
+)
+
+type WebhookSubscriptionController struct {
+	subscriptionSvc *services.WebhookSubscriptionService
+}
+
+func NewWebhookSubscriptionController(subscriptionSvc *services.WebhookSubscriptionService) *WebhookSubscriptionController {
+	return &WebhookSubscriptionController{subscriptionSvc: subscriptionSvc}
+}
+
+// List returns all webhook subscriptions
+func (c *WebhookSubscriptionController) List(w http.ResponseWriter, r *http.Request) {
+	subs, err := c.subscriptionSvc.ListSubscriptions(r.Context())
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"subscriptions": subs,
+		"total":         len(subs),
+	})
+}
+
+// Create registers a webhook subscription; the response includes its signing secret
+func (c *WebhookSubscriptionController) Create(w http.ResponseWriter, r *http.Request) {
+	var req types.CreateWebhookSubscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	sub, err := c.subscriptionSvc.CreateSubscription(r.Context(), &req)
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, sub)
+}
+
+// Get returns a single webhook subscription
+func (c *WebhookSubscriptionController) Get(w http.ResponseWriter, r *http.Request) {
+	sub, err := c.subscriptionSvc.GetSubscription(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, sub)
+}
+
+// Update edits, re-enables or rotates the secret of a webhook subscription
+func (c *WebhookSubscriptionController) Update(w http.ResponseWriter, r *http.Request) {
+	var req types.UpdateWebhookSubscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	sub, err := c.subscriptionSvc.UpdateSubscription(r.Context(), chi.URLParam(r, "id"), &req)
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, sub)
+}
+
+// Delete removes a webhook subscription and its delivery history
+func (c *WebhookSubscriptionController) Delete(w http.ResponseWriter, r *http.Request) {
+	if err := c.subscriptionSvc.DeleteSubscription(r.Context(), chi.URLParam(r, "id")); err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListDeliveries returns the delivery history of a subscription, filterable by status and event type
+func (c *WebhookSubscriptionController) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := types.EventDeliveryFilter{
+		Status:    types.DeliveryStatus(query.Get("status")),
+		EventType: types.EventType(query.Get("event_type")),
+		Limit:     queryInt(r, "limit", 50, 200),
+		Offset:    queryInt(r, "offset", 0, -1),
+	}
+
+	deliveries, total, err := c.subscriptionSvc.ListDeliveries(r.Context(), chi.URLParam(r, "id"), filter)
+	if err != nil {
+		respondServiceError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"deliveries": deliveries,
+		"total":      total,
+	})
+}
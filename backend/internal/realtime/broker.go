@@ -0,0 +1,183 @@
+// Package realtime fans dashboard events out to connected clients.
+// Events travel through Postgres LISTEN/NOTIFY so every backend replica sees them.
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/temanbatin/omnichannel/internal/repositories"
+	"github.com/temanbatin/omnichannel/internal/types"
+)
+
+const (
+	// channel is the Postgres notification channel shared by all replicas
+	channel = "omnichannel_realtime"
+
+	// maxPayload stays under Postgres' 8000 byte NOTIFY limit
+	maxPayload = 7900
+
+	subscriberBuffer = 64
+	reconnectDelay   = 5 * time.Second
+)
+
+// Subscription receives events accepted by its filter.
+// Events is closed when the subscriber falls behind or the broker stops.
+type Subscription struct {
+	Events <-chan *types.RealtimeEvent
+
+	events chan *types.RealtimeEvent
+	accept func(*types.RealtimeEvent) bool
+}
+
+// Broker publishes events with NOTIFY and delivers everything it LISTENs to
+// to the local subscribers
+type Broker struct {
+	db *repositories.DB
+
+	mu          sync.Mutex
+	subscribers map[*Subscription]struct{}
+	stopped     bool
+}
+
+// NewBroker creates a new broker. Run must be started for subscribers to receive events.
+func NewBroker(db *repositories.DB) *Broker {
+	return &Broker{
+		db:          db,
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish broadcasts an event to all replicas. Oversized data is dropped and the event
+// marked truncated so clients refetch. Failures are logged, never returned.
+func (b *Broker) Publish(ctx context.Context, event *types.RealtimeEvent) {
+	if b == nil {
+		return
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to marshal realtime %s event: %v", event.Type, err)
+		return
+	}
+	if len(payload) > maxPayload {
+		trimmed := *event
+		trimmed.Data = nil
+		trimmed.Truncated = true
+		if payload, err = json.Marshal(&trimmed); err != nil {
+			return
+		}
+	}
+
+	if _, err := b.db.Pool.Exec(ctx, `SELECT pg_notify($1, $2)`, channel, string(payload)); err != nil {
+		log.Printf("Failed to publish realtime %s event: %v", event.Type, err)
+	}
+}
+
+// Subscribe registers a local subscriber. accept decides which events it receives.
+func (b *Broker) Subscribe(accept func(*types.RealtimeEvent) bool) *Subscription {
+	events := make(chan *types.RealtimeEvent, subscriberBuffer)
+	sub := &Subscription{Events: events, events: events, accept: accept}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.stopped {
+		close(events)
+		return sub
+	}
+	b.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// Unsubscribe removes a subscriber and closes its channel
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subscribers[sub]; ok {
+		delete(b.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+// Run listens for notifications until ctx is cancelled, reconnecting after errors.
+// When it returns every subscription has been closed.
+func (b *Broker) Run(ctx context.Context) {
+	defer b.stop()
+
+	for {
+		err := b.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Realtime listener stopped, reconnecting in %s: %v", reconnectDelay, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+// listen holds a dedicated connection in LISTEN mode and dispatches notifications
+func (b *Broker) listen(ctx context.Context) error {
+	conn, err := b.db.Pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// Close rather than return a connection still subscribed to the channel
+	defer func() {
+		conn.Conn().Close(context.Background())
+		conn.Release()
+	}()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+channel); err != nil {
+		return err
+	}
+
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		var event types.RealtimeEvent
+		if err := json.Unmarshal([]byte(notification.Payload), &event); err != nil {
+			log.Printf("Ignoring malformed realtime notification: %v", err)
+			continue
+		}
+		b.dispatch(&event)
+	}
+}
+
+// dispatch hands an event to matching subscribers. A subscriber whose buffer is full
+// is dropped so one slow client cannot stall the others; it reconnects and refetches.
+func (b *Broker) dispatch(event *types.RealtimeEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subscribers {
+		if sub.accept != nil && !sub.accept(event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			delete(b.subscribers, sub)
+			close(sub.events)
+		}
+	}
+}
+
+// stop closes all subscriptions and rejects new ones
+func (b *Broker) stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.stopped = true
+	for sub := range b.subscribers {
+		delete(b.subscribers, sub)
+		close(sub.events)
+	}
+}
@@ -0,0 +1,414 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/temanbatin/omnichannel/internal/types"
+)
+
+const broadcastColumns = `id, name, COALESCE(template_id::text, ''), platform, COALESCE(status, 'draft'), template_params,
+		COALESCE(total_recipients, 0), COALESCE(sent_count, 0), COALESCE(delivered_count, 0), COALESCE(failed_count, 0),
+		skipped_count, last_error, COALESCE(created_by::text, ''), scheduled_at, started_at, completed_at, created_at, updated_at`
+
+const broadcastRecipientColumns = `id, broadcast_id, contact_id, COALESCE((SELECT name FROM contacts WHERE contacts.id = contact_id), ''),
+		whatsapp_id, status, COALESCE(message_id::text, ''), external_id, error_code, error_title,
+		sent_at, delivered_at, read_at, created_at, updated_at`
+
+type BroadcastRepository struct {
+	db *DB
+}
+
+func NewBroadcastRepository(db *DB) *BroadcastRepository {
+	return &BroadcastRepository{db: db}
+}
+
+func (r *BroadcastRepository) Create(ctx context.Context, b *types.Broadcast) error {
+	query := `
+		INSERT INTO broadcasts (id, name, template_id, platform, status, template_params, created_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, '')::uuid, $8, $9)
+	`
+	_, err := r.db.conn(ctx).Exec(ctx, query,
+		b.ID, b.Name, b.TemplateID, b.Platform, b.Status, b.TemplateParams,
+		b.CreatedBy, b.CreatedAt, b.UpdatedAt,
+	)
+	return err
+}
+
+func (r *BroadcastRepository) GetByID(ctx context.Context, id string) (*types.Broadcast, error) {
+	query := `SELECT ` + broadcastColumns + ` FROM broadcasts WHERE id = $1`
+	return scanBroadcast(r.db.conn(ctx).QueryRow(ctx, query, id))
+}
+
+// List returns broadcasts newest first, optionally only those with the given status
+func (r *BroadcastRepository) List(ctx context.Context, status types.BroadcastStatus) ([]*types.Broadcast, error) {
+	query := `
+		SELECT ` + broadcastColumns + ` FROM broadcasts
+		WHERE $1 = '' OR status = $1
+		ORDER BY created_at DESC, id DESC
+	`
+	rows, err := r.db.conn(ctx).Query(ctx, query, string(status))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var broadcasts []*types.Broadcast
+	for rows.Next() {
+		b, err := scanBroadcast(rows)
+		if err != nil {
+			return nil, err
+		}
+		broadcasts = append(broadcasts, b)
+	}
+	return broadcasts, rows.Err()
+}
+
+// Schedule moves a draft broadcast to scheduled. It reports false if the broadcast is not a draft.
+func (r *BroadcastRepository) Schedule(ctx context.Context, id string, at time.Time) (bool, error) {
+	query := `
+		UPDATE broadcasts SET status = 'scheduled', scheduled_at = $2, last_error = ''
+		WHERE id = $1 AND status = 'draft'
+	`
+	tag, err := r.db.conn(ctx).Exec(ctx, query, id, at)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// Transition moves a broadcast to status if it is currently in one of from. It reports
+// false if the broadcast was in any other status. Final statuses set completed_at.
+func (r *BroadcastRepository) Transition(ctx context.Context, id string, from []types.BroadcastStatus, status types.BroadcastStatus) (bool, error) {
+	query := `
+		UPDATE broadcasts
+		SET status = $2::text,
+		    completed_at = CASE WHEN $2::text IN ('completed', 'cancelled', 'failed') THEN NOW() ELSE completed_at END
+		WHERE id = $1 AND status = ANY($3)
+	`
+	current := make([]string, len(from))
+	for i, s := range from {
+		current[i] = string(s)
+	}
+	tag, err := r.db.conn(ctx).Exec(ctx, query, id, string(status), current)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// StartDue moves scheduled broadcasts whose time has come to sending
+func (r *BroadcastRepository) StartDue(ctx context.Context) (int, error) {
+	query := `
+		UPDATE broadcasts SET status = 'sending', started_at = COALESCE(started_at, NOW())
+		WHERE status = 'scheduled' AND scheduled_at <= NOW()
+	`
+	tag, err := r.db.conn(ctx).Exec(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// Fail stops a broadcast with an error and cancels the recipients it has not reached
+func (r *BroadcastRepository) Fail(ctx context.Context, id, lastError string) error {
+	return r.db.WithTx(ctx, func(ctx context.Context) error {
+		query := `
+			UPDATE broadcasts SET status = 'failed', last_error = $2, completed_at = NOW()
+			WHERE id = $1 AND status = 'sending'
+		`
+		if _, err := r.db.conn(ctx).Exec(ctx, query, id, lastError); err != nil {
+			return err
+		}
+		return r.CancelPending(ctx, id)
+	})
+}
+
+// SetLastError records why a sending broadcast is held up; an empty message clears it
+func (r *BroadcastRepository) SetLastError(ctx context.Context, id, lastError string) error {
+	_, err := r.db.conn(ctx).Exec(ctx, `UPDATE broadcasts SET last_error = $2 WHERE id = $1 AND last_error <> $2`, id, lastError)
+	return err
+}
+
+// CompleteIfDone completes a sending broadcast once no recipient is left to send to
+func (r *BroadcastRepository) CompleteIfDone(ctx context.Context, id string) (bool, error) {
+	query := `
+		UPDATE broadcasts SET status = 'completed', completed_at = NOW(), last_error = ''
+		WHERE id = $1 AND status = 'sending'
+		  AND NOT EXISTS (
+			SELECT 1 FROM broadcast_recipients
+			WHERE broadcast_id = $1 AND status IN ('pending', 'sending')
+		  )
+	`
+	tag, err := r.db.conn(ctx).Exec(ctx, query, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// AddRecipients adds the given contacts that have a WhatsApp ID. It returns how many of
+// them exist with a WhatsApp ID and how many of those were not already recipients.
+func (r *BroadcastRepository) AddRecipients(ctx context.Context, broadcastID string, contactIDs []string) (matched, added int, err error) {
+	return r.addRecipients(ctx, broadcastID, `
+		SELECT id, whatsapp_id FROM contacts
+		WHERE id = ANY($2::text[]::uuid[]) AND COALESCE(whatsapp_id, '') <> ''
+	`, contactIDs)
+}
+
+// AddRecipientsByTag adds every contact whose WhatsApp conversation carries the tag
+func (r *BroadcastRepository) AddRecipientsByTag(ctx context.Context, broadcastID, tag string) (matched, added int, err error) {
+	return r.addRecipients(ctx, broadcastID, `
+		SELECT DISTINCT ct.id, ct.whatsapp_id
+		FROM conversations c
+		JOIN contacts ct ON ct.id = c.contact_id
+		WHERE c.platform = 'whatsapp' AND $2 = ANY(c.tags) AND COALESCE(ct.whatsapp_id, '') <> ''
+	`, tag)
+}
+
+// addRecipients inserts the contacts selected by matchQuery ($1 is the broadcast, $2 is arg)
+// and keeps total_recipients in step
+func (r *BroadcastRepository) addRecipients(ctx context.Context, broadcastID, matchQuery string, arg interface{}) (matched, added int, err error) {
+	query := `
+		WITH matched AS (` + matchQuery + `),
+		inserted AS (
+			INSERT INTO broadcast_recipients (broadcast_id, contact_id, whatsapp_id)
+			SELECT $1, id, whatsapp_id FROM matched
+			ON CONFLICT (broadcast_id, contact_id) DO NOTHING
+			RETURNING 1
+		),
+		counted AS (
+			UPDATE broadcasts SET total_recipients = COALESCE(total_recipients, 0) + (SELECT COUNT(*) FROM inserted)
+			WHERE id = $1
+		)
+		SELECT (SELECT COUNT(*) FROM matched), (SELECT COUNT(*) FROM inserted)
+	`
+	err = r.db.conn(ctx).QueryRow(ctx, query, broadcastID, arg).Scan(&matched, &added)
+	return matched, added, err
+}
+
+// ListRecipients returns a page of a broadcast's recipients in the order they were added,
+// along with the total number matching the filter
+func (r *BroadcastRepository) ListRecipients(ctx context.Context, filter types.BroadcastRecipientFilter) ([]*types.BroadcastRecipient, int, error) {
+	args := []interface{}{filter.BroadcastID}
+	conditions := []string{"broadcast_id = $1"}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	where := "WHERE " + strings.Join(conditions, " AND ")
+
+	var total int
+	if err := r.db.conn(ctx).QueryRow(ctx, `SELECT COUNT(*) FROM broadcast_recipients `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	// The cursor narrows the page but not the total
+	if filter.After != nil {
+		args = append(args, filter.After.At, filter.After.ID)
+		conditions = append(conditions, fmt.Sprintf("(created_at, id) > ($%d, $%d::uuid)", len(args)-1, len(args)))
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	args = append(args, filter.Limit)
+	query := fmt.Sprintf(`
+		SELECT %s FROM broadcast_recipients
+		%s
+		ORDER BY created_at, id
+		LIMIT $%d
+	`, broadcastRecipientColumns, where, len(args))
+
+	rows, err := r.db.conn(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var recipients []*types.BroadcastRecipient
+	for rows.Next() {
+		recipient, err := scanBroadcastRecipient(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		recipients = append(recipients, recipient)
+	}
+	return recipients, total, rows.Err()
+}
+
+// ClaimPending marks up to limit pending recipients of a broadcast as sending and returns them
+func (r *BroadcastRepository) ClaimPending(ctx context.Context, broadcastID string, limit int) ([]*types.BroadcastRecipient, error) {
+	query := `
+		UPDATE broadcast_recipients SET status = 'sending'
+		WHERE id IN (
+			SELECT id FROM broadcast_recipients
+			WHERE broadcast_id = $1 AND status = 'pending'
+			ORDER BY created_at, id
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + broadcastRecipientColumns
+	rows, err := r.db.conn(ctx).Query(ctx, query, broadcastID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recipients []*types.BroadcastRecipient
+	for rows.Next() {
+		recipient, err := scanBroadcastRecipient(rows)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, recipient)
+	}
+	return recipients, rows.Err()
+}
+
+// MarkSent records a recipient's accepted message and counts it on the broadcast
+func (r *BroadcastRepository) MarkSent(ctx context.Context, id, messageID, externalID string) error {
+	query := `
+		WITH updated AS (
+			UPDATE broadcast_recipients
+			SET status = 'sent', message_id = NULLIF($2, '')::uuid, external_id = $3, sent_at = NOW()
+			WHERE id = $1 AND status = 'sending'
+			RETURNING broadcast_id
+		)
+		UPDATE broadcasts SET sent_count = COALESCE(sent_count, 0) + 1
+		WHERE id = (SELECT broadcast_id FROM updated)
+	`
+	_, err := r.db.conn(ctx).Exec(ctx, query, id, messageID, externalID)
+	return err
+}
+
+// MarkFailed records a recipient whose message could not be sent and counts it on the broadcast
+func (r *BroadcastRepository) MarkFailed(ctx context.Context, id, messageID string, errorCode int, errorTitle string) error {
+	query := `
+		WITH updated AS (
+			UPDATE broadcast_recipients
+			SET status = 'failed', message_id = NULLIF($2, '')::uuid, error_code = $3, error_title = $4
+			WHERE id = $1 AND status = 'sending'
+			RETURNING broadcast_id
+		)
+		UPDATE broadcasts SET failed_count = COALESCE(failed_count, 0) + 1
+		WHERE id = (SELECT broadcast_id FROM updated)
+	`
+	_, err := r.db.conn(ctx).Exec(ctx, query, id, messageID, errorCode, errorTitle)
+	return err
+}
+
+// MarkSkipped records a recipient that was not sent to, and why, and counts it on the broadcast
+func (r *BroadcastRepository) MarkSkipped(ctx context.Context, id, reason string) error {
+	query := `
+		WITH updated AS (
+			UPDATE broadcast_recipients SET status = 'skipped', error_title = $2
+			WHERE id = $1 AND status = 'sending'
+			RETURNING broadcast_id
+		)
+		UPDATE broadcasts SET skipped_count = skipped_count + 1
+		WHERE id = (SELECT broadcast_id FROM updated)
+	`
+	_, err := r.db.conn(ctx).Exec(ctx, query, id, reason)
+	return err
+}
+
+// FailStale fails recipients of a broadcast that were claimed longer ago than timeout
+// without the send being recorded, e.g. because the server stopped mid-send. They are not
+// retried, since Meta may already have delivered the message.
+func (r *BroadcastRepository) FailStale(ctx context.Context, broadcastID string, timeout time.Duration) (int, error) {
+	query := `
+		WITH updated AS (
+			UPDATE broadcast_recipients
+			SET status = 'failed', error_title = 'Interrupted before the send was recorded'
+			WHERE broadcast_id = $1 AND status = 'sending' AND updated_at < NOW() - make_interval(secs => $2)
+			RETURNING 1
+		),
+		counted AS (
+			UPDATE broadcasts SET failed_count = COALESCE(failed_count, 0) + (SELECT COUNT(*) FROM updated)
+			WHERE id = $1
+		)
+		SELECT COUNT(*) FROM updated
+	`
+	var failed int
+	err := r.db.conn(ctx).QueryRow(ctx, query, broadcastID, timeout.Seconds()).Scan(&failed)
+	return failed, err
+}
+
+// CancelPending cancels the recipients of a broadcast that have not been sent to
+func (r *BroadcastRepository) CancelPending(ctx context.Context, broadcastID string) error {
+	_, err := r.db.conn(ctx).Exec(ctx,
+		`UPDATE broadcast_recipients SET status = 'cancelled' WHERE broadcast_id = $1 AND status = 'pending'`,
+		broadcastID,
+	)
+	return err
+}
+
+// ApplyStatus moves the recipient whose message has the given WhatsApp ID to a delivery
+// status from a webhook, updating the broadcast's delivered and failed counts. Callers
+// check that the status moves the message forward.
+func (r *BroadcastRepository) ApplyStatus(ctx context.Context, externalID string, status types.MessageStatus, errorCode int, errorTitle string) error {
+	query := `
+		WITH previous AS (
+			SELECT id, status FROM broadcast_recipients
+			WHERE external_id = $1
+			FOR UPDATE
+		),
+		updated AS (
+			UPDATE broadcast_recipients r
+			SET status = $2::text,
+			    delivered_at = CASE WHEN $2::text IN ('delivered', 'read') THEN COALESCE(r.delivered_at, NOW()) ELSE r.delivered_at END,
+			    read_at = CASE WHEN $2::text = 'read' THEN NOW() ELSE r.read_at END,
+			    error_code = $3, error_title = $4
+			FROM previous p
+			WHERE r.id = p.id AND p.status <> $2::text
+			RETURNING r.broadcast_id, p.status AS previous
+		)
+		UPDATE broadcasts b
+		SET delivered_count = COALESCE(b.delivered_count, 0) +
+		        CASE WHEN $2::text IN ('delivered', 'read') AND u.previous NOT IN ('delivered', 'read') THEN 1 ELSE 0 END,
+		    failed_count = COALESCE(b.failed_count, 0) + CASE WHEN $2::text = 'failed' THEN 1 ELSE 0 END
+		FROM updated u
+		WHERE b.id = u.broadcast_id
+	`
+	_, err := r.db.conn(ctx).Exec(ctx, query, externalID, string(status), errorCode, errorTitle)
+	return err
+}
+
+// CountContactsSentSince returns how many distinct contacts broadcasts have messaged since the given time
+func (r *BroadcastRepository) CountContactsSentSince(ctx context.Context, since time.Time) (int, error) {
+	var count int
+	err := r.db.conn(ctx).QueryRow(ctx,
+		`SELECT COUNT(DISTINCT contact_id) FROM broadcast_recipients WHERE sent_at >= $1`,
+		since,
+	).Scan(&count)
+	return count, err
+}
+
+func scanBroadcast(row pgx.Row) (*types.Broadcast, error) {
+	b := &types.Broadcast{}
+	err := row.Scan(
+		&b.ID, &b.Name, &b.TemplateID, &b.Platform, &b.Status, &b.TemplateParams,
+		&b.TotalRecipients, &b.SentCount, &b.DeliveredCount, &b.FailedCount,
+		&b.SkippedCount, &b.LastError, &b.CreatedBy, &b.ScheduledAt, &b.StartedAt, &b.CompletedAt, &b.CreatedAt, &b.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func scanBroadcastRecipient(row pgx.Row) (*types.BroadcastRecipient, error) {
+	recipient := &types.BroadcastRecipient{}
+	err := row.Scan(
+		&recipient.ID, &recipient.BroadcastID, &recipient.ContactID, &recipient.ContactName,
+		&recipient.WhatsAppID, &recipient.Status, &recipient.MessageID, &recipient.ExternalID,
+		&recipient.ErrorCode, &recipient.ErrorTitle,
+		&recipient.SentAt, &recipient.DeliveredAt, &recipient.ReadAt, &recipient.CreatedAt, &recipient.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return recipient, nil
+}
@@ -2,11 +2,17 @@ package repositories
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/temanbatin/omnichannel/internal/types"
 )
 
+const contactColumns = `id, name, phone, email, whatsapp_id, instagram_id, avatar_url, metadata, created_at, updated_at`
+
 type ContactRepository struct {
 	db *DB
 }
@@ -20,91 +26,131 @@ func (r *ContactRepository) Create(ctx context.Context, contact *types.Contact)
 		INSERT INTO contacts (id, name, phone, email, whatsapp_id, instagram_id, avatar_url, metadata, created_at, updated_at)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 	`
-	_, err := r.db.Pool.Exec(ctx, query,
+	_, err := r.db.conn(ctx).Exec(ctx, query,
 		contact.ID, contact.Name, contact.Phone, contact.Email,
 		contact.WhatsAppID, contact.InstagramID, contact.AvatarURL,
 		contact.Metadata, contact.CreatedAt, contact.UpdatedAt,
 	)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("contact %w", ErrDuplicate)
+	}
 	return err
 }
 
-func (r *ContactRepository) GetByID(ctx context.Context, id string) (*types.Contact, error) {
+// CreateOrGetByWhatsAppID inserts contact unless one with its WhatsApp ID already exists,
+// in which case the stored contact is returned. It reports whether contact was inserted.
+// Concurrent callers for the same ID all get the same row.
+func (r *ContactRepository) CreateOrGetByWhatsAppID(ctx context.Context, contact *types.Contact) (*types.Contact, bool, error) {
+	return r.createOrGet(ctx, contact, `(whatsapp_id) WHERE whatsapp_id <> ''`, func(ctx context.Context) (*types.Contact, error) {
+		return r.GetByWhatsAppID(ctx, contact.WhatsAppID)
+	})
+}
+
+// CreateOrGetByInstagramID is CreateOrGetByWhatsAppID for Instagram-scoped user IDs
+func (r *ContactRepository) CreateOrGetByInstagramID(ctx context.Context, contact *types.Contact) (*types.Contact, bool, error) {
+	return r.createOrGet(ctx, contact, `(instagram_id) WHERE instagram_id <> ''`, func(ctx context.Context) (*types.Contact, error) {
+		return r.GetByInstagramID(ctx, contact.InstagramID)
+	})
+}
+
+// createOrGet inserts contact, falling back to get when the insert conflicts on the given unique index
+func (r *ContactRepository) createOrGet(
+	ctx context.Context,
+	contact *types.Contact,
+	conflictTarget string,
+	get func(ctx context.Context) (*types.Contact, error),
+) (*types.Contact, bool, error) {
 	query := `
-		SELECT id, name, phone, email, whatsapp_id, instagram_id, avatar_url, metadata, created_at, updated_at
-		FROM contacts WHERE id = $1
-	`
-	contact := &types.Contact{}
-	err := r.db.Pool.QueryRow(ctx, query, id).Scan(
-		&contact.ID, &contact.Name, &contact.Phone, &contact.Email,
-		&contact.WhatsAppID, &contact.InstagramID, &contact.AvatarURL,
-		&contact.Metadata, &contact.CreatedAt, &contact.UpdatedAt,
-	)
+		INSERT INTO contacts (` + contactColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT ` + conflictTarget + ` DO NOTHING
+		RETURNING ` + contactColumns
+	created, err := scanContact(r.db.conn(ctx).QueryRow(ctx, query,
+		contact.ID, contact.Name, contact.Phone, contact.Email,
+		contact.WhatsAppID, contact.InstagramID, contact.AvatarURL,
+		contact.Metadata, contact.CreatedAt, contact.UpdatedAt,
+	))
+	if err == nil {
+		return created, true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, err
+	}
+
+	existing, err := get(ctx)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
-	return contact, nil
+	return existing, false, nil
+}
+
+func (r *ContactRepository) GetByID(ctx context.Context, id string) (*types.Contact, error) {
+	query := `SELECT ` + contactColumns + ` FROM contacts WHERE id = $1`
+	return scanContact(r.db.conn(ctx).QueryRow(ctx, query, id))
 }
 
 func (r *ContactRepository) GetByWhatsAppID(ctx context.Context, waID string) (*types.Contact, error) {
-	query := `
-		SELECT id, name, phone, email, whatsapp_id, instagram_id, avatar_url, metadata, created_at, updated_at
-		FROM contacts WHERE whatsapp_id = $1
-	`
-	contact := &types.Contact{}
-	err := r.db.Pool.QueryRow(ctx, query, waID).Scan(
-		&contact.ID, &contact.Name, &contact.Phone, &contact.Email,
-		&contact.WhatsAppID, &contact.InstagramID, &contact.AvatarURL,
-		&contact.Metadata, &contact.CreatedAt, &contact.UpdatedAt,
-	)
-	if err != nil {
-		return nil, err
-	}
-	return contact, nil
+	query := `SELECT ` + contactColumns + ` FROM contacts WHERE whatsapp_id = $1`
+	return scanContact(r.db.conn(ctx).QueryRow(ctx, query, waID))
 }
 
 func (r *ContactRepository) GetByInstagramID(ctx context.Context, igID string) (*types.Contact, error) {
-	query := `
-		SELECT id, name, phone, email, whatsapp_id, instagram_id, avatar_url, metadata, created_at, updated_at
-		FROM contacts WHERE instagram_id = $1
-	`
-	contact := &types.Contact{}
-	err := r.db.Pool.QueryRow(ctx, query, igID).Scan(
-		&contact.ID, &contact.Name, &contact.Phone, &contact.Email,
-		&contact.WhatsAppID, &contact.InstagramID, &contact.AvatarURL,
-		&contact.Metadata, &contact.CreatedAt, &contact.UpdatedAt,
-	)
-	if err != nil {
-		return nil, err
-	}
-	return contact, nil
+	query := `SELECT ` + contactColumns + ` FROM contacts WHERE instagram_id = $1`
+	return scanContact(r.db.conn(ctx).QueryRow(ctx, query, igID))
 }
 
-func (r *ContactRepository) List(ctx context.Context, limit, offset int) ([]*types.Contact, error) {
+// List returns a page of contacts, newest first, along with the total number of contacts
+func (r *ContactRepository) List(ctx context.Context, filter types.ContactFilter) ([]*types.Contact, int, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.Scope != nil {
+		args = append(args, filter.Scope.UserID, filter.Scope.TeamID)
+		conditions = append(conditions, fmt.Sprintf(`EXISTS (
+			SELECT 1 FROM conversations c
+			WHERE c.contact_id = contacts.id
+			  AND (c.assignee_id::text = $%d OR (c.team_id IS NOT NULL AND c.team_id::text = $%d))
+		)`, len(args)-1, len(args)))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+	var total int
+	if err := r.db.conn(ctx).QueryRow(ctx, `SELECT COUNT(*) FROM contacts `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	if filter.After != nil {
+		args = append(args, filter.After.At, filter.After.ID)
+		conditions = append(conditions, fmt.Sprintf("(created_at, id) < ($%d, $%d::uuid)", len(args)-1, len(args)))
+	}
+	where = ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
 	query := `
-		SELECT id, name, phone, email, whatsapp_id, instagram_id, avatar_url, metadata, created_at, updated_at
+		SELECT ` + contactColumns + `
 		FROM contacts
-		ORDER BY updated_at DESC
-		LIMIT $1 OFFSET $2
-	`
-	rows, err := r.db.Pool.Query(ctx, query, limit, offset)
+		` + where + `
+		ORDER BY created_at DESC, id DESC
+		LIMIT $` + fmt.Sprint(len(args))
+	rows, err := r.db.conn(ctx).Query(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
 	var contacts []*types.Contact
 	for rows.Next() {
-		contact := &types.Contact{}
-		if err := rows.Scan(
-			&contact.ID, &contact.Name, &contact.Phone, &contact.Email,
-			&contact.WhatsAppID, &contact.InstagramID, &contact.AvatarURL,
-			&contact.Metadata, &contact.CreatedAt, &contact.UpdatedAt,
-		); err != nil {
-			return nil, err
+		contact, err := scanContact(rows)
+		if err != nil {
+			return nil, 0, err
 		}
 		contacts = append(contacts, contact)
 	}
-	return contacts, nil
+	return contacts, total, rows.Err()
 }
 
 func (r *ContactRepository) Update(ctx context.Context, contact *types.Contact) error {
@@ -114,10 +160,24 @@ func (r *ContactRepository) Update(ctx context.Context, contact *types.Contact)
 		    avatar_url = $6, metadata = $7, updated_at = $8
 		WHERE id = $9
 	`
-	_, err := r.db.Pool.Exec(ctx, query,
+	_, err := r.db.conn(ctx).Exec(ctx, query,
 		contact.Name, contact.Phone, contact.Email, contact.WhatsAppID,
 		contact.InstagramID, contact.AvatarURL, contact.Metadata,
 		time.Now(), contact.ID,
 	)
 	return err
 }
+
+// scanContact scans a row selected with contactColumns
+func scanContact(row pgx.Row) (*types.Contact, error) {
+	contact := &types.Contact{}
+	err := row.Scan(
+		&contact.ID, &contact.Name, &contact.Phone, &contact.Email,
+		&contact.WhatsAppID, &contact.InstagramID, &contact.AvatarURL,
+		&contact.Metadata, &contact.CreatedAt, &contact.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return contact, nil
+}
@@ -0,0 +1,65 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/temanbatin/omnichannel/internal/types"
+)
+
+type ConversationAssignmentRepository struct {
+	db *DB
+}
+
+func NewConversationAssignmentRepository(db *DB) *ConversationAssignmentRepository {
+	return &ConversationAssignmentRepository{db: db}
+}
+
+func (r *ConversationAssignmentRepository) Create(ctx context.Context, entry *types.ConversationAssignment) error {
+	query := `
+		INSERT INTO conversation_assignments (id, conversation_id, action, assignee_id, team_id, actor_id, created_at)
+		VALUES ($1, $2, $3, NULLIF($4, '')::uuid, NULLIF($5, '')::uuid, NULLIF($6, '')::uuid, $7)
+	`
+	_, err := r.db.conn(ctx).Exec(ctx, query,
+		entry.ID, entry.ConversationID, entry.Action,
+		entry.AssigneeID, entry.TeamID, entry.ActorID, entry.CreatedAt,
+	)
+	return err
+}
+
+// ListByConversation returns a conversation's assignment history, newest first
+func (r *ConversationAssignmentRepository) ListByConversation(ctx context.Context, conversationID string) ([]*types.ConversationAssignment, error) {
+	query := `
+		SELECT a.id, a.conversation_id, a.action,
+		       COALESCE(a.assignee_id::text, ''), COALESCE(assignee.name, ''),
+		       COALESCE(a.team_id::text, ''), COALESCE(t.name, ''),
+		       COALESCE(a.actor_id::text, ''), COALESCE(actor.name, ''),
+		       a.created_at
+		FROM conversation_assignments a
+		LEFT JOIN users assignee ON a.assignee_id = assignee.id
+		LEFT JOIN teams t ON a.team_id = t.id
+		LEFT JOIN users actor ON a.actor_id = actor.id
+		WHERE a.conversation_id = $1
+		ORDER BY a.created_at DESC
+	`
+	rows, err := r.db.conn(ctx).Query(ctx, query, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*types.ConversationAssignment
+	for rows.Next() {
+		entry := &types.ConversationAssignment{}
+		if err := rows.Scan(
+			&entry.ID, &entry.ConversationID, &entry.Action,
+			&entry.AssigneeID, &entry.AssigneeName,
+			&entry.TeamID, &entry.TeamName,
+			&entry.ActorID, &entry.ActorName,
+			&entry.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
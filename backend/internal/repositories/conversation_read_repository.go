@@ -0,0 +1,54 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/temanbatin/omnichannel/internal/types"
+)
+
+type ConversationReadRepository struct {
+	db *DB
+}
+
+func NewConversationReadRepository(db *DB) *ConversationReadRepository {
+	return &ConversationReadRepository{db: db}
+}
+
+// Upsert moves a user's read position in a conversation
+func (r *ConversationReadRepository) Upsert(ctx context.Context, read *types.ConversationRead) error {
+	query := `
+		INSERT INTO conversation_reads (conversation_id, user_id, last_read_message_id, last_read_at)
+		VALUES ($1, $2, NULLIF($3, '')::uuid, $4)
+		ON CONFLICT (conversation_id, user_id) DO UPDATE
+		SET last_read_message_id = EXCLUDED.last_read_message_id, last_read_at = EXCLUDED.last_read_at
+	`
+	_, err := r.db.conn(ctx).Exec(ctx, query, read.ConversationID, read.UserID, read.LastReadMessageID, read.LastReadAt)
+	return err
+}
+
+// ListByConversation returns every user's read position in a conversation, most recent first
+func (r *ConversationReadRepository) ListByConversation(ctx context.Context, conversationID string) ([]*types.ConversationRead, error) {
+	query := `
+		SELECT cr.conversation_id, cr.user_id, COALESCE(u.name, ''),
+		       COALESCE(cr.last_read_message_id::text, ''), cr.last_read_at
+		FROM conversation_reads cr
+		LEFT JOIN users u ON cr.user_id = u.id
+		WHERE cr.conversation_id = $1
+		ORDER BY cr.last_read_at DESC
+	`
+	rows, err := r.db.conn(ctx).Query(ctx, query, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reads []*types.ConversationRead
+	for rows.Next() {
+		read := &types.ConversationRead{}
+		if err := rows.Scan(&read.ConversationID, &read.UserID, &read.UserName, &read.LastReadMessageID, &read.LastReadAt); err != nil {
+			return nil, err
+		}
+		reads = append(reads, read)
+	}
+	return reads, rows.Err()
+}
@@ -2,8 +2,11 @@ package repositories
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/temanbatin/omnichannel/internal/types"
 )
 
@@ -15,32 +18,51 @@ func NewConversationRepository(db *DB) *ConversationRepository {
 	return &ConversationRepository{db: db}
 }
 
-func (r *ConversationRepository) Create(ctx context.Context, conv *types.Conversation) error {
+// CreateOrGet inserts conv unless the contact already has a conversation on that platform,
+// in which case the stored conversation is returned. It reports whether conv was inserted.
+func (r *ConversationRepository) CreateOrGet(ctx context.Context, conv *types.Conversation) (*types.Conversation, bool, error) {
 	query := `
-		INSERT INTO conversations (id, contact_id, platform, external_id, last_message_at, last_message_text, unread_count, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		INSERT INTO conversations (id, contact_id, platform, external_id, last_message_at, last_message_text, unread_count, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (contact_id, platform) DO NOTHING
 	`
-	_, err := r.db.Pool.Exec(ctx, query,
+	tag, err := r.db.conn(ctx).Exec(ctx, query,
 		conv.ID, conv.ContactID, conv.Platform, conv.ExternalID,
 		conv.LastMessageAt, conv.LastMessageText, conv.UnreadCount,
-		conv.CreatedAt, conv.UpdatedAt,
+		conv.Status, conv.CreatedAt, conv.UpdatedAt,
 	)
-	return err
+	if err != nil {
+		return nil, false, err
+	}
+	if tag.RowsAffected() == 1 {
+		return conv, true, nil
+	}
+
+	existing, err := r.GetByContactAndPlatform(ctx, conv.ContactID, conv.Platform)
+	if err != nil {
+		return nil, false, err
+	}
+	return existing, false, nil
 }
 
 func (r *ConversationRepository) GetByID(ctx context.Context, id string) (*types.Conversation, error) {
 	query := `
-		SELECT c.id, c.contact_id, c.platform, c.external_id, c.last_message_at, c.last_message_text, c.unread_count, c.created_at, c.updated_at,
+		SELECT c.id, c.contact_id, c.platform, c.external_id, c.last_message_at, c.last_message_text, c.unread_count,
+		       COALESCE(c.assignee_id::text, ''), COALESCE(c.team_id::text, ''), c.tags,
+		       c.last_message_direction, COALESCE(c.last_message_sender_id::text, ''), c.last_inbound_at, c.last_outbound_at,
+		       c.status, c.snoozed_until, c.resolved_at, c.created_at, c.updated_at,
 		       ct.id, ct.name, ct.phone, ct.email, ct.whatsapp_id, ct.instagram_id, ct.avatar_url
 		FROM conversations c
 		LEFT JOIN contacts ct ON c.contact_id = ct.id
 		WHERE c.id = $1
 	`
 	conv := &types.Conversation{Contact: &types.Contact{}}
-	err := r.db.Pool.QueryRow(ctx, query, id).Scan(
+	err := r.db.conn(ctx).QueryRow(ctx, query, id).Scan(
 		&conv.ID, &conv.ContactID, &conv.Platform, &conv.ExternalID,
 		&conv.LastMessageAt, &conv.LastMessageText, &conv.UnreadCount,
-		&conv.CreatedAt, &conv.UpdatedAt,
+		&conv.AssigneeID, &conv.TeamID, &conv.Tags,
+		&conv.LastMessageDirection, &conv.LastMessageSenderID, &conv.LastInboundAt, &conv.LastOutboundAt,
+		&conv.Status, &conv.SnoozedUntil, &conv.ResolvedAt, &conv.CreatedAt, &conv.UpdatedAt,
 		&conv.Contact.ID, &conv.Contact.Name, &conv.Contact.Phone,
 		&conv.Contact.Email, &conv.Contact.WhatsAppID, &conv.Contact.InstagramID,
 		&conv.Contact.AvatarURL,
@@ -48,39 +70,120 @@ func (r *ConversationRepository) GetByID(ctx context.Context, id string) (*types
 	if err != nil {
 		return nil, err
 	}
+	conv.SetServiceWindow(time.Now())
 	return conv, nil
 }
 
 func (r *ConversationRepository) GetByContactAndPlatform(ctx context.Context, contactID string, platform types.Platform) (*types.Conversation, error) {
 	query := `
-		SELECT id, contact_id, platform, external_id, last_message_at, last_message_text, unread_count, created_at, updated_at
+		SELECT id, contact_id, platform, external_id, last_message_at, last_message_text, unread_count,
+		       COALESCE(assignee_id::text, ''), COALESCE(team_id::text, ''), tags,
+		       last_message_direction, COALESCE(last_message_sender_id::text, ''), last_inbound_at, last_outbound_at,
+		       status, snoozed_until, resolved_at, created_at, updated_at
 		FROM conversations
 		WHERE contact_id = $1 AND platform = $2
 	`
 	conv := &types.Conversation{}
-	err := r.db.Pool.QueryRow(ctx, query, contactID, platform).Scan(
+	err := r.db.conn(ctx).QueryRow(ctx, query, contactID, platform).Scan(
 		&conv.ID, &conv.ContactID, &conv.Platform, &conv.ExternalID,
 		&conv.LastMessageAt, &conv.LastMessageText, &conv.UnreadCount,
-		&conv.CreatedAt, &conv.UpdatedAt,
+		&conv.AssigneeID, &conv.TeamID, &conv.Tags,
+		&conv.LastMessageDirection, &conv.LastMessageSenderID, &conv.LastInboundAt, &conv.LastOutboundAt,
+		&conv.Status, &conv.SnoozedUntil, &conv.ResolvedAt, &conv.CreatedAt, &conv.UpdatedAt,
 	)
 	if err != nil {
 		return nil, err
 	}
+	conv.SetServiceWindow(time.Now())
 	return conv, nil
 }
 
-func (r *ConversationRepository) List(ctx context.Context, limit, offset int) ([]*types.Conversation, error) {
-	query := `
-		SELECT c.id, c.contact_id, c.platform, c.external_id, c.last_message_at, c.last_message_text, c.unread_count, c.created_at, c.updated_at,
+// List returns a page of conversations ordered by latest activity, along with the total
+// number matching the filter. A non-nil filter.Scope limits the result to conversations
+// assigned to the scope's user or team.
+func (r *ConversationRepository) List(ctx context.Context, filter types.ConversationFilter) ([]*types.Conversation, int, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.Scope != nil {
+		args = append(args, filter.Scope.UserID, filter.Scope.TeamID)
+		conditions = append(conditions, fmt.Sprintf(
+			"(c.assignee_id::text = $%d OR (c.team_id IS NOT NULL AND c.team_id::text = $%d))",
+			len(args)-1, len(args),
+		))
+	}
+	if filter.AssigneeID != "" {
+		args = append(args, filter.AssigneeID)
+		conditions = append(conditions, fmt.Sprintf("c.assignee_id::text = $%d", len(args)))
+	}
+	if filter.Unassigned {
+		conditions = append(conditions, "c.assignee_id IS NULL")
+	}
+	if filter.TeamID != "" {
+		args = append(args, filter.TeamID)
+		conditions = append(conditions, fmt.Sprintf("c.team_id::text = $%d", len(args)))
+	}
+	if filter.Platform != "" {
+		args = append(args, filter.Platform)
+		conditions = append(conditions, fmt.Sprintf("c.platform = $%d", len(args)))
+	}
+	if len(filter.Statuses) > 0 {
+		statuses := make([]string, len(filter.Statuses))
+		for i, status := range filter.Statuses {
+			statuses[i] = string(status)
+		}
+		args = append(args, statuses)
+		conditions = append(conditions, fmt.Sprintf("c.status = ANY($%d)", len(args)))
+	}
+	if filter.Tag != "" {
+		args = append(args, []string{filter.Tag})
+		conditions = append(conditions, fmt.Sprintf("c.tags @> $%d", len(args)))
+	}
+	if filter.UnreadOnly {
+		conditions = append(conditions, "c.unread_count > 0")
+	}
+	if filter.Since != nil {
+		args = append(args, *filter.Since)
+		conditions = append(conditions, fmt.Sprintf("c.last_message_at >= $%d", len(args)))
+	}
+	if filter.Until != nil {
+		args = append(args, *filter.Until)
+		conditions = append(conditions, fmt.Sprintf("c.last_message_at < $%d", len(args)))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := r.db.conn(ctx).QueryRow(ctx, `SELECT COUNT(*) FROM conversations c `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	// The cursor narrows the page but not the total
+	if filter.After != nil {
+		args = append(args, filter.After.At, filter.After.ID)
+		conditions = append(conditions, fmt.Sprintf("(c.last_message_at, c.id) < ($%d, $%d::uuid)", len(args)-1, len(args)))
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	args = append(args, filter.Limit)
+	query := fmt.Sprintf(`
+		SELECT c.id, c.contact_id, c.platform, c.external_id, c.last_message_at, c.last_message_text, c.unread_count,
+		       COALESCE(c.assignee_id::text, ''), COALESCE(c.team_id::text, ''), c.tags,
+		       c.last_message_direction, COALESCE(c.last_message_sender_id::text, ''), c.last_inbound_at, c.last_outbound_at,
+		       c.status, c.snoozed_until, c.resolved_at, c.created_at, c.updated_at,
 		       ct.id, ct.name, ct.phone, ct.avatar_url
 		FROM conversations c
 		LEFT JOIN contacts ct ON c.contact_id = ct.id
-		ORDER BY c.last_message_at DESC
-		LIMIT $1 OFFSET $2
-	`
-	rows, err := r.db.Pool.Query(ctx, query, limit, offset)
+		%s
+		ORDER BY c.last_message_at DESC, c.id DESC
+		LIMIT $%d
+	`, where, len(args))
+
+	rows, err := r.db.conn(ctx).Query(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
@@ -90,29 +193,186 @@ func (r *ConversationRepository) List(ctx context.Context, limit, offset int) ([
 		if err := rows.Scan(
 			&conv.ID, &conv.ContactID, &conv.Platform, &conv.ExternalID,
 			&conv.LastMessageAt, &conv.LastMessageText, &conv.UnreadCount,
-			&conv.CreatedAt, &conv.UpdatedAt,
+			&conv.AssigneeID, &conv.TeamID, &conv.Tags,
+			&conv.LastMessageDirection, &conv.LastMessageSenderID, &conv.LastInboundAt, &conv.LastOutboundAt,
+			&conv.Status, &conv.SnoozedUntil, &conv.ResolvedAt, &conv.CreatedAt, &conv.UpdatedAt,
 			&conv.Contact.ID, &conv.Contact.Name, &conv.Contact.Phone,
 			&conv.Contact.AvatarURL,
 		); err != nil {
-			return nil, err
+			return nil, 0, err
 		}
+		conv.SetServiceWindow(time.Now())
 		conversations = append(conversations, conv)
 	}
-	return conversations, nil
+	return conversations, total, rows.Err()
+}
+
+// UpdateAssignment sets the assignee and team; empty strings clear them
+func (r *ConversationRepository) UpdateAssignment(ctx context.Context, id, assigneeID, teamID string) error {
+	query := `
+		UPDATE conversations
+		SET assignee_id = NULLIF($1, '')::uuid, team_id = NULLIF($2, '')::uuid, updated_at = $3
+		WHERE id = $4
+	`
+	tag, err := r.db.conn(ctx).Exec(ctx, query, assigneeID, teamID, time.Now(), id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
 }
 
-func (r *ConversationRepository) UpdateLastMessage(ctx context.Context, id, messageText string) error {
+// Claim assigns an unassigned conversation to userID. Returns false if someone else
+// already holds it, so two agents claiming at once cannot both win.
+func (r *ConversationRepository) Claim(ctx context.Context, id, userID string) (bool, error) {
 	query := `
-		UPDATE conversations 
-		SET last_message_at = $1, last_message_text = $2, unread_count = unread_count + 1, updated_at = $1
-		WHERE id = $3
+		UPDATE conversations
+		SET assignee_id = $1, updated_at = $2
+		WHERE id = $3 AND (assignee_id IS NULL OR assignee_id = $1)
 	`
-	_, err := r.db.Pool.Exec(ctx, query, time.Now(), messageText, id)
+	tag, err := r.db.conn(ctx).Exec(ctx, query, userID, time.Now(), id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// RouteTo assigns an unassigned conversation to an agent who is still below capacity,
+// optionally moving it to a team. Returns false if either condition no longer holds.
+// Routings to the same agent are serialized by a transaction-scoped advisory lock, taken
+// before the capacity is counted, so concurrent routings cannot both fill the last slot.
+func (r *ConversationRepository) RouteTo(ctx context.Context, id, assigneeID, teamID string, capacity int) (bool, error) {
+	routed := false
+	err := r.db.WithTx(ctx, func(ctx context.Context) error {
+		if _, err := r.db.conn(ctx).Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('route:' || $1))`, assigneeID); err != nil {
+			return err
+		}
+
+		// A separate statement, so its snapshot includes routings committed while waiting
+		query := `
+			UPDATE conversations
+			SET assignee_id = $1, team_id = COALESCE(NULLIF($2, '')::uuid, team_id), updated_at = $3
+			WHERE id = $4 AND assignee_id IS NULL
+			  AND (SELECT COUNT(*) FROM conversations WHERE assignee_id = $1 AND status <> 'resolved') < $5
+		`
+		tag, err := r.db.conn(ctx).Exec(ctx, query, assigneeID, teamID, time.Now(), id, capacity)
+		if err != nil {
+			return err
+		}
+		routed = tag.RowsAffected() == 1
+		return nil
+	})
+	return routed, err
+}
+
+// UpdateStatus moves a conversation to a new status. snoozedUntil is only kept for snoozed.
+func (r *ConversationRepository) UpdateStatus(ctx context.Context, id string, status types.ConversationStatus, snoozedUntil *time.Time) error {
+	query := `
+		UPDATE conversations
+		SET status = $1,
+		    snoozed_until = CASE WHEN $1 = 'snoozed' THEN $2::timestamptz END,
+		    resolved_at = CASE WHEN $1 = 'resolved' THEN $3::timestamptz END,
+		    updated_at = $3
+		WHERE id = $4
+	`
+	tag, err := r.db.conn(ctx).Exec(ctx, query, status, snoozedUntil, time.Now(), id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// Reopen sets a pending, snoozed or resolved conversation back to open.
+// Returns false if it was already open.
+func (r *ConversationRepository) Reopen(ctx context.Context, id string) (bool, error) {
+	query := `
+		UPDATE conversations
+		SET status = 'open', snoozed_until = NULL, resolved_at = NULL, updated_at = $1
+		WHERE id = $2 AND status <> 'open'
+	`
+	tag, err := r.db.conn(ctx).Exec(ctx, query, time.Now(), id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// ReopenExpiredSnoozes reopens up to limit conversations whose snooze has ended and
+// returns their IDs. Safe to run from several replicas at once.
+func (r *ConversationRepository) ReopenExpiredSnoozes(ctx context.Context, limit int) ([]string, error) {
+	query := `
+		UPDATE conversations
+		SET status = 'open', snoozed_until = NULL, updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM conversations
+			WHERE status = 'snoozed' AND snoozed_until <= NOW()
+			ORDER BY snoozed_until
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id
+	`
+	rows, err := r.db.conn(ctx).Query(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// UpdateTeam moves a conversation into a team's queue
+func (r *ConversationRepository) UpdateTeam(ctx context.Context, id, teamID string) error {
+	query := `UPDATE conversations SET team_id = NULLIF($1, '')::uuid, updated_at = $2 WHERE id = $3`
+	_, err := r.db.conn(ctx).Exec(ctx, query, teamID, time.Now(), id)
 	return err
 }
 
-func (r *ConversationRepository) MarkAsRead(ctx context.Context, id string) error {
-	query := `UPDATE conversations SET unread_count = 0, updated_at = $1 WHERE id = $2`
-	_, err := r.db.Pool.Exec(ctx, query, time.Now(), id)
+func (r *ConversationRepository) UpdateTags(ctx context.Context, id string, tags []string) error {
+	query := `UPDATE conversations SET tags = $1, updated_at = $2 WHERE id = $3`
+	tag, err := r.db.conn(ctx).Exec(ctx, query, tags, time.Now(), id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// Lock holds the conversation's row until the surrounding transaction ends, so a concurrent
+// Append waits to update its summary
+func (r *ConversationRepository) Lock(ctx context.Context, id string) error {
+	_, err := r.db.conn(ctx).Exec(ctx, `SELECT 1 FROM conversations WHERE id = $1 FOR UPDATE`, id)
 	return err
 }
+
+// MarkAsRead recounts unread_count from the inbound messages that are still unread, so a
+// message that arrived after they were acknowledged stays counted. It returns the new count.
+func (r *ConversationRepository) MarkAsRead(ctx context.Context, id string) (int, error) {
+	query := `
+		UPDATE conversations
+		SET unread_count = (
+			SELECT COUNT(*) FROM messages
+			WHERE conversation_id = $2 AND direction = 'inbound' AND status <> 'read'
+		), updated_at = $1
+		WHERE id = $2
+		RETURNING unread_count
+	`
+	var unread int
+	err := r.db.conn(ctx).QueryRow(ctx, query, time.Now(), id).Scan(&unread)
+	return unread, err
+}
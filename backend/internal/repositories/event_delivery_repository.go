@@ -0,0 +1,156 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/temanbatin/omnichannel/internal/types"
+)
+
+const eventDeliveryColumns = `id, event_id, event_type, target, COALESCE(subscription_id::text, ''), url, payload, status, attempts, next_attempt_at,
+		response_status, last_error, delivered_at, created_at, updated_at`
+
+type EventDeliveryRepository struct {
+	db *DB
+}
+
+func NewEventDeliveryRepository(db *DB) *EventDeliveryRepository {
+	return &EventDeliveryRepository{db: db}
+}
+
+func (r *EventDeliveryRepository) Create(ctx context.Context, delivery *types.EventDelivery) error {
+	query := `
+		INSERT INTO event_deliveries (id, event_id, event_type, target, subscription_id, url, payload, status, next_attempt_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, '')::uuid, $6, $7, $8, $9, $10, $11)
+	`
+	_, err := r.db.conn(ctx).Exec(ctx, query,
+		delivery.ID, delivery.EventID, delivery.EventType, delivery.Target, delivery.SubscriptionID, delivery.URL,
+		delivery.Payload, delivery.Status, delivery.NextAttemptAt,
+		delivery.CreatedAt, delivery.UpdatedAt,
+	)
+	return err
+}
+
+// List returns deliveries matching the filter, newest first, along with the total match count
+func (r *EventDeliveryRepository) List(ctx context.Context, filter types.EventDeliveryFilter) ([]*types.EventDelivery, int, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.Target != "" {
+		args = append(args, filter.Target)
+		conditions = append(conditions, fmt.Sprintf("target = $%d", len(args)))
+	}
+	if filter.SubscriptionID != "" {
+		args = append(args, filter.SubscriptionID)
+		conditions = append(conditions, fmt.Sprintf("subscription_id = $%d", len(args)))
+	}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if filter.EventType != "" {
+		args = append(args, filter.EventType)
+		conditions = append(conditions, fmt.Sprintf("event_type = $%d", len(args)))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := r.db.conn(ctx).QueryRow(ctx, `SELECT COUNT(*) FROM event_deliveries `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, filter.Limit, filter.Offset)
+	query := fmt.Sprintf(`
+		SELECT %s FROM event_deliveries
+		%s
+		ORDER BY created_at DESC
+		LIMIT $%d OFFSET $%d
+	`, eventDeliveryColumns, where, len(args)-1, len(args))
+
+	rows, err := r.db.conn(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var deliveries []*types.EventDelivery
+	for rows.Next() {
+		delivery, err := scanEventDelivery(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, total, rows.Err()
+}
+
+// ClaimNext locks the oldest due delivery and marks it as sending.
+// Returns pgx.ErrNoRows when nothing is due.
+func (r *EventDeliveryRepository) ClaimNext(ctx context.Context, lockTimeout time.Duration) (*types.EventDelivery, error) {
+	query := `
+		UPDATE event_deliveries
+		SET status = 'sending', attempts = attempts + 1, locked_at = NOW(), updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM event_deliveries
+			WHERE (status = 'pending' AND next_attempt_at <= NOW())
+			   OR (status = 'sending' AND locked_at < NOW() - make_interval(secs => $1))
+			ORDER BY next_attempt_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + eventDeliveryColumns
+	return scanEventDelivery(r.db.conn(ctx).QueryRow(ctx, query, lockTimeout.Seconds()))
+}
+
+func (r *EventDeliveryRepository) MarkDelivered(ctx context.Context, id string, responseStatus int) error {
+	query := `
+		UPDATE event_deliveries
+		SET status = 'delivered', locked_at = NULL, response_status = $1, last_error = '', delivered_at = $2, updated_at = $2
+		WHERE id = $3
+	`
+	_, err := r.db.conn(ctx).Exec(ctx, query, responseStatus, time.Now(), id)
+	return err
+}
+
+// MarkRetry records a failed attempt and schedules the next one
+func (r *EventDeliveryRepository) MarkRetry(ctx context.Context, id string, responseStatus int, lastError string, nextAttemptAt time.Time) error {
+	query := `
+		UPDATE event_deliveries
+		SET status = 'pending', locked_at = NULL, response_status = $1, last_error = $2, next_attempt_at = $3, updated_at = $4
+		WHERE id = $5
+	`
+	_, err := r.db.conn(ctx).Exec(ctx, query, responseStatus, lastError, nextAttemptAt, time.Now(), id)
+	return err
+}
+
+// MarkFailed gives up on a delivery
+func (r *EventDeliveryRepository) MarkFailed(ctx context.Context, id string, responseStatus int, lastError string) error {
+	query := `
+		UPDATE event_deliveries
+		SET status = 'failed', locked_at = NULL, response_status = $1, last_error = $2, updated_at = $3
+		WHERE id = $4
+	`
+	_, err := r.db.conn(ctx).Exec(ctx, query, responseStatus, lastError, time.Now(), id)
+	return err
+}
+
+// scanEventDelivery scans a row selected with eventDeliveryColumns
+func scanEventDelivery(row pgx.Row) (*types.EventDelivery, error) {
+	delivery := &types.EventDelivery{}
+	err := row.Scan(
+		&delivery.ID, &delivery.EventID, &delivery.EventType, &delivery.Target, &delivery.SubscriptionID, &delivery.URL,
+		&delivery.Payload, &delivery.Status, &delivery.Attempts, &delivery.NextAttemptAt,
+		&delivery.ResponseStatus, &delivery.LastError, &delivery.DeliveredAt,
+		&delivery.CreatedAt, &delivery.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
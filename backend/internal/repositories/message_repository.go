@@ -2,11 +2,25 @@ package repositories
 
 import (
 	"context"
+	"errors"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/temanbatin/omnichannel/internal/types"
 )
 
+const messageColumns = `id, conversation_id, platform, direction, COALESCE(sender_id::text, '') AS sender_id,
+		content, content_type, status, external_id,
+		media_id, media_url, media_mime_type, media_filename, media_sha256, media_size,
+		error_code, error_title, created_at, updated_at`
+
+const messageInsert = `
+	INSERT INTO messages (id, conversation_id, platform, direction, sender_id, content, content_type, status, external_id,
+		media_id, media_url, media_mime_type, media_filename, media_sha256, media_size,
+		error_code, error_title, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, NULLIF($5, '')::uuid, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
+`
+
 type MessageRepository struct {
 	db *DB
 }
@@ -15,45 +29,162 @@ func NewMessageRepository(db *DB) *MessageRepository {
 	return &MessageRepository{db: db}
 }
 
+// Create stores a message without touching its conversation's summary, e.g. a send that failed
 func (r *MessageRepository) Create(ctx context.Context, msg *types.Message) error {
-	query := `
-		INSERT INTO messages (id, conversation_id, platform, direction, content, content_type, status, external_id, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-	`
-	_, err := r.db.Pool.Exec(ctx, query,
-		msg.ID, msg.ConversationID, msg.Platform, msg.Direction,
-		msg.Content, msg.ContentType, msg.Status, msg.ExternalID,
-		msg.CreatedAt, msg.UpdatedAt,
-	)
+	_, err := r.db.conn(ctx).Exec(ctx, messageInsert, messageInsertArgs(msg)...)
 	return err
 }
 
+// Append stores a message and updates its conversation's summary in one transaction, so the
+// summary never drifts from the messages table. Only inbound messages count as unread.
+// A message older than the conversation's newest (e.g. a replayed webhook) never moves
+// the summary or last_inbound_at backwards.
+// A message whose (platform, external_id) is already stored is skipped; Append reports
+// whether a new row was inserted.
+func (r *MessageRepository) Append(ctx context.Context, msg *types.Message, preview string) (bool, error) {
+	inserted := false
+	err := r.db.WithTx(ctx, func(ctx context.Context) error {
+		tag, err := r.db.conn(ctx).Exec(ctx, messageInsert+`ON CONFLICT (platform, external_id) WHERE external_id <> '' DO NOTHING`, messageInsertArgs(msg)...)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return nil
+		}
+		inserted = true
+
+		query := `
+			UPDATE conversations
+			SET last_message_at = CASE WHEN older.stale THEN last_message_at ELSE $1 END,
+			    last_message_text = CASE WHEN older.stale THEN last_message_text ELSE $2 END,
+			    last_message_direction = CASE WHEN older.stale THEN last_message_direction ELSE $3 END,
+			    last_message_sender_id = CASE WHEN older.stale THEN last_message_sender_id ELSE NULLIF($4, '')::uuid END,
+			    unread_count = unread_count + CASE WHEN $3::text = 'inbound' THEN 1 ELSE 0 END,
+			    last_inbound_at = CASE WHEN $3::text = 'inbound' THEN GREATEST(last_inbound_at, $1) ELSE last_inbound_at END,
+			    last_outbound_at = CASE WHEN $3::text = 'outbound' THEN GREATEST(last_outbound_at, $1) ELSE last_outbound_at END,
+			    updated_at = NOW()
+			FROM (
+				SELECT EXISTS(SELECT 1 FROM messages WHERE conversation_id = $5 AND created_at > $1) AS stale
+			) older
+			WHERE conversations.id = $5
+		`
+		_, err = r.db.conn(ctx).Exec(ctx, query, msg.CreatedAt, preview, string(msg.Direction), msg.SenderID, msg.ConversationID)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	return inserted, nil
+}
+
+// ExistsByExternalID reports whether a message with the given Meta ID is stored for the platform
+func (r *MessageRepository) ExistsByExternalID(ctx context.Context, platform types.Platform, externalID string) (bool, error) {
+	if externalID == "" {
+		return false, nil
+	}
+	query := `SELECT EXISTS(SELECT 1 FROM messages WHERE platform = $1 AND external_id = $2)`
+	var exists bool
+	err := r.db.conn(ctx).QueryRow(ctx, query, platform, externalID).Scan(&exists)
+	return exists, err
+}
+
 func (r *MessageRepository) GetByID(ctx context.Context, id string) (*types.Message, error) {
+	query := `SELECT ` + messageColumns + ` FROM messages WHERE id = $1`
+	return scanMessage(r.db.conn(ctx).QueryRow(ctx, query, id))
+}
+
+func (r *MessageRepository) GetByExternalID(ctx context.Context, platform types.Platform, externalID string) (*types.Message, error) {
+	query := `SELECT ` + messageColumns + ` FROM messages WHERE platform = $1 AND external_id = $2`
+	return scanMessage(r.db.conn(ctx).QueryRow(ctx, query, platform, externalID))
+}
+
+// ListByConversation returns a page of a conversation's messages, newest first
+func (r *MessageRepository) ListByConversation(ctx context.Context, filter types.MessageFilter) ([]*types.Message, error) {
+	args := []interface{}{filter.ConversationID, filter.Limit}
+	after := ""
+	if filter.After != nil {
+		args = append(args, filter.After.At, filter.After.ID)
+		after = "AND (created_at, id) < ($3, $4::uuid)"
+	}
 	query := `
-		SELECT id, conversation_id, platform, direction, content, content_type, status, external_id, created_at, updated_at
-		FROM messages WHERE id = $1
+		SELECT ` + messageColumns + `
+		FROM messages
+		WHERE conversation_id = $1 ` + after + `
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
 	`
-	msg := &types.Message{}
-	err := r.db.Pool.QueryRow(ctx, query, id).Scan(
-		&msg.ID, &msg.ConversationID, &msg.Platform, &msg.Direction,
-		&msg.Content, &msg.ContentType, &msg.Status, &msg.ExternalID,
-		&msg.CreatedAt, &msg.UpdatedAt,
-	)
+	rows, err := r.db.conn(ctx).Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
-	return msg, nil
+	defer rows.Close()
+
+	var messages []*types.Message
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
+
+// CountByConversation returns how many messages a conversation has
+func (r *MessageRepository) CountByConversation(ctx context.Context, conversationID string) (int, error) {
+	var count int
+	err := r.db.conn(ctx).QueryRow(ctx, `SELECT COUNT(*) FROM messages WHERE conversation_id = $1`, conversationID).Scan(&count)
+	return count, err
+}
+
+// TransitionStatus moves a message to status if types.MessageStatus.CanTransitionTo allows it
+// from the status stored at that moment, so concurrent callbacks can never move it backwards.
+// The error is only recorded for failed. It returns the status the message moved from and
+// false if it was left unchanged.
+func (r *MessageRepository) TransitionStatus(ctx context.Context, id string, status types.MessageStatus, errorCode int, errorTitle string) (types.MessageStatus, bool, error) {
+	sources := types.TransitionSources(status)
+	if len(sources) == 0 {
+		return "", false, nil
+	}
+	from := make([]string, len(sources))
+	for i, s := range sources {
+		from[i] = string(s)
+	}
+
+	query := `
+		UPDATE messages m
+		SET status = $2::text,
+		    error_code = CASE WHEN $2::text = 'failed' THEN $4 ELSE m.error_code END,
+		    error_title = CASE WHEN $2::text = 'failed' THEN $5 ELSE m.error_title END,
+		    updated_at = $6
+		FROM (SELECT id, status FROM messages WHERE id = $1 FOR UPDATE) previous
+		WHERE m.id = previous.id AND previous.status = ANY($3)
+		RETURNING previous.status
+	`
+	var previous types.MessageStatus
+	err := r.db.conn(ctx).QueryRow(ctx, query, id, string(status), from, errorCode, errorTitle, time.Now()).Scan(&previous)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return previous, true, nil
 }
 
-func (r *MessageRepository) ListByConversation(ctx context.Context, conversationID string, limit, offset int) ([]*types.Message, error) {
+// MarkInboundRead marks a conversation's unread inbound messages up to upTo as read
+// and returns them, oldest first
+func (r *MessageRepository) MarkInboundRead(ctx context.Context, conversationID string, upTo time.Time) ([]*types.Message, error) {
 	query := `
-		SELECT id, conversation_id, platform, direction, content, content_type, status, external_id, created_at, updated_at
-		FROM messages 
-		WHERE conversation_id = $1
-		ORDER BY created_at DESC
-		LIMIT $2 OFFSET $3
+		WITH acknowledged AS (
+			UPDATE messages
+			SET status = 'read', updated_at = NOW()
+			WHERE conversation_id = $1 AND direction = 'inbound' AND status <> 'read' AND created_at <= $2
+			RETURNING ` + messageColumns + `
+		)
+		SELECT ` + messageColumns + ` FROM acknowledged ORDER BY created_at
 	`
-	rows, err := r.db.Pool.Query(ctx, query, conversationID, limit, offset)
+	rows, err := r.db.conn(ctx).Query(ctx, query, conversationID, upTo)
 	if err != nil {
 		return nil, err
 	}
@@ -61,21 +192,43 @@ func (r *MessageRepository) ListByConversation(ctx context.Context, conversation
 
 	var messages []*types.Message
 	for rows.Next() {
-		msg := &types.Message{}
-		if err := rows.Scan(
-			&msg.ID, &msg.ConversationID, &msg.Platform, &msg.Direction,
-			&msg.Content, &msg.ContentType, &msg.Status, &msg.ExternalID,
-			&msg.CreatedAt, &msg.UpdatedAt,
-		); err != nil {
+		msg, err := scanMessage(rows)
+		if err != nil {
 			return nil, err
 		}
 		messages = append(messages, msg)
 	}
-	return messages, nil
+	return messages, rows.Err()
 }
 
-func (r *MessageRepository) UpdateStatus(ctx context.Context, id string, status types.MessageStatus) error {
-	query := `UPDATE messages SET status = $1, updated_at = $2 WHERE id = $3`
-	_, err := r.db.Pool.Exec(ctx, query, status, time.Now(), id)
-	return err
+func messageInsertArgs(msg *types.Message) []interface{} {
+	att := msg.Attachment
+	if att == nil {
+		att = &types.Attachment{}
+	}
+	return []interface{}{
+		msg.ID, msg.ConversationID, msg.Platform, msg.Direction, msg.SenderID,
+		msg.Content, msg.ContentType, msg.Status, msg.ExternalID,
+		att.MediaID, att.URL, att.MimeType, att.Filename, att.SHA256, att.Size,
+		msg.ErrorCode, msg.ErrorTitle, msg.CreatedAt, msg.UpdatedAt,
+	}
+}
+
+// scanMessage scans a row selected with messageColumns
+func scanMessage(row pgx.Row) (*types.Message, error) {
+	msg := &types.Message{}
+	att := &types.Attachment{}
+	err := row.Scan(
+		&msg.ID, &msg.ConversationID, &msg.Platform, &msg.Direction, &msg.SenderID,
+		&msg.Content, &msg.ContentType, &msg.Status, &msg.ExternalID,
+		&att.MediaID, &att.URL, &att.MimeType, &att.Filename, &att.SHA256, &att.Size,
+		&msg.ErrorCode, &msg.ErrorTitle, &msg.CreatedAt, &msg.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if att.MediaID != "" || att.URL != "" {
+		msg.Attachment = att
+	}
+	return msg, nil
 }
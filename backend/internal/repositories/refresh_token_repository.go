@@ -0,0 +1,46 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type RefreshTokenRepository struct {
+	db *DB
+}
+
+func NewRefreshTokenRepository(db *DB) *RefreshTokenRepository {
+	return &RefreshTokenRepository{db: db}
+}
+
+// Create stores the hash of a newly issued refresh token
+func (r *RefreshTokenRepository) Create(ctx context.Context, userID, tokenHash string, expiresAt time.Time) error {
+	query := `
+		INSERT INTO refresh_tokens (id, user_id, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	_, err := r.db.conn(ctx).Exec(ctx, query, uuid.New().String(), userID, tokenHash, expiresAt, time.Now())
+	return err
+}
+
+// Consume revokes an active refresh token and returns its user ID.
+// Returns pgx.ErrNoRows if the token is unknown, expired or already used.
+func (r *RefreshTokenRepository) Consume(ctx context.Context, tokenHash string) (string, error) {
+	query := `
+		UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE token_hash = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		RETURNING user_id
+	`
+	var userID string
+	err := r.db.conn(ctx).QueryRow(ctx, query, tokenHash).Scan(&userID)
+	return userID, err
+}
+
+// RevokeAllForUser revokes every active refresh token of a user
+func (r *RefreshTokenRepository) RevokeAllForUser(ctx context.Context, userID string) error {
+	query := `UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`
+	_, err := r.db.conn(ctx).Exec(ctx, query, userID)
+	return err
+}
@@ -0,0 +1,108 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/temanbatin/omnichannel/internal/types"
+)
+
+const routingRuleColumns = `id, name, priority, platform, tag, COALESCE(team_id::text, ''), skill, strategy, is_active, created_at, updated_at`
+
+type RoutingRuleRepository struct {
+	db *DB
+}
+
+func NewRoutingRuleRepository(db *DB) *RoutingRuleRepository {
+	return &RoutingRuleRepository{db: db}
+}
+
+func (r *RoutingRuleRepository) Create(ctx context.Context, rule *types.RoutingRule) error {
+	query := `
+		INSERT INTO routing_rules (id, name, priority, platform, tag, team_id, skill, strategy, is_active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, '')::uuid, $7, $8, $9, $10, $11)
+	`
+	_, err := r.db.conn(ctx).Exec(ctx, query,
+		rule.ID, rule.Name, rule.Priority, rule.Platform, rule.Tag, rule.TeamID,
+		rule.Skill, rule.Strategy, rule.IsActive, rule.CreatedAt, rule.UpdatedAt,
+	)
+	return err
+}
+
+func (r *RoutingRuleRepository) GetByID(ctx context.Context, id string) (*types.RoutingRule, error) {
+	query := `SELECT ` + routingRuleColumns + ` FROM routing_rules WHERE id = $1`
+	return scanRoutingRule(r.db.conn(ctx).QueryRow(ctx, query, id))
+}
+
+// List returns all rules in evaluation order
+func (r *RoutingRuleRepository) List(ctx context.Context) ([]*types.RoutingRule, error) {
+	return r.query(ctx, `SELECT `+routingRuleColumns+` FROM routing_rules ORDER BY priority, created_at`)
+}
+
+// ListActive returns the active rules in evaluation order
+func (r *RoutingRuleRepository) ListActive(ctx context.Context) ([]*types.RoutingRule, error) {
+	return r.query(ctx, `SELECT `+routingRuleColumns+` FROM routing_rules WHERE is_active ORDER BY priority, created_at`)
+}
+
+func (r *RoutingRuleRepository) Update(ctx context.Context, rule *types.RoutingRule) error {
+	query := `
+		UPDATE routing_rules
+		SET name = $1, priority = $2, platform = $3, tag = $4, team_id = NULLIF($5, '')::uuid,
+		    skill = $6, strategy = $7, is_active = $8, updated_at = $9
+		WHERE id = $10
+	`
+	tag, err := r.db.conn(ctx).Exec(ctx, query,
+		rule.Name, rule.Priority, rule.Platform, rule.Tag, rule.TeamID,
+		rule.Skill, rule.Strategy, rule.IsActive, time.Now(), rule.ID,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func (r *RoutingRuleRepository) Delete(ctx context.Context, id string) error {
+	tag, err := r.db.conn(ctx).Exec(ctx, `DELETE FROM routing_rules WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func (r *RoutingRuleRepository) query(ctx context.Context, query string, args ...interface{}) ([]*types.RoutingRule, error) {
+	rows, err := r.db.conn(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []*types.RoutingRule
+	for rows.Next() {
+		rule, err := scanRoutingRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// scanRoutingRule scans a row selected with routingRuleColumns
+func scanRoutingRule(row pgx.Row) (*types.RoutingRule, error) {
+	rule := &types.RoutingRule{}
+	err := row.Scan(
+		&rule.ID, &rule.Name, &rule.Priority, &rule.Platform, &rule.Tag, &rule.TeamID,
+		&rule.Skill, &rule.Strategy, &rule.IsActive, &rule.CreatedAt, &rule.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
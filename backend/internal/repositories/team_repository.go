@@ -0,0 +1,50 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/temanbatin/omnichannel/internal/types"
+)
+
+type TeamRepository struct {
+	db *DB
+}
+
+func NewTeamRepository(db *DB) *TeamRepository {
+	return &TeamRepository{db: db}
+}
+
+func (r *TeamRepository) Create(ctx context.Context, team *types.Team) error {
+	query := `INSERT INTO teams (id, name, created_at, updated_at) VALUES ($1, $2, $3, $4)`
+	_, err := r.db.conn(ctx).Exec(ctx, query, team.ID, team.Name, team.CreatedAt, team.UpdatedAt)
+	return err
+}
+
+func (r *TeamRepository) GetByID(ctx context.Context, id string) (*types.Team, error) {
+	query := `SELECT id, name, created_at, updated_at FROM teams WHERE id = $1`
+	team := &types.Team{}
+	err := r.db.conn(ctx).QueryRow(ctx, query, id).Scan(&team.ID, &team.Name, &team.CreatedAt, &team.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return team, nil
+}
+
+func (r *TeamRepository) List(ctx context.Context) ([]*types.Team, error) {
+	query := `SELECT id, name, created_at, updated_at FROM teams ORDER BY name`
+	rows, err := r.db.conn(ctx).Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var teams []*types.Team
+	for rows.Next() {
+		team := &types.Team{}
+		if err := rows.Scan(&team.ID, &team.Name, &team.CreatedAt, &team.UpdatedAt); err != nil {
+			return nil, err
+		}
+		teams = append(teams, team)
+	}
+	return teams, nil
+}
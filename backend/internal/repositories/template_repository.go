@@ -0,0 +1,140 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/temanbatin/omnichannel/internal/types"
+)
+
+const templateColumns = `id, platform, name, COALESCE(language, ''), COALESCE(category, ''), COALESCE(status, 'pending'),
+		content, components, COALESCE(external_id, ''), rejected_reason, synced_at, created_at, updated_at`
+
+type TemplateRepository struct {
+	db *DB
+}
+
+func NewTemplateRepository(db *DB) *TemplateRepository {
+	return &TemplateRepository{db: db}
+}
+
+func (r *TemplateRepository) Create(ctx context.Context, t *types.Template) error {
+	query := `
+		INSERT INTO templates (id, platform, name, language, category, status, content, components, external_id, rejected_reason, synced_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	`
+	_, err := r.db.conn(ctx).Exec(ctx, query,
+		t.ID, t.Platform, t.Name, t.Language, t.Category, t.Status, t.Content, t.Components,
+		t.ExternalID, t.RejectedReason, t.SyncedAt, t.CreatedAt, t.UpdatedAt,
+	)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("template %w", ErrDuplicate)
+	}
+	return err
+}
+
+func (r *TemplateRepository) GetByID(ctx context.Context, id string) (*types.Template, error) {
+	query := `SELECT ` + templateColumns + ` FROM templates WHERE id = $1`
+	return scanTemplate(r.db.conn(ctx).QueryRow(ctx, query, id))
+}
+
+func (r *TemplateRepository) GetByNameAndLanguage(ctx context.Context, name, language string) (*types.Template, error) {
+	query := `SELECT ` + templateColumns + ` FROM templates WHERE name = $1 AND language = $2`
+	return scanTemplate(r.db.conn(ctx).QueryRow(ctx, query, name, language))
+}
+
+// List returns templates ordered by name, optionally only those with the given status
+func (r *TemplateRepository) List(ctx context.Context, status types.TemplateStatus) ([]*types.Template, error) {
+	query := `
+		SELECT ` + templateColumns + ` FROM templates
+		WHERE $1 = '' OR status = $1
+		ORDER BY name, language
+	`
+	rows, err := r.db.conn(ctx).Query(ctx, query, string(status))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var templates []*types.Template
+	for rows.Next() {
+		t, err := scanTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	return templates, rows.Err()
+}
+
+// Upsert stores a template pulled from the business account, matched by name and language
+func (r *TemplateRepository) Upsert(ctx context.Context, t *types.Template) error {
+	query := `
+		INSERT INTO templates (id, platform, name, language, category, status, content, components, external_id, rejected_reason, synced_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		ON CONFLICT (name, language) DO UPDATE
+		SET category = EXCLUDED.category, status = EXCLUDED.status, content = EXCLUDED.content,
+		    components = EXCLUDED.components, external_id = EXCLUDED.external_id,
+		    rejected_reason = EXCLUDED.rejected_reason, synced_at = EXCLUDED.synced_at, updated_at = EXCLUDED.updated_at
+	`
+	_, err := r.db.conn(ctx).Exec(ctx, query,
+		t.ID, t.Platform, t.Name, t.Language, t.Category, t.Status, t.Content, t.Components,
+		t.ExternalID, t.RejectedReason, t.SyncedAt, t.CreatedAt, t.UpdatedAt,
+	)
+	return err
+}
+
+// MarkDeletedUnless marks submitted templates that a sync starting at syncedAt did not see as deleted.
+// They are kept rather than removed so broadcasts that used them keep their history.
+func (r *TemplateRepository) MarkDeletedUnless(ctx context.Context, syncedAt time.Time) (int, error) {
+	query := `
+		UPDATE templates
+		SET status = 'deleted', updated_at = NOW()
+		WHERE platform = 'whatsapp' AND COALESCE(external_id, '') <> '' AND status <> 'deleted'
+		  AND (synced_at IS NULL OR synced_at < $1)
+	`
+	tag, err := r.db.conn(ctx).Exec(ctx, query, syncedAt)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// Delete removes a template, or marks it deleted while broadcasts still reference it
+func (r *TemplateRepository) Delete(ctx context.Context, id string) error {
+	tag, err := r.db.conn(ctx).Exec(ctx, `
+		DELETE FROM templates
+		WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM broadcasts WHERE template_id = $1)
+	`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 1 {
+		return nil
+	}
+
+	tag, err = r.db.conn(ctx).Exec(ctx, `UPDATE templates SET status = 'deleted', updated_at = NOW() WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// scanTemplate scans a row selected with templateColumns
+func scanTemplate(row pgx.Row) (*types.Template, error) {
+	t := &types.Template{}
+	err := row.Scan(
+		&t.ID, &t.Platform, &t.Name, &t.Language, &t.Category, &t.Status,
+		&t.Content, &t.Components, &t.ExternalID, &t.RejectedReason, &t.SyncedAt,
+		&t.CreatedAt, &t.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
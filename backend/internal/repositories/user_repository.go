@@ -0,0 +1,158 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/temanbatin/omnichannel/internal/types"
+)
+
+const userColumns = `id, email, name, role, COALESCE(team_id::text, ''), password_hash, is_active,
+		availability, max_concurrent, skills, last_login_at, created_at, updated_at`
+
+type UserRepository struct {
+	db *DB
+}
+
+func NewUserRepository(db *DB) *UserRepository {
+	return &UserRepository{db: db}
+}
+
+func (r *UserRepository) Create(ctx context.Context, user *types.User) error {
+	query := `
+		INSERT INTO users (id, email, name, role, team_id, password_hash, is_active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, '')::uuid, $6, $7, $8, $9)
+	`
+	_, err := r.db.conn(ctx).Exec(ctx, query,
+		user.ID, user.Email, user.Name, user.Role, user.TeamID,
+		user.PasswordHash, user.IsActive, user.CreatedAt, user.UpdatedAt,
+	)
+	return err
+}
+
+func (r *UserRepository) GetByID(ctx context.Context, id string) (*types.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
+	return scanUser(r.db.conn(ctx).QueryRow(ctx, query, id))
+}
+
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*types.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE LOWER(email) = LOWER($1)`
+	return scanUser(r.db.conn(ctx).QueryRow(ctx, query, email))
+}
+
+func (r *UserRepository) List(ctx context.Context) ([]*types.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users ORDER BY name`
+	rows, err := r.db.conn(ctx).Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*types.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+func (r *UserRepository) Update(ctx context.Context, user *types.User) error {
+	query := `
+		UPDATE users
+		SET name = $1, role = $2, team_id = NULLIF($3, '')::uuid, password_hash = $4, is_active = $5,
+		    max_concurrent = $6, skills = $7, updated_at = $8
+		WHERE id = $9
+	`
+	skills := user.Skills
+	if skills == nil {
+		skills = []string{}
+	}
+	_, err := r.db.conn(ctx).Exec(ctx, query,
+		user.Name, user.Role, user.TeamID, user.PasswordHash, user.IsActive,
+		user.MaxConcurrent, skills, time.Now(), user.ID,
+	)
+	return err
+}
+
+func (r *UserRepository) UpdateAvailability(ctx context.Context, id string, availability types.Availability) error {
+	query := `UPDATE users SET availability = $1, updated_at = $2 WHERE id = $3`
+	_, err := r.db.conn(ctx).Exec(ctx, query, availability, time.Now(), id)
+	return err
+}
+
+// ListRoutingCandidates returns active, online agents below their concurrent cap, optionally
+// limited to a team and a skill, in the order the strategy prefers them
+func (r *UserRepository) ListRoutingCandidates(ctx context.Context, teamID, skill string, strategy types.RoutingStrategy, defaultCap, limit int) ([]*types.RoutingCandidate, error) {
+	order := "u.last_routed_at NULLS FIRST, u.id"
+	if strategy == types.RoutingLeastOpen {
+		order = "load.open_count, u.last_routed_at NULLS FIRST, u.id"
+	}
+
+	query := `
+		SELECT u.id, load.open_count,
+		       CASE WHEN u.max_concurrent > 0 THEN u.max_concurrent ELSE $3 END AS capacity
+		FROM users u
+		CROSS JOIN LATERAL (
+			SELECT COUNT(*)::int AS open_count FROM conversations c
+			WHERE c.assignee_id = u.id AND c.status <> 'resolved'
+		) load
+		WHERE u.is_active AND u.availability = 'online'
+		  AND ($1 = '' OR u.team_id::text = $1)
+		  AND ($2 = '' OR $2 = ANY(u.skills))
+		  AND load.open_count < CASE WHEN u.max_concurrent > 0 THEN u.max_concurrent ELSE $3 END
+		ORDER BY ` + order + `
+		LIMIT $4
+	`
+	rows, err := r.db.conn(ctx).Query(ctx, query, teamID, skill, defaultCap, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candidates []*types.RoutingCandidate
+	for rows.Next() {
+		c := &types.RoutingCandidate{}
+		if err := rows.Scan(&c.UserID, &c.OpenCount, &c.Capacity); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, rows.Err()
+}
+
+// MarkRouted moves an agent to the back of the round-robin order
+func (r *UserRepository) MarkRouted(ctx context.Context, id string) error {
+	_, err := r.db.conn(ctx).Exec(ctx, `UPDATE users SET last_routed_at = $1 WHERE id = $2`, time.Now(), id)
+	return err
+}
+
+func (r *UserRepository) Count(ctx context.Context) (int, error) {
+	var count int
+	err := r.db.conn(ctx).QueryRow(ctx, `SELECT COUNT(*) FROM users`).Scan(&count)
+	return count, err
+}
+
+func (r *UserRepository) UpdateLastLogin(ctx context.Context, id string) error {
+	query := `UPDATE users SET last_login_at = $1 WHERE id = $2`
+	_, err := r.db.conn(ctx).Exec(ctx, query, time.Now(), id)
+	return err
+}
+
+// scanUser scans a row selected with userColumns
+func scanUser(row pgx.Row) (*types.User, error) {
+	user := &types.User{}
+	err := row.Scan(
+		&user.ID, &user.Email, &user.Name, &user.Role, &user.TeamID,
+		&user.PasswordHash, &user.IsActive,
+		&user.Availability, &user.MaxConcurrent, &user.Skills,
+		&user.LastLoginAt, &user.CreatedAt, &user.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
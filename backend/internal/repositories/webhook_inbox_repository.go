@@ -0,0 +1,186 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/temanbatin/omnichannel/internal/types"
+)
+
+const webhookEventColumns = `id, source, payload, headers, signature_status, status, attempts, next_attempt_at, last_error, processed_at, created_at, updated_at`
+
+type WebhookInboxRepository struct {
+	db *DB
+}
+
+func NewWebhookInboxRepository(db *DB) *WebhookInboxRepository {
+	return &WebhookInboxRepository{db: db}
+}
+
+// Create stores a received webhook. Pending events are picked up by the workers.
+// Returns ErrDuplicate if an event with the same signature hash is already stored.
+func (r *WebhookInboxRepository) Create(ctx context.Context, event *types.WebhookEvent) error {
+	query := `
+		INSERT INTO webhook_inbox (id, source, payload, headers, signature_status, signature, status, last_error, next_attempt_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, $9, $10, $11)
+		ON CONFLICT (signature) WHERE signature IS NOT NULL DO NOTHING
+	`
+	headers := event.Headers
+	if headers == nil {
+		headers = map[string]string{}
+	}
+	tag, err := r.db.conn(ctx).Exec(ctx, query,
+		event.ID, event.Source, event.Payload, headers, event.Signature, event.SignatureHash, event.Status,
+		event.LastError, event.NextAttemptAt, event.CreatedAt, event.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("webhook event %w", ErrDuplicate)
+	}
+	return nil
+}
+
+func (r *WebhookInboxRepository) GetByID(ctx context.Context, id string) (*types.WebhookEvent, error) {
+	query := `SELECT ` + webhookEventColumns + ` FROM webhook_inbox WHERE id = $1`
+	return scanWebhookEvent(r.db.conn(ctx).QueryRow(ctx, query, id))
+}
+
+// List returns events matching the filter, newest first, along with the total match count
+func (r *WebhookInboxRepository) List(ctx context.Context, filter types.WebhookEventFilter) ([]*types.WebhookEvent, int, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.Source != "" {
+		args = append(args, filter.Source)
+		conditions = append(conditions, fmt.Sprintf("source = $%d", len(args)))
+	}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if filter.Since != nil {
+		args = append(args, *filter.Since)
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if filter.Until != nil {
+		args = append(args, *filter.Until)
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := r.db.conn(ctx).QueryRow(ctx, `SELECT COUNT(*) FROM webhook_inbox `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, filter.Limit, filter.Offset)
+	query := fmt.Sprintf(`
+		SELECT %s FROM webhook_inbox
+		%s
+		ORDER BY created_at DESC
+		LIMIT $%d OFFSET $%d
+	`, webhookEventColumns, where, len(args)-1, len(args))
+
+	rows, err := r.db.conn(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var events []*types.WebhookEvent
+	for rows.Next() {
+		event, err := scanWebhookEvent(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		events = append(events, event)
+	}
+	return events, total, rows.Err()
+}
+
+// ClaimNext locks the oldest due event and marks it as processing.
+// Events stuck in processing longer than lockTimeout (e.g. after a crash) are reclaimed.
+// Returns pgx.ErrNoRows when nothing is due.
+func (r *WebhookInboxRepository) ClaimNext(ctx context.Context, lockTimeout time.Duration) (*types.WebhookEvent, error) {
+	query := `
+		UPDATE webhook_inbox
+		SET status = 'processing', attempts = attempts + 1, locked_at = NOW(), updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM webhook_inbox
+			WHERE (status = 'pending' AND next_attempt_at <= NOW())
+			   OR (status = 'processing' AND locked_at < NOW() - make_interval(secs => $1))
+			ORDER BY next_attempt_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + webhookEventColumns
+	return scanWebhookEvent(r.db.conn(ctx).QueryRow(ctx, query, lockTimeout.Seconds()))
+}
+
+// ClaimByID marks a specific event as processing for a manual replay.
+// Events whose signature failed verification are never claimed.
+// Returns pgx.ErrNoRows if the event does not exist, failed verification or a worker currently holds it.
+func (r *WebhookInboxRepository) ClaimByID(ctx context.Context, id string, lockTimeout time.Duration) (*types.WebhookEvent, error) {
+	query := `
+		UPDATE webhook_inbox
+		SET status = 'processing', attempts = attempts + 1, locked_at = NOW(), updated_at = NOW()
+		WHERE id = $1
+		  AND signature_status <> 'invalid'
+		  AND (status <> 'processing' OR locked_at < NOW() - make_interval(secs => $2))
+		RETURNING ` + webhookEventColumns
+	return scanWebhookEvent(r.db.conn(ctx).QueryRow(ctx, query, id, lockTimeout.Seconds()))
+}
+
+func (r *WebhookInboxRepository) MarkProcessed(ctx context.Context, id string) error {
+	query := `
+		UPDATE webhook_inbox
+		SET status = 'processed', locked_at = NULL, last_error = '', processed_at = $1, updated_at = $1
+		WHERE id = $2
+	`
+	_, err := r.db.conn(ctx).Exec(ctx, query, time.Now(), id)
+	return err
+}
+
+// MarkRetry puts a failed event back in the queue until nextAttemptAt
+func (r *WebhookInboxRepository) MarkRetry(ctx context.Context, id, lastError string, nextAttemptAt time.Time) error {
+	query := `
+		UPDATE webhook_inbox
+		SET status = 'pending', locked_at = NULL, last_error = $1, next_attempt_at = $2, updated_at = $3
+		WHERE id = $4
+	`
+	_, err := r.db.conn(ctx).Exec(ctx, query, lastError, nextAttemptAt, time.Now(), id)
+	return err
+}
+
+// MarkDead moves an event to the dead-letter state
+func (r *WebhookInboxRepository) MarkDead(ctx context.Context, id, lastError string) error {
+	query := `
+		UPDATE webhook_inbox
+		SET status = 'dead', locked_at = NULL, last_error = $1, updated_at = $2
+		WHERE id = $3
+	`
+	_, err := r.db.conn(ctx).Exec(ctx, query, lastError, time.Now(), id)
+	return err
+}
+
+// scanWebhookEvent scans a row selected with webhookEventColumns
+func scanWebhookEvent(row pgx.Row) (*types.WebhookEvent, error) {
+	event := &types.WebhookEvent{}
+	err := row.Scan(
+		&event.ID, &event.Source, &event.Payload, &event.Headers, &event.Signature,
+		&event.Status, &event.Attempts, &event.NextAttemptAt, &event.LastError,
+		&event.ProcessedAt, &event.CreatedAt, &event.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return event, nil
+}
@@ -0,0 +1,155 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/temanbatin/omnichannel/internal/types"
+)
+
+const webhookSubscriptionColumns = `id, name, url, secret, event_types, is_active, consecutive_failures,
+		disabled_at, disabled_reason, created_at, updated_at`
+
+type WebhookSubscriptionRepository struct {
+	db *DB
+}
+
+func NewWebhookSubscriptionRepository(db *DB) *WebhookSubscriptionRepository {
+	return &WebhookSubscriptionRepository{db: db}
+}
+
+func (r *WebhookSubscriptionRepository) Create(ctx context.Context, sub *types.WebhookSubscription) error {
+	query := `
+		INSERT INTO webhook_subscriptions (id, name, url, secret, event_types, is_active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	_, err := r.db.conn(ctx).Exec(ctx, query,
+		sub.ID, sub.Name, sub.URL, sub.Secret, eventTypeStrings(sub.EventTypes),
+		sub.IsActive, sub.CreatedAt, sub.UpdatedAt,
+	)
+	return err
+}
+
+func (r *WebhookSubscriptionRepository) GetByID(ctx context.Context, id string) (*types.WebhookSubscription, error) {
+	query := `SELECT ` + webhookSubscriptionColumns + ` FROM webhook_subscriptions WHERE id = $1`
+	return scanWebhookSubscription(r.db.conn(ctx).QueryRow(ctx, query, id))
+}
+
+func (r *WebhookSubscriptionRepository) List(ctx context.Context) ([]*types.WebhookSubscription, error) {
+	query := `SELECT ` + webhookSubscriptionColumns + ` FROM webhook_subscriptions ORDER BY created_at`
+	return r.query(ctx, query)
+}
+
+// ListActiveForEvent returns active subscriptions that select the event type
+func (r *WebhookSubscriptionRepository) ListActiveForEvent(ctx context.Context, eventType types.EventType) ([]*types.WebhookSubscription, error) {
+	query := `
+		SELECT ` + webhookSubscriptionColumns + ` FROM webhook_subscriptions
+		WHERE is_active AND (cardinality(event_types) = 0 OR $1 = ANY(event_types))
+	`
+	return r.query(ctx, query, string(eventType))
+}
+
+// Update saves the editable fields. Activating a subscription clears its failure state.
+func (r *WebhookSubscriptionRepository) Update(ctx context.Context, sub *types.WebhookSubscription) error {
+	query := `
+		UPDATE webhook_subscriptions
+		SET name = $1, url = $2, secret = $3, event_types = $4, is_active = $5,
+		    consecutive_failures = CASE WHEN $5 THEN 0 ELSE consecutive_failures END,
+		    disabled_at = CASE WHEN $5 THEN NULL ELSE disabled_at END,
+		    disabled_reason = CASE WHEN $5 THEN '' ELSE disabled_reason END,
+		    updated_at = $6
+		WHERE id = $7
+	`
+	_, err := r.db.conn(ctx).Exec(ctx, query,
+		sub.Name, sub.URL, sub.Secret, eventTypeStrings(sub.EventTypes), sub.IsActive,
+		time.Now(), sub.ID,
+	)
+	return err
+}
+
+// Delete removes a subscription along with its delivery history
+func (r *WebhookSubscriptionRepository) Delete(ctx context.Context, id string) error {
+	tag, err := r.db.conn(ctx).Exec(ctx, `DELETE FROM webhook_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// RecordSuccess resets the consecutive failure count after a successful delivery
+func (r *WebhookSubscriptionRepository) RecordSuccess(ctx context.Context, id string) error {
+	query := `
+		UPDATE webhook_subscriptions
+		SET consecutive_failures = 0, updated_at = $1
+		WHERE id = $2 AND consecutive_failures > 0
+	`
+	_, err := r.db.conn(ctx).Exec(ctx, query, time.Now(), id)
+	return err
+}
+
+// RecordFailure counts a failed delivery attempt and deactivates the subscription once
+// maxFailures consecutive attempts have failed. Returns true if this call disabled it.
+func (r *WebhookSubscriptionRepository) RecordFailure(ctx context.Context, id string, maxFailures int, reason string) (bool, error) {
+	query := `
+		UPDATE webhook_subscriptions
+		SET consecutive_failures = consecutive_failures + 1,
+		    is_active = is_active AND consecutive_failures + 1 < $1,
+		    disabled_at = CASE WHEN is_active AND consecutive_failures + 1 >= $1 THEN $2 ELSE disabled_at END,
+		    disabled_reason = CASE WHEN is_active AND consecutive_failures + 1 >= $1 THEN $3 ELSE disabled_reason END,
+		    updated_at = $2
+		WHERE id = $4
+		RETURNING disabled_at IS NOT NULL AND disabled_at = $2
+	`
+	var disabled bool
+	err := r.db.conn(ctx).QueryRow(ctx, query, maxFailures, time.Now(), reason, id).Scan(&disabled)
+	return disabled, err
+}
+
+func (r *WebhookSubscriptionRepository) query(ctx context.Context, query string, args ...interface{}) ([]*types.WebhookSubscription, error) {
+	rows, err := r.db.conn(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subs []*types.WebhookSubscription
+	for rows.Next() {
+		sub, err := scanWebhookSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+// scanWebhookSubscription scans a row selected with webhookSubscriptionColumns
+func scanWebhookSubscription(row pgx.Row) (*types.WebhookSubscription, error) {
+	sub := &types.WebhookSubscription{}
+	var eventTypes []string
+	err := row.Scan(
+		&sub.ID, &sub.Name, &sub.URL, &sub.Secret, &eventTypes, &sub.IsActive,
+		&sub.ConsecutiveFailures, &sub.DisabledAt, &sub.DisabledReason,
+		&sub.CreatedAt, &sub.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	sub.EventTypes = make([]types.EventType, len(eventTypes))
+	for i, t := range eventTypes {
+		sub.EventTypes[i] = types.EventType(t)
+	}
+	return sub, nil
+}
+
+func eventTypeStrings(eventTypes []types.EventType) []string {
+	values := make([]string, len(eventTypes))
+	for i, t := range eventTypes {
+		values[i] = string(t)
+	}
+	return values
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/temanbatin/omnichannel/internal/auth"
+	"github.com/temanbatin/omnichannel/internal/types"
+)
+
+// ErrForbidden is returned when the authenticated user may not perform an action
+var ErrForbidden = errors.New("forbidden")
+
+// conversationScope returns the visibility restriction for the user in ctx.
+// Contexts without a user (webhook processing, background jobs) are unrestricted.
+func conversationScope(ctx context.Context) *types.ConversationScope {
+	user := auth.UserFromContext(ctx)
+	if user == nil || user.Role.Can(types.PermViewAllConversations) {
+		return nil
+	}
+	return &types.ConversationScope{
+		UserID: user.ID,
+		TeamID: user.TeamID,
+	}
+}
+
+// canAccessConversation reports whether the user in ctx may see and reply to conv
+func canAccessConversation(ctx context.Context, conv *types.Conversation) bool {
+	scope := conversationScope(ctx)
+	if scope == nil {
+		return true
+	}
+	if conv.AssigneeID != "" && conv.AssigneeID == scope.UserID {
+		return true
+	}
+	return conv.TeamID != "" && conv.TeamID == scope.TeamID
+}
+
+// requirePermission returns ErrForbidden unless the user in ctx has the permission.
+// Contexts without a user are trusted internal callers.
+func requirePermission(ctx context.Context, permission types.Permission) error {
+	user := auth.UserFromContext(ctx)
+	if user == nil || user.Role.Can(permission) {
+		return nil
+	}
+	return ErrForbidden
+}
+
+// requireAnyPermission returns ErrForbidden unless the user in ctx has one of the permissions
+func requireAnyPermission(ctx context.Context, permissions ...types.Permission) error {
+	for _, permission := range permissions {
+		if requirePermission(ctx, permission) == nil {
+			return nil
+		}
+	}
+	return ErrForbidden
+}
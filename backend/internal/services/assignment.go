@@ -0,0 +1,199 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/temanbatin/omnichannel/internal/auth"
+	"github.com/temanbatin/omnichannel/internal/realtime"
+	"github.com/temanbatin/omnichannel/internal/repositories"
+	"github.com/temanbatin/omnichannel/internal/types"
+)
+
+// ErrAlreadyAssigned is returned when claiming a conversation another agent holds
+var ErrAlreadyAssigned = errors.New("conversation is already assigned")
+
+// AssignmentService assigns conversations to agents and teams and records the history
+type AssignmentService struct {
+	conversationRepo *repositories.ConversationRepository
+	assignmentRepo   *repositories.ConversationAssignmentRepository
+	userRepo         *repositories.UserRepository
+	teamRepo         *repositories.TeamRepository
+	realtime         *realtime.Broker
+}
+
+// NewAssignmentService creates a new assignment service
+func NewAssignmentService(
+	conversationRepo *repositories.ConversationRepository,
+	assignmentRepo *repositories.ConversationAssignmentRepository,
+	userRepo *repositories.UserRepository,
+	teamRepo *repositories.TeamRepository,
+	realtimeBroker *realtime.Broker,
+) *AssignmentService {
+	return &AssignmentService{
+		conversationRepo: conversationRepo,
+		assignmentRepo:   assignmentRepo,
+		userRepo:         userRepo,
+		teamRepo:         teamRepo,
+		realtime:         realtimeBroker,
+	}
+}
+
+// Assign hands a conversation to an agent and/or team. Requires the assign permission.
+func (s *AssignmentService) Assign(ctx context.Context, conversationID string, req *types.AssignConversationRequest) (*types.Conversation, error) {
+	if err := requirePermission(ctx, types.PermAssignConversations); err != nil {
+		return nil, err
+	}
+	if req.AssigneeID == "" && req.TeamID == nil {
+		return nil, fmt.Errorf("%w: assignee_id or team_id is required", ErrInvalidInput)
+	}
+
+	conv, err := s.conversationRepo.GetByID(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	assigneeID := conv.AssigneeID
+	if req.AssigneeID != "" {
+		if err := s.checkAssignee(ctx, req.AssigneeID); err != nil {
+			return nil, err
+		}
+		assigneeID = req.AssigneeID
+	}
+	teamID := conv.TeamID
+	if req.TeamID != nil {
+		if err := s.checkTeam(ctx, *req.TeamID); err != nil {
+			return nil, err
+		}
+		teamID = *req.TeamID
+	}
+
+	return s.apply(ctx, conv, types.AssignmentAssign, assigneeID, teamID)
+}
+
+// Unassign removes the agent from a conversation, returning it to its team's queue.
+// Supervisors may unassign anyone; agents may release their own conversations.
+func (s *AssignmentService) Unassign(ctx context.Context, conversationID string) (*types.Conversation, error) {
+	conv, err := s.conversationRepo.GetByID(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := requirePermission(ctx, types.PermAssignConversations); err != nil {
+		user := auth.UserFromContext(ctx)
+		if user == nil || conv.AssigneeID != user.ID {
+			return nil, err
+		}
+	}
+
+	return s.apply(ctx, conv, types.AssignmentUnassign, "", conv.TeamID)
+}
+
+// Claim assigns a conversation the current user can see to themselves,
+// unless another agent already holds it
+func (s *AssignmentService) Claim(ctx context.Context, conversationID string) (*types.Conversation, error) {
+	user := auth.UserFromContext(ctx)
+	if user == nil {
+		return nil, fmt.Errorf("%w: claiming requires a dashboard user", ErrInvalidInput)
+	}
+
+	conv, err := s.conversationRepo.GetByID(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if !canAccessConversation(ctx, conv) {
+		return nil, ErrForbidden
+	}
+
+	claimed, err := s.conversationRepo.Claim(ctx, conv.ID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, ErrAlreadyAssigned
+	}
+
+	conv.AssigneeID = user.ID
+	s.record(ctx, conv, types.AssignmentClaim)
+	return conv, nil
+}
+
+// History returns who a conversation was assigned to over time
+func (s *AssignmentService) History(ctx context.Context, conversationID string) ([]*types.ConversationAssignment, error) {
+	conv, err := s.conversationRepo.GetByID(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if !canAccessConversation(ctx, conv) {
+		return nil, ErrForbidden
+	}
+	return s.assignmentRepo.ListByConversation(ctx, conv.ID)
+}
+
+// apply saves a new assignment and records it
+func (s *AssignmentService) apply(ctx context.Context, conv *types.Conversation, action types.AssignmentAction, assigneeID, teamID string) (*types.Conversation, error) {
+	if err := s.conversationRepo.UpdateAssignment(ctx, conv.ID, assigneeID, teamID); err != nil {
+		return nil, err
+	}
+	conv.AssigneeID = assigneeID
+	conv.TeamID = teamID
+	s.record(ctx, conv, action)
+	return conv, nil
+}
+
+// record appends to the history and notifies dashboards. The assignment itself has
+// already been saved, so failures here are logged rather than returned.
+func (s *AssignmentService) record(ctx context.Context, conv *types.Conversation, action types.AssignmentAction) {
+	entry := &types.ConversationAssignment{
+		ID:             uuid.New().String(),
+		ConversationID: conv.ID,
+		Action:         action,
+		AssigneeID:     conv.AssigneeID,
+		TeamID:         conv.TeamID,
+		CreatedAt:      time.Now(),
+	}
+	if user := auth.UserFromContext(ctx); user != nil {
+		entry.ActorID = user.ID
+	}
+	if err := s.assignmentRepo.Create(ctx, entry); err != nil {
+		log.Printf("Failed to record %s of conversation %s: %v", action, conv.ID, err)
+	}
+
+	publishRealtime(ctx, s.realtime, types.RealtimeConversationUpdated, conv, conv)
+}
+
+func (s *AssignmentService) checkAssignee(ctx context.Context, userID string) error {
+	if _, err := uuid.Parse(userID); err != nil {
+		return fmt.Errorf("%w: invalid assignee_id", ErrInvalidInput)
+	}
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%w: assignee not found", ErrInvalidInput)
+	}
+	if err != nil {
+		return err
+	}
+	if !user.IsActive {
+		return fmt.Errorf("%w: assignee is deactivated", ErrInvalidInput)
+	}
+	return nil
+}
+
+func (s *AssignmentService) checkTeam(ctx context.Context, teamID string) error {
+	if teamID == "" {
+		return nil
+	}
+	if _, err := uuid.Parse(teamID); err != nil {
+		return fmt.Errorf("%w: invalid team_id", ErrInvalidInput)
+	}
+	_, err := s.teamRepo.GetByID(ctx, teamID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%w: team not found", ErrInvalidInput)
+	}
+	return err
+}
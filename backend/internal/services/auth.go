@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/temanbatin/omnichannel/internal/auth"
+	"github.com/temanbatin/omnichannel/internal/config"
+	"github.com/temanbatin/omnichannel/internal/repositories"
+	"github.com/temanbatin/omnichannel/internal/types"
+)
+
+var (
+	ErrInvalidCredentials  = errors.New("invalid email or password")
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+)
+
+// AuthService handles dashboard login and session tokens
+type AuthService struct {
+	userRepo         *repositories.UserRepository
+	refreshTokenRepo *repositories.RefreshTokenRepository
+	tokens           *auth.TokenManager
+	config           *config.Config
+}
+
+// NewAuthService creates a new auth service
+func NewAuthService(
+	userRepo *repositories.UserRepository,
+	refreshTokenRepo *repositories.RefreshTokenRepository,
+	tokens *auth.TokenManager,
+	cfg *config.Config,
+) *AuthService {
+	return &AuthService{
+		userRepo:         userRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		tokens:           tokens,
+		config:           cfg,
+	}
+}
+
+// Login verifies credentials and issues a new token pair
+func (s *AuthService) Login(ctx context.Context, email, password string) (*types.AuthTokens, error) {
+	user, err := s.userRepo.GetByEmail(ctx, strings.TrimSpace(email))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.IsActive || !auth.CheckPassword(user.PasswordHash, password) {
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := s.userRepo.UpdateLastLogin(ctx, user.ID); err != nil {
+		return nil, fmt.Errorf("failed to update last login: %w", err)
+	}
+
+	return s.issueTokens(ctx, user)
+}
+
+// Refresh exchanges a refresh token for a new token pair. Refresh tokens are single-use.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (*types.AuthTokens, error) {
+	userID, err := s.refreshTokenRepo.Consume(ctx, auth.HashOpaqueToken(refreshToken))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsActive {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	return s.issueTokens(ctx, user)
+}
+
+// Logout revokes a refresh token. Unknown tokens are ignored.
+func (s *AuthService) Logout(ctx context.Context, refreshToken string) error {
+	_, err := s.refreshTokenRepo.Consume(ctx, auth.HashOpaqueToken(refreshToken))
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+	return nil
+}
+
+// CurrentUser returns the full account of the authenticated user
+func (s *AuthService) CurrentUser(ctx context.Context) (*types.User, error) {
+	user := auth.UserFromContext(ctx)
+	if user == nil {
+		return nil, ErrInvalidCredentials
+	}
+	return s.userRepo.GetByID(ctx, user.ID)
+}
+
+// EnsureBootstrapAdmin creates the first account from config when no users exist yet
+func (s *AuthService) EnsureBootstrapAdmin(ctx context.Context) (bool, error) {
+	if s.config.BootstrapAdminEmail == "" || s.config.BootstrapAdminPassword == "" {
+		return false, nil
+	}
+
+	count, err := s.userRepo.Count(ctx)
+	if err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return false, nil
+	}
+
+	hash, err := auth.HashPassword(s.config.BootstrapAdminPassword)
+	if err != nil {
+		return false, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	now := time.Now()
+	user := &types.User{
+		ID:           uuid.New().String(),
+		Email:        s.config.BootstrapAdminEmail,
+		Name:         "Administrator",
+		Role:         types.RoleAdmin,
+		PasswordHash: hash,
+		IsActive:     true,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *AuthService) issueTokens(ctx context.Context, user *types.User) (*types.AuthTokens, error) {
+	accessToken, err := s.tokens.Issue(auth.Claims{
+		Subject: user.ID,
+		Email:   user.Email,
+		Name:    user.Name,
+		Role:    string(user.Role),
+		TeamID:  user.TeamID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to issue access token: %w", err)
+	}
+
+	refreshToken, err := auth.NewOpaqueToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	expiresAt := time.Now().Add(s.config.RefreshTokenTTL)
+	if err := s.refreshTokenRepo.Create(ctx, user.ID, auth.HashOpaqueToken(refreshToken), expiresAt); err != nil {
+		return nil, fmt.Errorf("failed to store refresh token: %w", err)
+	}
+
+	return &types.AuthTokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.tokens.TTL().Seconds()),
+		User:         user,
+	}, nil
+}
@@ -0,0 +1,655 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/temanbatin/omnichannel/internal/auth"
+	"github.com/temanbatin/omnichannel/internal/config"
+	"github.com/temanbatin/omnichannel/internal/repositories"
+	"github.com/temanbatin/omnichannel/internal/types"
+)
+
+const (
+	broadcastTickInterval = time.Second
+	broadcastClaimTimeout = 5 * time.Minute // A claimed recipient older than this was interrupted
+	broadcastTierWindow   = 24 * time.Hour
+	broadcastImportBatch  = 500
+	broadcastMaxInvalid   = 100 // Invalid rows reported back from an import
+	broadcastSendWorkers  = 10  // Sends in flight at once within a second's budget
+)
+
+// ErrBroadcastState is returned when a broadcast is not in a status that allows the action
+var ErrBroadcastState = errors.New("broadcast is not in a state that allows this")
+
+// BroadcastService creates broadcast campaigns and sends them through a throttled worker
+type BroadcastService struct {
+	broadcastRepo *repositories.BroadcastRepository
+	templateRepo  *repositories.TemplateRepository
+	contactRepo   *repositories.ContactRepository
+	messaging     *MessagingService
+	events        *EventDispatcher
+
+	ratePerSecond int
+	tierLimit     int
+}
+
+// NewBroadcastService creates a new broadcast service
+func NewBroadcastService(
+	broadcastRepo *repositories.BroadcastRepository,
+	templateRepo *repositories.TemplateRepository,
+	contactRepo *repositories.ContactRepository,
+	messaging *MessagingService,
+	events *EventDispatcher,
+	cfg *config.Config,
+) *BroadcastService {
+	return &BroadcastService{
+		broadcastRepo: broadcastRepo,
+		templateRepo:  templateRepo,
+		contactRepo:   contactRepo,
+		messaging:     messaging,
+		events:        events,
+		ratePerSecond: cfg.BroadcastRatePerSecond,
+		tierLimit:     cfg.WhatsAppTierLimit,
+	}
+}
+
+// ListBroadcasts returns broadcasts, optionally only those with the given status
+func (s *BroadcastService) ListBroadcasts(ctx context.Context, status types.BroadcastStatus) ([]*types.Broadcast, error) {
+	if err := requirePermission(ctx, types.PermManageBroadcasts); err != nil {
+		return nil, err
+	}
+	return s.broadcastRepo.List(ctx, status)
+}
+
+// GetBroadcast returns a single broadcast with its counters
+func (s *BroadcastService) GetBroadcast(ctx context.Context, id string) (*types.Broadcast, error) {
+	if err := requirePermission(ctx, types.PermManageBroadcasts); err != nil {
+		return nil, err
+	}
+	return s.broadcastRepo.GetByID(ctx, id)
+}
+
+// CreateBroadcast creates a draft broadcast of an approved template and adds its audience
+func (s *BroadcastService) CreateBroadcast(ctx context.Context, req *types.CreateBroadcastRequest) (*types.Broadcast, *types.BroadcastAudienceResult, error) {
+	if err := requirePermission(ctx, types.PermManageBroadcasts); err != nil {
+		return nil, nil, err
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return nil, nil, fmt.Errorf("%w: name is required", ErrInvalidInput)
+	}
+	template, err := s.templateRepo.GetByID(ctx, req.TemplateID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil, fmt.Errorf("%w: unknown template", ErrInvalidInput)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	// Catch parameter mistakes now rather than on every recipient
+	if _, _, err := renderForContact(template, &req.Params, nil); err != nil {
+		return nil, nil, err
+	}
+	for _, id := range req.Audience.ContactIDs {
+		if _, err := uuid.Parse(id); err != nil {
+			return nil, nil, fmt.Errorf("%w: invalid contact ID %q", ErrInvalidInput, id)
+		}
+	}
+
+	var createdBy string
+	if user := auth.UserFromContext(ctx); user != nil {
+		createdBy = user.ID
+	}
+
+	now := time.Now()
+	broadcast := &types.Broadcast{
+		ID:             uuid.New().String(),
+		Name:           req.Name,
+		TemplateID:     template.ID,
+		Platform:       types.PlatformWhatsApp,
+		Status:         types.BroadcastDraft,
+		TemplateParams: req.Params,
+		CreatedBy:      createdBy,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if err := s.broadcastRepo.Create(ctx, broadcast); err != nil {
+		return nil, nil, err
+	}
+
+	result := &types.BroadcastAudienceResult{}
+	if len(req.Audience.ContactIDs) > 0 {
+		matched, added, err := s.broadcastRepo.AddRecipients(ctx, broadcast.ID, req.Audience.ContactIDs)
+		if err != nil {
+			return nil, nil, err
+		}
+		result.Added += added
+		result.Duplicate += matched - added
+		if missing := len(req.Audience.ContactIDs) - matched; missing > 0 {
+			result.Invalid = append(result.Invalid, fmt.Sprintf("%d contacts were not found or have no WhatsApp ID", missing))
+		}
+	}
+	// Tags are stored normalized, so "VIP" matches conversations tagged "vip"
+	if tag := normalizeLabel(req.Audience.Tag); tag != "" {
+		matched, added, err := s.broadcastRepo.AddRecipientsByTag(ctx, broadcast.ID, tag)
+		if err != nil {
+			return nil, nil, err
+		}
+		result.Added += added
+		result.Duplicate += matched - added
+		if matched == 0 {
+			result.Invalid = append(result.Invalid, fmt.Sprintf("tag %q matches no WhatsApp contacts", tag))
+		}
+	}
+
+	broadcast, err = s.broadcastRepo.GetByID(ctx, broadcast.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return broadcast, result, nil
+}
+
+// ImportRecipients adds recipients to a draft broadcast from CSV. The first row names the
+// columns: "phone" (or "whatsapp_id") is required and "name" is optional. Phone numbers
+// without a contact get one.
+func (s *BroadcastService) ImportRecipients(ctx context.Context, id string, r io.Reader) (*types.BroadcastAudienceResult, error) {
+	if err := requirePermission(ctx, types.PermManageBroadcasts); err != nil {
+		return nil, err
+	}
+	broadcast, err := s.broadcastRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if broadcast.Status != types.BroadcastDraft {
+		return nil, fmt.Errorf("%w: recipients can only be added to a draft", ErrBroadcastState)
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: CSV has no header row", ErrInvalidInput)
+	}
+	phoneCol, nameCol := -1, -1
+	for i, column := range header {
+		switch strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff"))) {
+		case "phone", "whatsapp_id":
+			phoneCol = i
+		case "name":
+			nameCol = i
+		}
+	}
+	if phoneCol < 0 {
+		return nil, fmt.Errorf("%w: CSV needs a phone column", ErrInvalidInput)
+	}
+
+	result := &types.BroadcastAudienceResult{}
+	invalid := func(line int, reason string) {
+		if len(result.Invalid) < broadcastMaxInvalid {
+			result.Invalid = append(result.Invalid, fmt.Sprintf("line %d: %s", line, reason))
+		}
+	}
+
+	var batch []string
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		matched, added, err := s.broadcastRepo.AddRecipients(ctx, broadcast.ID, batch)
+		if err != nil {
+			return err
+		}
+		result.Added += added
+		result.Duplicate += matched - added
+		batch = batch[:0]
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: CSV line %d: %v", ErrInvalidInput, line, err)
+		}
+		if phoneCol >= len(record) {
+			invalid(line, "missing phone")
+			continue
+		}
+		phone, ok := normalizeWhatsAppID(record[phoneCol])
+		if !ok {
+			invalid(line, fmt.Sprintf("invalid phone %q", record[phoneCol]))
+			continue
+		}
+		if seen[phone] {
+			result.Duplicate++
+			continue
+		}
+		seen[phone] = true
+
+		name := phone
+		if nameCol >= 0 && nameCol < len(record) && strings.TrimSpace(record[nameCol]) != "" {
+			name = strings.TrimSpace(record[nameCol])
+		}
+		contact, err := s.importContact(ctx, phone, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create contact for line %d: %w", line, err)
+		}
+
+		batch = append(batch, contact.ID)
+		if len(batch) >= broadcastImportBatch {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// importContact returns the contact with a WhatsApp ID, creating it if needed
+func (s *BroadcastService) importContact(ctx context.Context, whatsappID, name string) (*types.Contact, error) {
+	now := time.Now()
+	contact, created, err := s.contactRepo.CreateOrGetByWhatsAppID(ctx, &types.Contact{
+		ID:         uuid.New().String(),
+		Name:       name,
+		Phone:      whatsappID,
+		WhatsAppID: whatsappID,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		s.events.Publish(ctx, types.EventContactCreated, contact)
+	}
+	return contact, nil
+}
+
+// PreviewBroadcast renders a broadcast's message as a contact would receive it, or reports
+// why that contact would be skipped
+func (s *BroadcastService) PreviewBroadcast(ctx context.Context, req *types.PreviewBroadcastRequest) (*types.BroadcastPreview, error) {
+	if err := requirePermission(ctx, types.PermManageBroadcasts); err != nil {
+		return nil, err
+	}
+
+	templateID, params := req.TemplateID, &req.Params
+	if req.BroadcastID != "" {
+		broadcast, err := s.broadcastRepo.GetByID(ctx, req.BroadcastID)
+		if err != nil {
+			return nil, err
+		}
+		templateID, params = broadcast.TemplateID, &broadcast.TemplateParams
+	}
+	template, err := s.templateRepo.GetByID(ctx, templateID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w: unknown template", ErrInvalidInput)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	contact := req.Contact
+	if req.ContactID != "" {
+		if contact, err = s.contactRepo.GetByID(ctx, req.ContactID); err != nil {
+			return nil, err
+		}
+	}
+
+	rendered, bound, err := renderForContact(template, params, contact)
+	var missing *MissingVariableError
+	if errors.As(err, &missing) {
+		return &types.BroadcastPreview{Skipped: true, SkipReason: err.Error()}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &types.BroadcastPreview{
+		Header:  rendered.header,
+		Content: rendered.content,
+		Params:  bound,
+	}, nil
+}
+
+// ListRecipients returns a page of a broadcast's recipients
+func (s *BroadcastService) ListRecipients(ctx context.Context, filter types.BroadcastRecipientFilter) (*types.BroadcastRecipientPage, error) {
+	if err := requirePermission(ctx, types.PermManageBroadcasts); err != nil {
+		return nil, err
+	}
+	if _, err := s.broadcastRepo.GetByID(ctx, filter.BroadcastID); err != nil {
+		return nil, err
+	}
+
+	recipients, total, err := s.broadcastRepo.ListRecipients(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &types.BroadcastRecipientPage{Recipients: recipients, Total: total}
+	if len(recipients) > 0 && len(recipients) == filter.Limit {
+		last := recipients[len(recipients)-1]
+		page.NextCursor = (&types.Cursor{At: last.CreatedAt, ID: last.ID}).Encode()
+	}
+	return page, nil
+}
+
+// ScheduleBroadcast starts sending a draft broadcast at the requested time, or now
+func (s *BroadcastService) ScheduleBroadcast(ctx context.Context, id string, req *types.ScheduleBroadcastRequest) (*types.Broadcast, error) {
+	if err := requirePermission(ctx, types.PermManageBroadcasts); err != nil {
+		return nil, err
+	}
+	broadcast, err := s.broadcastRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if broadcast.TotalRecipients == 0 {
+		return nil, fmt.Errorf("%w: broadcast has no recipients", ErrInvalidInput)
+	}
+
+	at := time.Now()
+	if req.ScheduledAt != nil {
+		if req.ScheduledAt.Before(at.Add(-time.Minute)) {
+			return nil, fmt.Errorf("%w: scheduled_at is in the past", ErrInvalidInput)
+		}
+		at = *req.ScheduledAt
+	}
+
+	scheduled, err := s.broadcastRepo.Schedule(ctx, broadcast.ID, at)
+	if err != nil {
+		return nil, err
+	}
+	if !scheduled {
+		return nil, fmt.Errorf("%w: only a draft can be scheduled", ErrBroadcastState)
+	}
+	return s.broadcastRepo.GetByID(ctx, broadcast.ID)
+}
+
+// PauseBroadcast stops sending until the broadcast is resumed. Messages already handed
+// to the worker for the current second are still sent.
+func (s *BroadcastService) PauseBroadcast(ctx context.Context, id string) (*types.Broadcast, error) {
+	return s.transition(ctx, id, []types.BroadcastStatus{types.BroadcastScheduled, types.BroadcastSending}, types.BroadcastPaused)
+}
+
+// ResumeBroadcast continues a paused broadcast. One paused before its scheduled time waits for it.
+func (s *BroadcastService) ResumeBroadcast(ctx context.Context, id string) (*types.Broadcast, error) {
+	return s.transition(ctx, id, []types.BroadcastStatus{types.BroadcastPaused}, types.BroadcastScheduled)
+}
+
+// CancelBroadcast stops a broadcast for good; recipients not yet sent to are cancelled
+func (s *BroadcastService) CancelBroadcast(ctx context.Context, id string) (*types.Broadcast, error) {
+	broadcast, err := s.transition(ctx, id, []types.BroadcastStatus{
+		types.BroadcastDraft, types.BroadcastScheduled, types.BroadcastSending, types.BroadcastPaused,
+	}, types.BroadcastCancelled)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.broadcastRepo.CancelPending(ctx, broadcast.ID); err != nil {
+		return nil, err
+	}
+	return broadcast, nil
+}
+
+// transition moves a broadcast between statuses on behalf of a user
+func (s *BroadcastService) transition(ctx context.Context, id string, from []types.BroadcastStatus, to types.BroadcastStatus) (*types.Broadcast, error) {
+	if err := requirePermission(ctx, types.PermManageBroadcasts); err != nil {
+		return nil, err
+	}
+	broadcast, err := s.broadcastRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	moved, err := s.broadcastRepo.Transition(ctx, broadcast.ID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if !moved {
+		return nil, fmt.Errorf("%w: broadcast is %s", ErrBroadcastState, broadcast.Status)
+	}
+	return s.broadcastRepo.GetByID(ctx, broadcast.ID)
+}
+
+// RunWorker sends scheduled broadcasts until ctx is cancelled. Each second it sends at most
+// the configured rate, shared by all sending broadcasts, and never more than the WhatsApp
+// tier allows in a rolling 24 hours.
+func (s *BroadcastService) RunWorker(ctx context.Context) {
+	if s.ratePerSecond <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(broadcastTickInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.sendDue(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Broadcast worker error: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sendDue starts broadcasts whose time has come and sends the next batch of each
+func (s *BroadcastService) sendDue(ctx context.Context) error {
+	if _, err := s.broadcastRepo.StartDue(ctx); err != nil {
+		return fmt.Errorf("failed to start scheduled broadcasts: %w", err)
+	}
+
+	broadcasts, err := s.broadcastRepo.List(ctx, types.BroadcastSending)
+	if err != nil {
+		return fmt.Errorf("failed to list sending broadcasts: %w", err)
+	}
+	if len(broadcasts) == 0 {
+		return nil
+	}
+
+	budget := s.ratePerSecond
+	tierReached := false
+	if s.tierLimit > 0 {
+		used, err := s.broadcastRepo.CountContactsSentSince(ctx, time.Now().Add(-broadcastTierWindow))
+		if err != nil {
+			return fmt.Errorf("failed to count recent broadcast contacts: %w", err)
+		}
+		if remaining := s.tierLimit - used; remaining < budget {
+			budget = remaining
+			tierReached = remaining <= 0
+		}
+	}
+
+	// Oldest first, so a broadcast is finished before the next one takes the budget
+	for i := len(broadcasts) - 1; i >= 0 && ctx.Err() == nil; i-- {
+		broadcast := broadcasts[i]
+		if _, err := s.broadcastRepo.FailStale(ctx, broadcast.ID, broadcastClaimTimeout); err != nil {
+			log.Printf("Failed to release stale recipients of broadcast %s: %v", broadcast.ID, err)
+		}
+
+		if tierReached {
+			if completed, err := s.broadcastRepo.CompleteIfDone(ctx, broadcast.ID); err != nil || completed {
+				continue
+			}
+			message := fmt.Sprintf("Waiting for the WhatsApp tier limit of %d contacts per 24 hours", s.tierLimit)
+			if err := s.broadcastRepo.SetLastError(ctx, broadcast.ID, message); err != nil {
+				log.Printf("Failed to update broadcast %s: %v", broadcast.ID, err)
+			}
+			continue
+		}
+		if budget <= 0 {
+			break
+		}
+
+		sent, err := s.sendBatch(ctx, broadcast, budget)
+		if err != nil {
+			log.Printf("Failed to send broadcast %s: %v", broadcast.ID, err)
+			continue
+		}
+		budget -= sent
+	}
+	return nil
+}
+
+// sendBatch sends to up to limit pending recipients of a broadcast, completing it once
+// none are left. It returns how many recipients were claimed.
+func (s *BroadcastService) sendBatch(ctx context.Context, broadcast *types.Broadcast, limit int) (int, error) {
+	if s.messaging.whatsappClient == nil {
+		return 0, s.broadcastRepo.Fail(ctx, broadcast.ID, "WhatsApp client not configured")
+	}
+
+	template, err := s.templateRepo.GetByID(ctx, broadcast.TemplateID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return 0, err
+	}
+	var rendered *renderedTemplate
+	if err == nil {
+		// With variables this checks sample values; each recipient is rendered for their contact
+		rendered, _, err = renderForContact(template, &broadcast.TemplateParams, nil)
+	} else {
+		err = fmt.Errorf("template no longer exists")
+	}
+	if err != nil {
+		// Every recipient would fail the same way, e.g. the template was paused by Meta
+		return 0, s.broadcastRepo.Fail(ctx, broadcast.ID, err.Error())
+	}
+	personalized := hasVariables(&broadcast.TemplateParams)
+
+	recipients, err := s.broadcastRepo.ClaimPending(ctx, broadcast.ID, limit)
+	if err != nil {
+		return 0, err
+	}
+	if len(recipients) == 0 {
+		if _, err := s.broadcastRepo.CompleteIfDone(ctx, broadcast.ID); err != nil {
+			return 0, err
+		}
+		return 0, nil
+	}
+	if err := s.broadcastRepo.SetLastError(ctx, broadcast.ID, ""); err != nil {
+		log.Printf("Failed to update broadcast %s: %v", broadcast.ID, err)
+	}
+
+	// Meta answers each send in turn, so a few run at once to keep up with the rate
+	workers := min(len(recipients), broadcastSendWorkers)
+	queue := make(chan *types.BroadcastRecipient)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for recipient := range queue {
+				s.sendRecipient(ctx, broadcast, recipient, template, rendered, personalized)
+			}
+		}()
+	}
+	for _, recipient := range recipients {
+		queue <- recipient
+	}
+	close(queue)
+	wg.Wait()
+	return len(recipients), nil
+}
+
+// sendRecipient renders a claimed recipient's message if it is personalized and sends it.
+// Failures are recorded on the recipient.
+func (s *BroadcastService) sendRecipient(ctx context.Context, broadcast *types.Broadcast, recipient *types.BroadcastRecipient, template *types.Template, rendered *renderedTemplate, personalized bool) {
+	message := rendered
+	if personalized {
+		var err error
+		if message, err = s.renderForRecipient(ctx, template, broadcast, recipient); err != nil {
+			log.Printf("Failed to record broadcast %s recipient %s: %v", broadcast.ID, recipient.ID, err)
+		}
+		if message == nil {
+			return
+		}
+	}
+
+	if err := s.sendToRecipient(ctx, broadcast, recipient, template, message); err != nil {
+		log.Printf("Failed to record broadcast %s recipient %s: %v", broadcast.ID, recipient.ID, err)
+	}
+}
+
+// renderForRecipient renders a personalized broadcast for one recipient's contact. When it
+// returns nil, the recipient has been recorded as skipped or failed instead.
+func (s *BroadcastService) renderForRecipient(ctx context.Context, template *types.Template, broadcast *types.Broadcast, recipient *types.BroadcastRecipient) (*renderedTemplate, error) {
+	contact, err := s.contactRepo.GetByID(ctx, recipient.ContactID)
+	if err != nil {
+		return nil, s.broadcastRepo.MarkFailed(ctx, recipient.ID, "", 0, fmt.Sprintf("failed to load contact: %v", err))
+	}
+
+	rendered, _, err := renderForContact(template, &broadcast.TemplateParams, contact)
+	var missing *MissingVariableError
+	if errors.As(err, &missing) {
+		return nil, s.broadcastRepo.MarkSkipped(ctx, recipient.ID, err.Error())
+	}
+	if err != nil {
+		// The contact's values do not fit the template, e.g. an empty header
+		return nil, s.broadcastRepo.MarkFailed(ctx, recipient.ID, "", 0, err.Error())
+	}
+	return rendered, nil
+}
+
+// sendToRecipient sends a rendered broadcast to one recipient and records the outcome
+func (s *BroadcastService) sendToRecipient(ctx context.Context, broadcast *types.Broadcast, recipient *types.BroadcastRecipient, template *types.Template, rendered *renderedTemplate) error {
+	msg, err := s.messaging.SendBroadcastTemplate(ctx, recipient.ContactID, recipient.WhatsAppID, template, rendered)
+	switch {
+	case msg != nil && msg.Status == types.StatusSent:
+		if err != nil {
+			log.Printf("Broadcast %s message to %s was sent but not stored: %v", broadcast.ID, recipient.WhatsAppID, err)
+			msg.ID = ""
+		}
+		return s.broadcastRepo.MarkSent(ctx, recipient.ID, msg.ID, msg.ExternalID)
+	case msg != nil:
+		return s.broadcastRepo.MarkFailed(ctx, recipient.ID, msg.ID, 0, err.Error())
+	default:
+		return s.broadcastRepo.MarkFailed(ctx, recipient.ID, "", 0, err.Error())
+	}
+}
+
+// renderForContact fills params' variables from a contact, or with sample values when it
+// is nil, and renders the template with them
+func renderForContact(template *types.Template, params *types.TemplateParams, contact *types.Contact) (*renderedTemplate, *types.TemplateParams, error) {
+	bound, err := bindVariables(params, contact)
+	if err != nil {
+		return nil, nil, err
+	}
+	rendered, err := renderTemplate(template, bound)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rendered, bound, nil
+}
+
+// normalizeWhatsAppID reduces a phone number to the digits WhatsApp uses as an ID,
+// reporting false if it cannot be a full international number
+func normalizeWhatsAppID(phone string) (string, bool) {
+	var digits strings.Builder
+	for _, r := range strings.TrimSpace(phone) {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == '+' || r == ' ' || r == '-' || r == '(' || r == ')' || r == '.':
+		default:
+			return "", false
+		}
+	}
+	id := digits.String()
+	return id, len(id) >= 8 && len(id) <= 15
+}
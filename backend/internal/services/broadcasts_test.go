@@ -0,0 +1,27 @@
+package services
+
+import "testing"
+
+func TestNormalizeWhatsAppID(t *testing.T) {
+	tests := []struct {
+		phone  string
+		want   string
+		wantOK bool
+	}{
+		{"6281234567890", "6281234567890", true},
+		{"+62 812-3456-7890", "6281234567890", true},
+		{" +1 (415) 555.0100 ", "14155550100", true},
+		{"12345678", "12345678", true},
+		{"1234567", "1234567", false},                   // Too short for an international number
+		{"1234567890123456", "1234567890123456", false}, // Longer than E.164 allows
+		{"0812-3456-7890x", "", false},
+		{"62 812 3456 789O", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := normalizeWhatsAppID(tt.phone)
+		if ok != tt.wantOK || (ok && got != tt.want) {
+			t.Errorf("normalizeWhatsAppID(%q) = %q, %v, want %q, %v", tt.phone, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,343 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/temanbatin/omnichannel/internal/config"
+	"github.com/temanbatin/omnichannel/internal/repositories"
+	"github.com/temanbatin/omnichannel/internal/types"
+)
+
+const (
+	deliveryLockTimeout = 2 * time.Minute
+	deliveryTimeout     = 15 * time.Second
+)
+
+// Delivery target names: the configured N8N_WEBHOOK_URL, or a webhook subscription
+const (
+	EventTargetN8N          = "n8n"
+	EventTargetSubscription = "subscription"
+)
+
+// eventTarget is a URL an event is delivered to
+type eventTarget struct {
+	name           string
+	subscriptionID string
+	url            string
+}
+
+// EventDispatcher records outbound integration events and delivers them with retries.
+// Deliveries are stored in Postgres, so they survive restarts and double as the delivery log.
+type EventDispatcher struct {
+	deliveryRepo     *repositories.EventDeliveryRepository
+	subscriptionRepo *repositories.WebhookSubscriptionRepository
+	config           *config.Config
+	httpClient       *http.Client
+	workers          int
+	maxAttempts      int
+	maxFailures      int
+
+	// wake nudges an idle worker when a new delivery is queued
+	wake chan struct{}
+}
+
+// NewEventDispatcher creates a new event dispatcher
+func NewEventDispatcher(
+	deliveryRepo *repositories.EventDeliveryRepository,
+	subscriptionRepo *repositories.WebhookSubscriptionRepository,
+	cfg *config.Config,
+) *EventDispatcher {
+	workers := cfg.EventWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	maxAttempts := cfg.EventMaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	maxFailures := cfg.SubscriptionMaxFailures
+	if maxFailures < 1 {
+		maxFailures = 1
+	}
+	return &EventDispatcher{
+		deliveryRepo:     deliveryRepo,
+		subscriptionRepo: subscriptionRepo,
+		config:           cfg,
+		httpClient: &http.Client{
+			Timeout: deliveryTimeout,
+		},
+		workers:     workers,
+		maxAttempts: maxAttempts,
+		maxFailures: maxFailures,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Publish queues an event for every interested target and wakes a worker to deliver it.
+// It is for operations that have already committed: failures are logged rather than
+// returned so they never break the operation that raised the event. Inside a transaction
+// use Queue instead.
+func (d *EventDispatcher) Publish(ctx context.Context, eventType types.EventType, data interface{}) {
+	if err := d.Queue(ctx, eventType, data); err != nil {
+		log.Printf("Failed to queue %s event: %v", eventType, err)
+	}
+	d.Wake()
+}
+
+// Queue stores the deliveries of an event without waking a worker. Inside a transaction
+// the deliveries commit or roll back with it, so the caller must return the error and
+// call Wake once the transaction has committed; a woken worker could not see them earlier.
+func (d *EventDispatcher) Queue(ctx context.Context, eventType types.EventType, data interface{}) error {
+	if d == nil {
+		return nil
+	}
+
+	targets := d.targets(ctx, eventType)
+	if len(targets) == 0 {
+		return nil
+	}
+
+	event := types.Event{
+		ID:         uuid.New().String(),
+		Type:       eventType,
+		OccurredAt: time.Now().UTC(),
+		Data:       data,
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s event: %w", eventType, err)
+	}
+
+	now := time.Now()
+	for _, target := range targets {
+		delivery := &types.EventDelivery{
+			ID:             uuid.New().String(),
+			EventID:        event.ID,
+			EventType:      eventType,
+			Target:         target.name,
+			SubscriptionID: target.subscriptionID,
+			URL:            target.url,
+			Payload:        string(payload),
+			Status:         types.DeliveryPending,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		}
+		if err := d.deliveryRepo.Create(ctx, delivery); err != nil {
+			return fmt.Errorf("failed to queue %s event for %s: %w", eventType, target.name, err)
+		}
+	}
+	return nil
+}
+
+// Wake nudges an idle worker to look for queued deliveries
+func (d *EventDispatcher) Wake() {
+	if d == nil {
+		return
+	}
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// ListDeliveries returns the delivery log
+func (d *EventDispatcher) ListDeliveries(ctx context.Context, filter types.EventDeliveryFilter) ([]*types.EventDelivery, int, error) {
+	if err := requirePermission(ctx, types.PermManageChannels); err != nil {
+		return nil, 0, err
+	}
+	return d.deliveryRepo.List(ctx, filter)
+}
+
+// Run starts the delivery workers and blocks until ctx is cancelled and all workers have stopped
+func (d *EventDispatcher) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < d.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.work(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+// targets returns where an event of the given type should be delivered
+func (d *EventDispatcher) targets(ctx context.Context, eventType types.EventType) []eventTarget {
+	var targets []eventTarget
+	if d.config.N8NWebhookURL != "" {
+		targets = append(targets, eventTarget{
+			name: EventTargetN8N,
+			url:  d.config.N8NWebhookURL,
+		})
+	}
+
+	subs, err := d.subscriptionRepo.ListActiveForEvent(ctx, eventType)
+	if err != nil {
+		log.Printf("Failed to load webhook subscriptions for %s: %v", eventType, err)
+	}
+	for _, sub := range subs {
+		targets = append(targets, eventTarget{
+			name:           EventTargetSubscription,
+			subscriptionID: sub.ID,
+			url:            sub.URL,
+		})
+	}
+	return targets
+}
+
+func (d *EventDispatcher) work(ctx context.Context) {
+	for {
+		delivery, err := d.deliveryRepo.ClaimNext(ctx, deliveryLockTimeout)
+		if err == nil {
+			d.deliver(ctx, delivery)
+			continue
+		}
+
+		wait := webhookPollInterval
+		if !errors.Is(err, pgx.ErrNoRows) {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Failed to claim event delivery: %v", err)
+			wait = webhookIdleOnDBError
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.wake:
+		case <-time.After(wait):
+		}
+	}
+}
+
+// deliver POSTs a claimed delivery and records the outcome
+func (d *EventDispatcher) deliver(ctx context.Context, delivery *types.EventDelivery) {
+	// Record the outcome even if shutdown cancelled ctx mid-request
+	recordCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	secret := d.config.N8NWebhookSecret
+	var sub *types.WebhookSubscription
+	if delivery.SubscriptionID != "" {
+		var err error
+		sub, err = d.subscriptionRepo.GetByID(ctx, delivery.SubscriptionID)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			log.Printf("Failed to load webhook subscription %s: %v", delivery.SubscriptionID, err)
+			if err := d.deliveryRepo.MarkRetry(recordCtx, delivery.ID, 0, err.Error(), time.Now().Add(webhookIdleOnDBError)); err != nil {
+				log.Printf("Failed to reschedule event delivery %s: %v", delivery.ID, err)
+			}
+			return
+		}
+		if sub == nil || !sub.IsActive {
+			if err := d.deliveryRepo.MarkFailed(recordCtx, delivery.ID, 0, "subscription is disabled"); err != nil {
+				log.Printf("Failed to mark event delivery %s failed: %v", delivery.ID, err)
+			}
+			return
+		}
+		secret = sub.Secret
+	}
+
+	responseStatus, err := d.post(ctx, delivery, secret)
+	if sub != nil {
+		d.recordSubscriptionOutcome(recordCtx, sub, err)
+	}
+
+	if err == nil {
+		if err := d.deliveryRepo.MarkDelivered(recordCtx, delivery.ID, responseStatus); err != nil {
+			log.Printf("Failed to mark event delivery %s delivered: %v", delivery.ID, err)
+		}
+		return
+	}
+
+	if delivery.Attempts >= d.maxAttempts {
+		log.Printf("Event delivery %s to %s failed after %d attempts: %v", delivery.ID, delivery.Target, delivery.Attempts, err)
+		if err := d.deliveryRepo.MarkFailed(recordCtx, delivery.ID, responseStatus, err.Error()); err != nil {
+			log.Printf("Failed to mark event delivery %s failed: %v", delivery.ID, err)
+		}
+		return
+	}
+
+	next := time.Now().Add(webhookBackoff(delivery.Attempts))
+	if err := d.deliveryRepo.MarkRetry(recordCtx, delivery.ID, responseStatus, err.Error(), next); err != nil {
+		log.Printf("Failed to reschedule event delivery %s: %v", delivery.ID, err)
+	}
+}
+
+// recordSubscriptionOutcome tracks consecutive failed attempts and disables a
+// subscription once it reaches the configured limit
+func (d *EventDispatcher) recordSubscriptionOutcome(ctx context.Context, sub *types.WebhookSubscription, deliveryErr error) {
+	if deliveryErr == nil {
+		if err := d.subscriptionRepo.RecordSuccess(ctx, sub.ID); err != nil {
+			log.Printf("Failed to reset failures of webhook subscription %s: %v", sub.ID, err)
+		}
+		return
+	}
+
+	reason := fmt.Sprintf("disabled after %d consecutive failed deliveries; last error: %v", d.maxFailures, deliveryErr)
+	disabled, err := d.subscriptionRepo.RecordFailure(ctx, sub.ID, d.maxFailures, reason)
+	if err != nil {
+		log.Printf("Failed to record failure of webhook subscription %s: %v", sub.ID, err)
+		return
+	}
+	if disabled {
+		log.Printf("Webhook subscription %s (%s) disabled: %s", sub.ID, sub.Name, reason)
+	}
+}
+
+// post sends the payload with signature headers. Any non-2xx response is an error.
+func (d *EventDispatcher) post(ctx context.Context, delivery *types.EventDelivery, secret string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", delivery.URL, bytes.NewBufferString(delivery.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "omnichannel-events/1.0")
+	req.Header.Set("X-Omnichannel-Event", string(delivery.EventType))
+	req.Header.Set("X-Omnichannel-Delivery", delivery.ID)
+	req.Header.Set("X-Omnichannel-Timestamp", timestamp)
+	if secret != "" {
+		req.Header.Set("X-Omnichannel-Signature", "sha256="+signPayload(secret, timestamp, delivery.Payload))
+	}
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return resp.StatusCode, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return resp.StatusCode, nil
+}
+
+// signPayload returns the hex HMAC-SHA256 of "<timestamp>.<payload>", the same scheme
+// /internal/whatsapp expects from n8n
+func signPayload(secret, timestamp, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/temanbatin/omnichannel/internal/types"
+)
+
+const (
+	snoozeCheckInterval = 30 * time.Second
+	snoozeBatchSize     = 100
+)
+
+// Reasons recorded on conversation.status_changed events
+const (
+	statusReasonManual         = "manual"
+	statusReasonInboundMessage = "inbound_message"
+	statusReasonSnoozeExpired  = "snooze_expired"
+)
+
+// UpdateConversationStatus moves a conversation the current user can access to a new status
+func (s *MessagingService) UpdateConversationStatus(ctx context.Context, id string, req *types.UpdateConversationStatusRequest) (*types.Conversation, error) {
+	if !req.Status.Valid() {
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidInput, req.Status)
+	}
+	var snoozedUntil *time.Time
+	if req.Status == types.ConversationSnoozed {
+		if req.SnoozedUntil == nil || !req.SnoozedUntil.After(time.Now()) {
+			return nil, fmt.Errorf("%w: snoozed_until must be in the future", ErrInvalidInput)
+		}
+		snoozedUntil = req.SnoozedUntil
+	}
+
+	conv, err := s.conversationRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !canAccessConversation(ctx, conv) {
+		return nil, ErrForbidden
+	}
+
+	if err := s.conversationRepo.UpdateStatus(ctx, conv.ID, req.Status, snoozedUntil); err != nil {
+		return nil, err
+	}
+
+	previous := conv.Status
+	updated, err := s.conversationRepo.GetByID(ctx, conv.ID)
+	if err != nil {
+		return nil, err
+	}
+	s.publishStatusChange(ctx, updated, previous, statusReasonManual)
+	return updated, nil
+}
+
+// reopenOnInbound reopens a pending, snoozed or resolved conversation when the customer writes again.
+// It runs in the inbound message's transaction and queues the status change with it; dashboards
+// see the new status in the message push that follows the commit.
+func (s *MessagingService) reopenOnInbound(ctx context.Context, conv *types.Conversation) error {
+	if conv.Status == types.ConversationOpen {
+		return nil
+	}
+
+	reopened, err := s.conversationRepo.Reopen(ctx, conv.ID)
+	if err != nil || !reopened {
+		return err
+	}
+
+	previous := conv.Status
+	conv.Status = types.ConversationOpen
+	conv.SnoozedUntil = nil
+	conv.ResolvedAt = nil
+	return s.events.Queue(ctx, types.EventConversationStatusChanged, statusChange(conv, previous, statusReasonInboundMessage))
+}
+
+// RunSnoozeWaker reopens conversations whose snooze has ended until ctx is cancelled
+func (s *MessagingService) RunSnoozeWaker(ctx context.Context) {
+	ticker := time.NewTicker(snoozeCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		s.wakeSnoozed(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *MessagingService) wakeSnoozed(ctx context.Context) {
+	for {
+		ids, err := s.conversationRepo.ReopenExpiredSnoozes(ctx, snoozeBatchSize)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Failed to reopen snoozed conversations: %v", err)
+			}
+			return
+		}
+
+		for _, id := range ids {
+			conv, err := s.conversationRepo.GetByID(ctx, id)
+			if err != nil {
+				log.Printf("Failed to load reopened conversation %s: %v", id, err)
+				continue
+			}
+			s.publishStatusChange(ctx, conv, types.ConversationSnoozed, statusReasonSnoozeExpired)
+			s.routing.Route(ctx, conv)
+		}
+
+		if len(ids) < snoozeBatchSize {
+			return
+		}
+	}
+}
+
+// publishStatusChange notifies integrations and dashboards of a new conversation status
+func (s *MessagingService) publishStatusChange(ctx context.Context, conv *types.Conversation, previous types.ConversationStatus, reason string) {
+	s.events.Publish(ctx, types.EventConversationStatusChanged, statusChange(conv, previous, reason))
+	s.pushRealtime(ctx, types.RealtimeConversationUpdated, conv, conv)
+}
+
+func statusChange(conv *types.Conversation, previous types.ConversationStatus, reason string) *types.ConversationStatusChange {
+	return &types.ConversationStatusChange{
+		ConversationID: conv.ID,
+		PreviousStatus: previous,
+		Status:         conv.Status,
+		SnoozedUntil:   conv.SnoozedUntil,
+		Reason:         reason,
+	}
+}
@@ -0,0 +1,205 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"mime"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/temanbatin/omnichannel/internal/types"
+)
+
+// MediaRoutePrefix is the path under which stored media is served
+const MediaRoutePrefix = "/media/"
+
+// mediaURLTTL is how long a signed media URL handed to a client or to Meta stays valid
+const mediaURLTTL = 24 * time.Hour
+
+// storeWhatsAppMedia downloads inbound media into the blob store.
+// Download failures are logged and the attachment keeps only the Meta metadata,
+// so the message itself is never dropped.
+func (s *MessagingService) storeWhatsAppMedia(ctx context.Context, media *types.WhatsAppMedia) *types.Attachment {
+	att := &types.Attachment{
+		MediaID:  media.ID,
+		MimeType: media.MimeType,
+		Filename: media.Filename,
+		SHA256:   media.SHA256,
+	}
+
+	if s.whatsappClient == nil || s.blobStore == nil {
+		return att
+	}
+
+	body, info, err := s.whatsappClient.DownloadMedia(media.ID)
+	if err != nil {
+		log.Printf("Failed to download WhatsApp media %s: %v", media.ID, err)
+		return att
+	}
+	defer body.Close()
+
+	if att.MimeType == "" {
+		att.MimeType = info.MimeType
+	}
+
+	key := mediaKey(types.PlatformWhatsApp, att.MimeType)
+	size, err := s.blobStore.Put(ctx, key, body)
+	if err != nil {
+		log.Printf("Failed to store WhatsApp media %s: %v", media.ID, err)
+		return att
+	}
+
+	att.Size = size
+	att.URL = s.mediaURL(key)
+	return att
+}
+
+// mediaKey builds a unique blob key with an extension matching the MIME type
+func mediaKey(platform types.Platform, mimeType string) string {
+	ext := ""
+	// Meta appends codec parameters, e.g. "audio/ogg; codecs=opus"
+	if exts, err := mime.ExtensionsByType(strings.TrimSpace(strings.Split(mimeType, ";")[0])); err == nil && len(exts) > 0 {
+		ext = exts[0]
+	}
+	return fmt.Sprintf("%s/%s%s", platform, uuid.New().String(), ext)
+}
+
+// mediaURL returns the unsigned URL of a stored blob, as kept on the message
+func (s *MessagingService) mediaURL(key string) string {
+	return strings.TrimRight(s.config.PublicBaseURL, "/") + MediaRoutePrefix + key
+}
+
+// signedMediaURL returns a URL that serves a stored blob until mediaURLTTL from now
+func (s *MessagingService) signedMediaURL(key string) string {
+	expires := strconv.FormatInt(time.Now().Add(mediaURLTTL).Unix(), 10)
+	return s.mediaURL(key) + "?" + url.Values{
+		"expires":   {expires},
+		"signature": {s.mediaSignature(key, expires)},
+	}.Encode()
+}
+
+// signMedia swaps the stored media URLs of messages for signed ones. Messages only reach
+// callers allowed to read them, so holding a signed URL stands in for that access check.
+func (s *MessagingService) signMedia(messages ...*types.Message) {
+	for _, msg := range messages {
+		if msg == nil || msg.Attachment == nil {
+			continue
+		}
+		if key, ok := s.mediaKeyFromURL(msg.Attachment.URL); ok {
+			msg.Attachment.URL = s.signedMediaURL(key)
+		}
+	}
+}
+
+// mediaKeyFromURL returns the blob key of one of our media URLs, signed or not
+func (s *MessagingService) mediaKeyFromURL(mediaURL string) (string, bool) {
+	key, ok := strings.CutPrefix(mediaURL, s.mediaURL(""))
+	if !ok {
+		return "", false
+	}
+	key, _, _ = strings.Cut(key, "?")
+	return key, key != ""
+}
+
+// VerifyMediaURL reports whether expires and signature come from an unexpired signed URL for key
+func (s *MessagingService) VerifyMediaURL(key, expires, signature string) bool {
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() >= unix {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(s.mediaSignature(key, expires)))
+}
+
+func (s *MessagingService) mediaSignature(key, expires string) string {
+	mac := hmac.New(sha256.New, []byte("media:"+s.config.JWTSecret))
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// messagePreview returns the text shown in the conversation list for a message
+func messagePreview(msg *types.Message) string {
+	if msg.Content != "" {
+		return msg.Content
+	}
+	if msg.Attachment != nil {
+		return "[" + msg.ContentType + "]"
+	}
+	return msg.Content
+}
+
+// UploadMedia stores an outbound file and prepares it for sending on a platform.
+// WhatsApp requires the file to be uploaded to Meta first; Instagram fetches it from our public URL.
+func (s *MessagingService) UploadMedia(ctx context.Context, platform types.Platform, filename, mimeType string, content []byte) (*types.Attachment, error) {
+	if platform != types.PlatformWhatsApp && platform != types.PlatformInstagram {
+		return nil, fmt.Errorf("unsupported platform: %s", platform)
+	}
+	if s.blobStore == nil {
+		return nil, fmt.Errorf("media storage not configured")
+	}
+
+	key := mediaKey(platform, mimeType)
+	size, err := s.blobStore.Put(ctx, key, bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to store media: %w", err)
+	}
+
+	sum := sha256.Sum256(content)
+	att := &types.Attachment{
+		URL:      s.signedMediaURL(key), // Instagram fetches it from here
+		MimeType: mimeType,
+		Filename: filename,
+		SHA256:   hex.EncodeToString(sum[:]),
+		Size:     size,
+	}
+
+	switch platform {
+	case types.PlatformWhatsApp:
+		if s.whatsappClient == nil {
+			return nil, fmt.Errorf("WhatsApp client not configured")
+		}
+		mediaID, err := s.whatsappClient.UploadMedia(filename, mimeType, bytes.NewReader(content))
+		if err != nil {
+			return nil, fmt.Errorf("failed to upload WhatsApp media: %w", err)
+		}
+		att.MediaID = mediaID
+
+	case types.PlatformInstagram:
+		if s.config.PublicBaseURL == "" {
+			return nil, fmt.Errorf("PUBLIC_BASE_URL must be set to send Instagram attachments")
+		}
+	}
+
+	return att, nil
+}
+
+// attachmentContentType maps a MIME type to a message content type
+func attachmentContentType(mimeType string) string {
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return "image"
+	case strings.HasPrefix(mimeType, "video/"):
+		return "video"
+	case strings.HasPrefix(mimeType, "audio/"):
+		return "audio"
+	default:
+		return "document"
+	}
+}
+
+// instagramAttachmentType maps a message content type to an Instagram attachment type
+func instagramAttachmentType(contentType string) string {
+	switch contentType {
+	case "image", "video", "audio":
+		return contentType
+	default:
+		return "file"
+	}
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/temanbatin/omnichannel/internal/config"
+	"github.com/temanbatin/omnichannel/internal/types"
+)
+
+func testMediaService() *MessagingService {
+	return &MessagingService{config: &config.Config{
+		JWTSecret:     "test-secret",
+		PublicBaseURL: "https://omni.example/",
+	}}
+}
+
+func TestSignedMediaURL(t *testing.T) {
+	s := testMediaService()
+	key := "whatsapp/3f1c.jpg"
+
+	signed, err := url.Parse(s.signedMediaURL(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signed.Path != "/media/"+key {
+		t.Errorf("path = %q", signed.Path)
+	}
+	query := signed.Query()
+	expires, signature := query.Get("expires"), query.Get("signature")
+	if !s.VerifyMediaURL(key, expires, signature) {
+		t.Fatal("signed URL does not verify")
+	}
+
+	tests := map[string][3]string{
+		"other key":       {"whatsapp/other.jpg", expires, signature},
+		"extended expiry": {key, strconv.FormatInt(time.Now().Add(48*time.Hour).Unix(), 10), signature},
+		"bad signature":   {key, expires, strings.Repeat("0", len(signature))},
+		"no signature":    {key, expires, ""},
+		"no expiry":       {key, "", signature},
+	}
+	for name, args := range tests {
+		if s.VerifyMediaURL(args[0], args[1], args[2]) {
+			t.Errorf("%s: verified, want rejected", name)
+		}
+	}
+}
+
+func TestVerifyMediaURLExpired(t *testing.T) {
+	s := testMediaService()
+	expires := strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+	if s.VerifyMediaURL("whatsapp/3f1c.jpg", expires, s.mediaSignature("whatsapp/3f1c.jpg", expires)) {
+		t.Error("expired URL verified")
+	}
+}
+
+func TestSignMedia(t *testing.T) {
+	s := testMediaService()
+	stored := &types.Message{Attachment: &types.Attachment{URL: "https://omni.example/media/whatsapp/3f1c.jpg"}}
+	resigned := &types.Message{Attachment: &types.Attachment{URL: "https://omni.example/media/instagram/9a.png?expires=1&signature=old"}}
+	external := &types.Message{Attachment: &types.Attachment{URL: "https://cdn.example/media/x.png"}}
+	s.signMedia(stored, resigned, external, &types.Message{}, nil)
+
+	for key, msg := range map[string]*types.Message{"whatsapp/3f1c.jpg": stored, "instagram/9a.png": resigned} {
+		signed, err := url.Parse(msg.Attachment.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if signed.Path != "/media/"+key || !s.VerifyMediaURL(key, signed.Query().Get("expires"), signed.Query().Get("signature")) {
+			t.Errorf("%s: URL = %q, want a valid signed URL", key, msg.Attachment.URL)
+		}
+	}
+	if external.Attachment.URL != "https://cdn.example/media/x.png" {
+		t.Errorf("external URL changed to %q", external.Attachment.URL)
+	}
+}
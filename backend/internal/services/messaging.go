@@ -2,21 +2,35 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/temanbatin/omnichannel/internal/auth"
 	"github.com/temanbatin/omnichannel/internal/config"
+	"github.com/temanbatin/omnichannel/internal/realtime"
 	"github.com/temanbatin/omnichannel/internal/repositories"
+	"github.com/temanbatin/omnichannel/internal/storage"
 	"github.com/temanbatin/omnichannel/internal/types"
 	"github.com/temanbatin/omnichannel/pkg/meta"
 )
 
 // MessagingService handles unified messaging across platforms
 type MessagingService struct {
+	db               *repositories.DB
 	messageRepo      *repositories.MessageRepository
 	contactRepo      *repositories.ContactRepository
 	conversationRepo *repositories.ConversationRepository
+	readRepo         *repositories.ConversationReadRepository
+	templateRepo     *repositories.TemplateRepository
+	broadcastRepo    *repositories.BroadcastRepository
+	blobStore        storage.BlobStore
+	events           *EventDispatcher
+	realtime         *realtime.Broker
+	routing          *RoutingService
 	config           *config.Config
 
 	whatsappClient  *meta.WhatsAppClient
@@ -25,15 +39,31 @@ type MessagingService struct {
 
 // NewMessagingService creates a new messaging service
 func NewMessagingService(
+	db *repositories.DB,
 	messageRepo *repositories.MessageRepository,
 	contactRepo *repositories.ContactRepository,
 	conversationRepo *repositories.ConversationRepository,
+	readRepo *repositories.ConversationReadRepository,
+	templateRepo *repositories.TemplateRepository,
+	broadcastRepo *repositories.BroadcastRepository,
+	blobStore storage.BlobStore,
+	events *EventDispatcher,
+	realtimeBroker *realtime.Broker,
+	routing *RoutingService,
 	cfg *config.Config,
 ) *MessagingService {
 	svc := &MessagingService{
+		db:               db,
 		messageRepo:      messageRepo,
 		contactRepo:      contactRepo,
 		conversationRepo: conversationRepo,
+		readRepo:         readRepo,
+		templateRepo:     templateRepo,
+		broadcastRepo:    broadcastRepo,
+		blobStore:        blobStore,
+		events:           events,
+		realtime:         realtimeBroker,
+		routing:          routing,
 		config:           cfg,
 	}
 
@@ -58,14 +88,77 @@ func NewMessagingService(
 
 // SendMessage sends a message to a recipient
 func (s *MessagingService) SendMessage(ctx context.Context, req *types.SendMessageRequest) (*types.Message, error) {
+	// Users limited to their own conversations must reply within one
+	var conv *types.Conversation
+	if req.ConversationID != "" || conversationScope(ctx) != nil {
+		var err error
+		conv, err = s.conversationRepo.GetByID(ctx, req.ConversationID)
+		if err != nil {
+			return nil, fmt.Errorf("conversation not found: %w", err)
+		}
+		if !canAccessConversation(ctx, conv) {
+			return nil, ErrForbidden
+		}
+
+		// The conversation decides who is messaged, so access to one conversation
+		// cannot be used to reach another contact
+		recipientID := conversationRecipient(conv)
+		if recipientID == "" {
+			return nil, fmt.Errorf("%w: the contact has no %s ID", ErrInvalidInput, conv.Platform)
+		}
+		if req.Platform != "" && req.Platform != conv.Platform {
+			return nil, fmt.Errorf("%w: platform does not match the conversation", ErrInvalidInput)
+		}
+		if req.RecipientID != "" && !sameRecipient(conv.Platform, req.RecipientID, recipientID) {
+			return nil, fmt.Errorf("%w: recipient does not match the conversation", ErrInvalidInput)
+		}
+		req.Platform, req.RecipientID = conv.Platform, recipientID
+	} else if req.RecipientID == "" {
+		return nil, fmt.Errorf("%w: recipient_id is required without a conversation", ErrInvalidInput)
+	}
+
+	// Meta rejects free-form WhatsApp messages once the customer service window has closed.
+	// The window is the recipient's: conv was derived from the recipient above, and a send
+	// without a conversation uses the recipient's own conversation.
+	if req.Platform == types.PlatformWhatsApp {
+		if conv == nil {
+			var err error
+			if conv, err = s.whatsappConversation(ctx, req.RecipientID); err != nil {
+				return nil, err
+			}
+			if conv != nil {
+				req.ConversationID = conv.ID
+			}
+		}
+		if err := s.requireServiceWindow(ctx, conv); err != nil {
+			return nil, err
+		}
+	}
+
+	contentType := req.ContentType
+	if req.Attachment != nil && (contentType == "" || contentType == "text") {
+		contentType = attachmentContentType(req.Attachment.MimeType)
+	}
+	if contentType == "" {
+		contentType = "text"
+	}
+
+	// Sends made by the system (e.g. n8n) have no sender
+	var senderID string
+	if user := auth.UserFromContext(ctx); user != nil {
+		senderID = user.ID
+	}
+
 	now := time.Now()
 	msg := &types.Message{
 		ID:             uuid.New().String(),
 		ConversationID: req.ConversationID,
 		Platform:       req.Platform,
 		Direction:      types.DirectionOutbound,
+		SenderID:       senderID,
 		Content:        req.Content,
-		ContentType:    req.ContentType,
+		ContentType:    contentType,
+		Attachment:     req.Attachment,
 		Status:         types.StatusPending,
 		CreatedAt:      now,
 		UpdatedAt:      now,
@@ -80,7 +173,16 @@ func (s *MessagingService) SendMessage(ctx context.Context, req *types.SendMessa
 		if s.whatsappClient == nil {
 			return nil, fmt.Errorf("WhatsApp client not configured")
 		}
-		resp, sendErr := s.whatsappClient.SendText(req.RecipientID, req.Content)
+		var resp *meta.SendTextResponse
+		var sendErr error
+		if req.Attachment != nil {
+			if req.Attachment.MediaID == "" {
+				return nil, fmt.Errorf("attachment media_id is required for WhatsApp")
+			}
+			resp, sendErr = s.whatsappClient.SendMedia(req.RecipientID, contentType, req.Attachment.MediaID, req.Content, req.Attachment.Filename)
+		} else {
+			resp, sendErr = s.whatsappClient.SendText(req.RecipientID, req.Content)
+		}
 		if sendErr != nil {
 			msg.Status = types.StatusFailed
 			s.messageRepo.Create(ctx, msg)
@@ -94,7 +196,22 @@ func (s *MessagingService) SendMessage(ctx context.Context, req *types.SendMessa
 		if s.instagramClient == nil {
 			return nil, fmt.Errorf("Instagram client not configured")
 		}
-		resp, sendErr := s.instagramClient.SendText(req.RecipientID, req.Content)
+		var resp *meta.IGMessageResponse
+		var sendErr error
+		if req.Attachment != nil {
+			if req.Attachment.URL == "" {
+				return nil, fmt.Errorf("attachment url is required for Instagram")
+			}
+			resp, sendErr = s.instagramClient.SendAttachment(req.RecipientID, instagramAttachmentType(contentType), req.Attachment.URL)
+			// Instagram attachments carry no caption, so it follows as a separate text
+			if sendErr == nil && req.Content != "" {
+				if _, captionErr := s.instagramClient.SendText(req.RecipientID, req.Content); captionErr != nil {
+					log.Printf("Failed to send Instagram caption: %v", captionErr)
+				}
+			}
+		} else {
+			resp, sendErr = s.instagramClient.SendText(req.RecipientID, req.Content)
+		}
 		if sendErr != nil {
 			msg.Status = types.StatusFailed
 			s.messageRepo.Create(ctx, msg)
@@ -109,17 +226,44 @@ func (s *MessagingService) SendMessage(ctx context.Context, req *types.SendMessa
 	msg.ExternalID = externalID
 	msg.Status = types.StatusSent
 
-	// Save message
-	if err = s.messageRepo.Create(ctx, msg); err != nil {
+	// Save message and update the conversation summary
+	if _, err = s.messageRepo.Append(ctx, msg, messagePreview(msg)); err != nil {
 		return nil, fmt.Errorf("failed to save message: %w", err)
 	}
 
-	// Update conversation
-	s.conversationRepo.UpdateLastMessage(ctx, req.ConversationID, req.Content)
+	s.signMedia(msg)
+	s.events.Publish(ctx, types.EventMessageSent, msg)
+	s.pushMessage(ctx, msg)
 
 	return msg, nil
 }
 
+// conversationRecipient returns the platform ID messages in conv are sent to
+func conversationRecipient(conv *types.Conversation) string {
+	if conv.Contact == nil {
+		return ""
+	}
+	switch conv.Platform {
+	case types.PlatformWhatsApp:
+		return conv.Contact.WhatsAppID
+	case types.PlatformInstagram:
+		return conv.Contact.InstagramID
+	}
+	return ""
+}
+
+// sameRecipient reports whether two recipient IDs name the same account. WhatsApp
+// numbers are compared by their digits, so "+62 812..." matches "62812...".
+func sameRecipient(platform types.Platform, a, b string) bool {
+	if platform == types.PlatformWhatsApp {
+		if na, ok := normalizeWhatsAppID(a); ok {
+			nb, _ := normalizeWhatsAppID(b)
+			return na == nb
+		}
+	}
+	return a == b
+}
+
 // ProcessIncomingWhatsApp processes incoming WhatsApp webhook
 func (s *MessagingService) ProcessIncomingWhatsApp(ctx context.Context, payload *types.WebhookPayload) error {
 	for _, entry := range payload.Entry {
@@ -129,43 +273,108 @@ func (s *MessagingService) ProcessIncomingWhatsApp(ctx context.Context, payload
 			}
 
 			for _, waMsg := range change.Value.Messages {
-				// Get or create contact
-				contact, err := s.getOrCreateWhatsAppContact(ctx, waMsg.From, change.Value.Contacts)
+				// Skip retried deliveries before doing any work (e.g. media download)
+				exists, err := s.messageRepo.ExistsByExternalID(ctx, types.PlatformWhatsApp, waMsg.ID)
 				if err != nil {
-					return fmt.Errorf("failed to get/create contact: %w", err)
+					return fmt.Errorf("failed to check message: %w", err)
 				}
-
-				// Get or create conversation
-				conversation, err := s.getOrCreateConversation(ctx, contact.ID, types.PlatformWhatsApp)
-				if err != nil {
-					return fmt.Errorf("failed to get/create conversation: %w", err)
+				if exists {
+					continue
 				}
 
-				// Create message
+				// Create message. It is dated when the customer sent it rather than when it is
+				// processed, so retried or replayed webhooks do not move the service window.
 				now := time.Now()
 				msg := &types.Message{
-					ID:             uuid.New().String(),
-					ConversationID: conversation.ID,
-					Platform:       types.PlatformWhatsApp,
-					Direction:      types.DirectionInbound,
-					Content:        waMsg.Text.Body,
-					ContentType:    waMsg.Type,
-					Status:         types.StatusDelivered,
-					ExternalID:     waMsg.ID,
-					CreatedAt:      now,
-					UpdatedAt:      now,
+					ID:          uuid.New().String(),
+					Platform:    types.PlatformWhatsApp,
+					Direction:   types.DirectionInbound,
+					Content:     waMsg.Text.Body,
+					ContentType: waMsg.Type,
+					Status:      types.StatusDelivered,
+					ExternalID:  waMsg.ID,
+					CreatedAt:   waMsg.SentAt(now),
+					UpdatedAt:   now,
 				}
 
-				if err := s.messageRepo.Create(ctx, msg); err != nil {
-					return fmt.Errorf("failed to save message: %w", err)
+				// Media is downloaded before the transaction opens
+				if media := waMsg.Media(); media != nil {
+					msg.Content = media.Caption
+					msg.Attachment = s.storeWhatsAppMedia(ctx, media)
 				}
 
-				// Update conversation
-				s.conversationRepo.UpdateLastMessage(ctx, conversation.ID, waMsg.Text.Body)
+				created, err := s.ingestInbound(ctx, msg, messagePreview(msg), func(ctx context.Context) (*types.Contact, error) {
+					return s.getOrCreateWhatsAppContact(ctx, waMsg.From, change.Value.Contacts)
+				})
+				if err != nil {
+					return err
+				}
+				if !created {
+					// A concurrent delivery of the same message won the insert
+					continue
+				}
+				s.pushMessage(ctx, msg)
 			}
+
+			for i := range change.Value.Statuses {
+				if err := s.applyWhatsAppStatus(ctx, &change.Value.Statuses[i]); err != nil {
+					return fmt.Errorf("failed to apply message status: %w", err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// applyWhatsAppStatus moves an outbound message forward based on a status callback
+func (s *MessagingService) applyWhatsAppStatus(ctx context.Context, status *types.WhatsAppStatus) error {
+	msg, err := s.messageRepo.GetByExternalID(ctx, types.PlatformWhatsApp, status.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Message was not sent through the dashboard
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Cheap early skip; TransitionStatus repeats the check against the row it locks
+	next := types.MessageStatus(status.Status)
+	if !msg.Status.CanTransitionTo(next) {
+		return nil
+	}
+
+	change := &types.MessageStatusChange{
+		MessageID:      msg.ID,
+		ExternalID:     msg.ExternalID,
+		ConversationID: msg.ConversationID,
+		Platform:       msg.Platform,
+		PreviousStatus: msg.Status,
+		Status:         next,
+	}
+
+	if next == types.StatusFailed && len(status.Errors) > 0 {
+		change.ErrorCode = status.Errors[0].Code
+		change.ErrorTitle = status.Errors[0].Title
+	}
+	applied := false
+	err = s.db.WithTx(ctx, func(ctx context.Context) error {
+		previous, ok, err := s.messageRepo.TransitionStatus(ctx, msg.ID, next, change.ErrorCode, change.ErrorTitle)
+		if err != nil || !ok {
+			// A concurrent callback already moved the message at least as far
+			return err
 		}
+		applied = true
+		change.PreviousStatus = previous
+		// Keeps the counters of the broadcast that sent the message, if any
+		return s.broadcastRepo.ApplyStatus(ctx, msg.ExternalID, next, change.ErrorCode, change.ErrorTitle)
+	})
+	if err != nil || !applied {
+		return err
 	}
 
+	s.events.Publish(ctx, types.EventMessageStatusChanged, change)
+	s.pushStatus(ctx, change)
 	return nil
 }
 
@@ -175,69 +384,218 @@ func (s *MessagingService) ProcessIncomingInstagram(ctx context.Context, payload
 		for _, messaging := range entry.Messaging {
 			senderID := messaging.Sender.ID
 
-			// Get or create contact
-			contact, err := s.getOrCreateInstagramContact(ctx, senderID)
+			exists, err := s.messageRepo.ExistsByExternalID(ctx, types.PlatformInstagram, messaging.Message.Mid)
 			if err != nil {
-				return fmt.Errorf("failed to get/create contact: %w", err)
+				return fmt.Errorf("failed to check message: %w", err)
 			}
-
-			// Get or create conversation
-			conversation, err := s.getOrCreateConversation(ctx, contact.ID, types.PlatformInstagram)
-			if err != nil {
-				return fmt.Errorf("failed to get/create conversation: %w", err)
+			if exists {
+				continue
 			}
 
 			// Create message
 			now := time.Now()
 			msg := &types.Message{
-				ID:             uuid.New().String(),
-				ConversationID: conversation.ID,
-				Platform:       types.PlatformInstagram,
-				Direction:      types.DirectionInbound,
-				Content:        messaging.Message.Text,
-				ContentType:    "text",
-				Status:         types.StatusDelivered,
-				ExternalID:     messaging.Message.Mid,
-				CreatedAt:      now,
-				UpdatedAt:      now,
+				ID:          uuid.New().String(),
+				Platform:    types.PlatformInstagram,
+				Direction:   types.DirectionInbound,
+				Content:     messaging.Message.Text,
+				ContentType: "text",
+				Status:      types.StatusDelivered,
+				ExternalID:  messaging.Message.Mid,
+				CreatedAt:   now,
+				UpdatedAt:   now,
 			}
 
-			if err := s.messageRepo.Create(ctx, msg); err != nil {
-				return fmt.Errorf("failed to save message: %w", err)
+			created, err := s.ingestInbound(ctx, msg, messaging.Message.Text, func(ctx context.Context) (*types.Contact, error) {
+				return s.getOrCreateInstagramContact(ctx, senderID)
+			})
+			if err != nil {
+				return err
 			}
-
-			// Update conversation
-			s.conversationRepo.UpdateLastMessage(ctx, conversation.ID, messaging.Message.Text)
+			if !created {
+				continue
+			}
+			s.pushMessage(ctx, msg)
 		}
 	}
 
 	return nil
 }
 
-// ListConversations returns all conversations
-func (s *MessagingService) ListConversations(ctx context.Context, limit, offset int) ([]*types.Conversation, error) {
-	return s.conversationRepo.List(ctx, limit, offset)
+// ingestInbound stores an inbound message together with the contact and conversation it
+// belongs to as one unit of work, so a failure midway leaves no orphan contact or stale
+// summary. Reopening and routing the conversation and the integration events raised along
+// the way commit with the message: a retried webhook finds the message stored and has
+// nothing left to redo, and failing any step rolls the message back so the retry does it all.
+// It reports false when a concurrent delivery already stored the message.
+func (s *MessagingService) ingestInbound(
+	ctx context.Context,
+	msg *types.Message,
+	preview string,
+	contactFn func(ctx context.Context) (*types.Contact, error),
+) (bool, error) {
+	created := false
+	err := s.db.WithTx(ctx, func(ctx context.Context) error {
+		contact, err := contactFn(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get/create contact: %w", err)
+		}
+
+		conversation, err := s.getOrCreateConversation(ctx, contact.ID, msg.Platform)
+		if err != nil {
+			return fmt.Errorf("failed to get/create conversation: %w", err)
+		}
+
+		msg.ConversationID = conversation.ID
+		created, err = s.messageRepo.Append(ctx, msg, preview)
+		if err != nil {
+			return fmt.Errorf("failed to save message: %w", err)
+		}
+		if !created {
+			return nil
+		}
+
+		if err := s.reopenOnInbound(ctx, conversation); err != nil {
+			return fmt.Errorf("failed to reopen conversation: %w", err)
+		}
+		s.signMedia(msg)
+		if err := s.events.Queue(ctx, types.EventMessageReceived, msg); err != nil {
+			return err
+		}
+		// Last, so that only a failed commit can undo an assignment dashboards were told about
+		s.routing.Route(ctx, conversation)
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	// Deliveries queued in the transaction are visible to the workers only now
+	s.events.Wake()
+	return created, nil
+}
+
+// ListConversations returns a page of the conversations visible to the current user that match the filter
+func (s *MessagingService) ListConversations(ctx context.Context, filter types.ConversationFilter) (*types.ConversationPage, error) {
+	filter.Scope = conversationScope(ctx)
+	if filter.Mine {
+		user := auth.UserFromContext(ctx)
+		if user == nil {
+			return nil, fmt.Errorf("%w: assignee=mine requires a dashboard user", ErrInvalidInput)
+		}
+		filter.AssigneeID = user.ID
+	}
+
+	conversations, total, err := s.conversationRepo.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &types.ConversationPage{Conversations: conversations, Total: total}
+	if len(conversations) > 0 && len(conversations) == filter.Limit {
+		last := conversations[len(conversations)-1]
+		page.NextCursor = (&types.Cursor{At: last.LastMessageAt, ID: last.ID}).Encode()
+	}
+	return page, nil
 }
 
-// GetConversation returns a conversation with messages
+// GetConversation returns a conversation with its latest messages
 func (s *MessagingService) GetConversation(ctx context.Context, id string, messageLimit int) (*types.Conversation, error) {
 	conv, err := s.conversationRepo.GetByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
+	if !canAccessConversation(ctx, conv) {
+		return nil, ErrForbidden
+	}
+
+	messages, err := s.messageRepo.ListByConversation(ctx, types.MessageFilter{ConversationID: id, Limit: messageLimit})
+	if err != nil {
+		return nil, err
+	}
 
-	messages, err := s.messageRepo.ListByConversation(ctx, id, messageLimit, 0)
+	reads, err := s.readRepo.ListByConversation(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
+	s.signMedia(messages...)
 	conv.Messages = messages
+	conv.MessagesNextCursor = messagesCursor(messages, messageLimit)
+	conv.Reads = reads
+	return conv, nil
+}
+
+// ListMessages returns a page of a conversation's messages, newest first. Passing the
+// previous page's cursor loads older messages.
+func (s *MessagingService) ListMessages(ctx context.Context, filter types.MessageFilter) (*types.MessagePage, error) {
+	conv, err := s.conversationRepo.GetByID(ctx, filter.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+	if !canAccessConversation(ctx, conv) {
+		return nil, ErrForbidden
+	}
+
+	messages, err := s.messageRepo.ListByConversation(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	total, err := s.messageRepo.CountByConversation(ctx, conv.ID)
+	if err != nil {
+		return nil, err
+	}
+	s.signMedia(messages...)
+
+	return &types.MessagePage{
+		Messages:   messages,
+		Total:      total,
+		NextCursor: messagesCursor(messages, filter.Limit),
+	}, nil
+}
+
+// messagesCursor returns the cursor for the page after messages, or "" when it was the last page
+func messagesCursor(messages []*types.Message, limit int) string {
+	if len(messages) == 0 || len(messages) < limit {
+		return ""
+	}
+	last := messages[len(messages)-1]
+	return (&types.Cursor{At: last.CreatedAt, ID: last.ID}).Encode()
+}
+
+// UpdateTags replaces a conversation's tags, which routing rules match on
+func (s *MessagingService) UpdateTags(ctx context.Context, conversationID string, tags []string) (*types.Conversation, error) {
+	conv, err := s.conversationRepo.GetByID(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if !canAccessConversation(ctx, conv) {
+		return nil, ErrForbidden
+	}
+
+	conv.Tags = normalizeLabels(tags)
+	if err := s.conversationRepo.UpdateTags(ctx, conv.ID, conv.Tags); err != nil {
+		return nil, err
+	}
+
+	s.pushRealtime(ctx, types.RealtimeConversationUpdated, conv, conv)
 	return conv, nil
 }
 
-// ListContacts returns all contacts
-func (s *MessagingService) ListContacts(ctx context.Context, limit, offset int) ([]*types.Contact, error) {
-	return s.contactRepo.List(ctx, limit, offset)
+// ListContacts returns a page of contacts, newest first. Users limited to their own
+// conversations only see the contacts of those conversations.
+func (s *MessagingService) ListContacts(ctx context.Context, filter types.ContactFilter) (*types.ContactPage, error) {
+	filter.Scope = conversationScope(ctx)
+	contacts, total, err := s.contactRepo.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &types.ContactPage{Contacts: contacts, Total: total}
+	if len(contacts) > 0 && len(contacts) == filter.Limit {
+		last := contacts[len(contacts)-1]
+		page.NextCursor = (&types.Cursor{At: last.CreatedAt, ID: last.ID}).Encode()
+	}
+	return page, nil
 }
 
 // CreateContact creates a new contact
@@ -246,10 +604,16 @@ func (s *MessagingService) CreateContact(ctx context.Context, contact *types.Con
 	contact.ID = uuid.New().String()
 	contact.CreatedAt = now
 	contact.UpdatedAt = now
-	return s.contactRepo.Create(ctx, contact)
+	if err := s.contactRepo.Create(ctx, contact); err != nil {
+		return err
+	}
+
+	s.events.Publish(ctx, types.EventContactCreated, contact)
+	return nil
 }
 
-// Helper: get or create WhatsApp contact
+// Helper: get or create WhatsApp contact. Its event is queued with Queue, so the caller
+// wakes the dispatcher once its transaction commits.
 func (s *MessagingService) getOrCreateWhatsAppContact(ctx context.Context, waID string, waContacts []struct {
 	Profile struct {
 		Name string `json:"name"`
@@ -260,6 +624,9 @@ func (s *MessagingService) getOrCreateWhatsAppContact(ctx context.Context, waID
 	if err == nil {
 		return contact, nil
 	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
 
 	// Create new contact
 	name := waID
@@ -280,19 +647,29 @@ func (s *MessagingService) getOrCreateWhatsAppContact(ctx context.Context, waID
 		UpdatedAt:  now,
 	}
 
-	if err := s.contactRepo.Create(ctx, contact); err != nil {
+	// A concurrent webhook for the same customer may have created it since the lookup
+	contact, created, err := s.contactRepo.CreateOrGetByWhatsAppID(ctx, contact)
+	if err != nil {
 		return nil, err
 	}
 
+	if created {
+		if err := s.events.Queue(ctx, types.EventContactCreated, contact); err != nil {
+			return nil, err
+		}
+	}
 	return contact, nil
 }
 
-// Helper: get or create Instagram contact
+// Helper: get or create Instagram contact; its event is queued as for WhatsApp contacts
 func (s *MessagingService) getOrCreateInstagramContact(ctx context.Context, igID string) (*types.Contact, error) {
 	contact, err := s.contactRepo.GetByInstagramID(ctx, igID)
 	if err == nil {
 		return contact, nil
 	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
 
 	// Try to get profile from Instagram
 	name := igID
@@ -316,33 +693,50 @@ func (s *MessagingService) getOrCreateInstagramContact(ctx context.Context, igID
 		UpdatedAt:   now,
 	}
 
-	if err := s.contactRepo.Create(ctx, contact); err != nil {
+	contact, created, err := s.contactRepo.CreateOrGetByInstagramID(ctx, contact)
+	if err != nil {
 		return nil, err
 	}
 
+	if created {
+		if err := s.events.Queue(ctx, types.EventContactCreated, contact); err != nil {
+			return nil, err
+		}
+	}
 	return contact, nil
 }
 
-// Helper: get or create conversation
+// Helper: get or create conversation; its event is queued as for contacts
 func (s *MessagingService) getOrCreateConversation(ctx context.Context, contactID string, platform types.Platform) (*types.Conversation, error) {
 	conv, err := s.conversationRepo.GetByContactAndPlatform(ctx, contactID, platform)
 	if err == nil {
 		return conv, nil
 	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
 
 	now := time.Now()
 	conv = &types.Conversation{
 		ID:            uuid.New().String(),
 		ContactID:     contactID,
 		Platform:      platform,
+		Tags:          []string{},
+		Status:        types.ConversationOpen,
 		LastMessageAt: now,
 		CreatedAt:     now,
 		UpdatedAt:     now,
 	}
 
-	if err := s.conversationRepo.Create(ctx, conv); err != nil {
+	conv, created, err := s.conversationRepo.CreateOrGet(ctx, conv)
+	if err != nil {
 		return nil, err
 	}
 
+	if created {
+		if err := s.events.Queue(ctx, types.EventConversationCreated, conv); err != nil {
+			return nil, err
+		}
+	}
 	return conv, nil
 }
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/temanbatin/omnichannel/internal/types"
+)
+
+func TestConversationRecipient(t *testing.T) {
+	contact := &types.Contact{WhatsAppID: "6281234567890", InstagramID: "17841400000000000"}
+	tests := []struct {
+		conv *types.Conversation
+		want string
+	}{
+		{&types.Conversation{Platform: types.PlatformWhatsApp, Contact: contact}, "6281234567890"},
+		{&types.Conversation{Platform: types.PlatformInstagram, Contact: contact}, "17841400000000000"},
+		{&types.Conversation{Platform: types.PlatformWhatsApp, Contact: &types.Contact{InstagramID: "1784"}}, ""},
+		{&types.Conversation{Platform: types.PlatformWhatsApp}, ""},
+	}
+	for i, tt := range tests {
+		if got := conversationRecipient(tt.conv); got != tt.want {
+			t.Errorf("case %d: conversationRecipient = %q, want %q", i, got, tt.want)
+		}
+	}
+}
+
+func TestSameRecipient(t *testing.T) {
+	tests := []struct {
+		platform types.Platform
+		a, b     string
+		want     bool
+	}{
+		{types.PlatformWhatsApp, "6281234567890", "6281234567890", true},
+		{types.PlatformWhatsApp, "+62 812-3456-7890", "6281234567890", true},
+		{types.PlatformWhatsApp, "6281234567891", "6281234567890", false},
+		{types.PlatformWhatsApp, "not a number", "6281234567890", false},
+		{types.PlatformInstagram, "17841400000000000", "17841400000000000", true},
+		{types.PlatformInstagram, "+17841400000000000", "17841400000000000", false},
+	}
+	for _, tt := range tests {
+		if got := sameRecipient(tt.platform, tt.a, tt.b); got != tt.want {
+			t.Errorf("sameRecipient(%s, %q, %q) = %v, want %v", tt.platform, tt.a, tt.b, got, tt.want)
+		}
+	}
+}
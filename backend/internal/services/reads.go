@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/temanbatin/omnichannel/internal/auth"
+	"github.com/temanbatin/omnichannel/internal/types"
+)
+
+// MarkConversationRead acknowledges everything received in a conversation so far:
+// it clears the unread count, moves the current user's read position to the latest
+// message and sends a read receipt to the platform. Receipt failures are logged
+// rather than returned, since the dashboard state is already correct.
+func (s *MessagingService) MarkConversationRead(ctx context.Context, conversationID string) (*types.MarkReadResponse, error) {
+	conv, err := s.conversationRepo.GetByID(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if !canAccessConversation(ctx, conv) {
+		return nil, ErrForbidden
+	}
+
+	// The conversation stays locked while its messages are acknowledged and recounted, so an
+	// inbound message appended meanwhile is either acknowledged or left counted as unread
+	now := time.Now()
+	var acknowledged []*types.Message
+	err = s.db.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.conversationRepo.Lock(ctx, conv.ID); err != nil {
+			return err
+		}
+		var err error
+		if acknowledged, err = s.messageRepo.MarkInboundRead(ctx, conv.ID, now); err != nil {
+			return err
+		}
+		conv.UnreadCount, err = s.conversationRepo.MarkAsRead(ctx, conv.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &types.MarkReadResponse{
+		ConversationID: conv.ID,
+		Acknowledged:   len(acknowledged),
+		LastReadAt:     now,
+	}
+	latest, err := s.messageRepo.ListByConversation(ctx, types.MessageFilter{ConversationID: conv.ID, Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(latest) > 0 {
+		resp.LastReadMessageID = latest[0].ID
+	}
+
+	// System callers have no read position of their own
+	if user := auth.UserFromContext(ctx); user != nil {
+		if err := s.readRepo.Upsert(ctx, &types.ConversationRead{
+			ConversationID:    conv.ID,
+			UserID:            user.ID,
+			LastReadMessageID: resp.LastReadMessageID,
+			LastReadAt:        now,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(acknowledged) > 0 {
+		s.sendReadReceipt(conv, acknowledged)
+	}
+
+	s.pushRealtime(ctx, types.RealtimeConversationUpdated, conv, conv)
+	return resp, nil
+}
+
+// sendReadReceipt tells the contact's platform that their messages were read.
+// WhatsApp marks every earlier message read along with the one referenced, so only
+// the newest acknowledged message is sent; Instagram has a single mark_seen action.
+func (s *MessagingService) sendReadReceipt(conv *types.Conversation, acknowledged []*types.Message) {
+	switch conv.Platform {
+	case types.PlatformWhatsApp:
+		if s.whatsappClient == nil {
+			return
+		}
+		for i := len(acknowledged) - 1; i >= 0; i-- {
+			if acknowledged[i].ExternalID == "" {
+				continue
+			}
+			if err := s.whatsappClient.MarkAsRead(acknowledged[i].ExternalID); err != nil {
+				log.Printf("Failed to send WhatsApp read receipt for %s: %v", acknowledged[i].ExternalID, err)
+			}
+			return
+		}
+	case types.PlatformInstagram:
+		if s.instagramClient == nil || conv.Contact == nil || conv.Contact.InstagramID == "" {
+			return
+		}
+		if err := s.instagramClient.MarkSeen(conv.Contact.InstagramID); err != nil {
+			log.Printf("Failed to send Instagram mark_seen for conversation %s: %v", conv.ID, err)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/temanbatin/omnichannel/internal/auth"
+	"github.com/temanbatin/omnichannel/internal/realtime"
+	"github.com/temanbatin/omnichannel/internal/types"
+)
+
+// SubscribeRealtime registers the user in ctx for dashboard events on the conversations
+// they can see. The caller must Unsubscribe when the client disconnects.
+func (s *MessagingService) SubscribeRealtime(ctx context.Context) *realtime.Subscription {
+	return s.realtime.Subscribe(func(event *types.RealtimeEvent) bool {
+		return canAccessConversation(ctx, &types.Conversation{
+			ID:         event.ConversationID,
+			AssigneeID: event.AssigneeID,
+			TeamID:     event.TeamID,
+		})
+	})
+}
+
+// UnsubscribeRealtime stops a subscription created by SubscribeRealtime
+func (s *MessagingService) UnsubscribeRealtime(sub *realtime.Subscription) {
+	s.realtime.Unsubscribe(sub)
+}
+
+// Typing tells other dashboard users that the current user is composing a reply
+func (s *MessagingService) Typing(ctx context.Context, conversationID string) error {
+	user := auth.UserFromContext(ctx)
+	if user == nil {
+		return fmt.Errorf("%w: typing requires a dashboard user", ErrInvalidInput)
+	}
+
+	conv, err := s.conversationRepo.GetByID(ctx, conversationID)
+	if err != nil {
+		return err
+	}
+	if !canAccessConversation(ctx, conv) {
+		return ErrForbidden
+	}
+
+	s.pushRealtime(ctx, types.RealtimeTyping, conv, &types.TypingIndicator{
+		UserID:   user.ID,
+		UserName: user.Name,
+	})
+	return nil
+}
+
+// pushMessage notifies dashboards of a stored message and the conversation summary it changed
+func (s *MessagingService) pushMessage(ctx context.Context, msg *types.Message) {
+	conv, err := s.conversationRepo.GetByID(ctx, msg.ConversationID)
+	if err != nil {
+		log.Printf("Failed to load conversation %s for realtime push: %v", msg.ConversationID, err)
+		return
+	}
+	s.pushRealtime(ctx, types.RealtimeMessageCreated, conv, msg)
+	s.pushRealtime(ctx, types.RealtimeConversationUpdated, conv, conv)
+}
+
+// pushStatus notifies dashboards that a sent message changed status
+func (s *MessagingService) pushStatus(ctx context.Context, change *types.MessageStatusChange) {
+	conv, err := s.conversationRepo.GetByID(ctx, change.ConversationID)
+	if err != nil {
+		log.Printf("Failed to load conversation %s for realtime push: %v", change.ConversationID, err)
+		return
+	}
+	s.pushRealtime(ctx, types.RealtimeMessageStatus, conv, change)
+}
+
+func (s *MessagingService) pushRealtime(ctx context.Context, eventType types.RealtimeEventType, conv *types.Conversation, data interface{}) {
+	publishRealtime(ctx, s.realtime, eventType, conv, data)
+}
+
+// publishRealtime broadcasts data about conv, tagged with the conversation's visibility
+func publishRealtime(ctx context.Context, broker *realtime.Broker, eventType types.RealtimeEventType, conv *types.Conversation, data interface{}) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Failed to marshal realtime %s data: %v", eventType, err)
+		return
+	}
+	broker.Publish(ctx, &types.RealtimeEvent{
+		Type:           eventType,
+		ConversationID: conv.ID,
+		AssigneeID:     conv.AssigneeID,
+		TeamID:         conv.TeamID,
+		Data:           payload,
+	})
+}
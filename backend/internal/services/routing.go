@@ -0,0 +1,245 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/temanbatin/omnichannel/internal/config"
+	"github.com/temanbatin/omnichannel/internal/repositories"
+	"github.com/temanbatin/omnichannel/internal/types"
+)
+
+// routingCandidateLimit bounds how many agents are tried when others hit their cap concurrently
+const routingCandidateLimit = 5
+
+// RoutingService assigns unowned conversations to available agents
+type RoutingService struct {
+	ruleRepo         *repositories.RoutingRuleRepository
+	userRepo         *repositories.UserRepository
+	teamRepo         *repositories.TeamRepository
+	conversationRepo *repositories.ConversationRepository
+	assignments      *AssignmentService
+
+	defaultStrategy types.RoutingStrategy
+	defaultCap      int
+}
+
+// NewRoutingService creates a new routing service
+func NewRoutingService(
+	ruleRepo *repositories.RoutingRuleRepository,
+	userRepo *repositories.UserRepository,
+	teamRepo *repositories.TeamRepository,
+	conversationRepo *repositories.ConversationRepository,
+	assignments *AssignmentService,
+	cfg *config.Config,
+) *RoutingService {
+	strategy := types.RoutingStrategy(cfg.RoutingStrategy)
+	if strategy != "" && !strategy.Valid() {
+		log.Printf("Ignoring unknown ROUTING_STRATEGY %q", cfg.RoutingStrategy)
+		strategy = ""
+	}
+	defaultCap := cfg.RoutingMaxConcurrent
+	if defaultCap < 1 {
+		defaultCap = 1
+	}
+	return &RoutingService{
+		ruleRepo:         ruleRepo,
+		userRepo:         userRepo,
+		teamRepo:         teamRepo,
+		conversationRepo: conversationRepo,
+		assignments:      assignments,
+		defaultStrategy:  strategy,
+		defaultCap:       defaultCap,
+	}
+}
+
+// Route assigns an unassigned conversation using the first matching rule, or the
+// ROUTING_STRATEGY default when none matches. If no agent is available the conversation
+// is left in the rule's team queue. Failures are logged so they never block message intake.
+func (s *RoutingService) Route(ctx context.Context, conv *types.Conversation) {
+	if s == nil || conv.AssigneeID != "" {
+		return
+	}
+
+	rule, err := s.matchRule(ctx, conv)
+	if err != nil {
+		log.Printf("Failed to load routing rules: %v", err)
+		return
+	}
+	if rule == nil {
+		return
+	}
+
+	candidates, err := s.userRepo.ListRoutingCandidates(ctx, rule.TeamID, rule.Skill, rule.Strategy, s.defaultCap, routingCandidateLimit)
+	if err != nil {
+		log.Printf("Failed to find agents for conversation %s: %v", conv.ID, err)
+		return
+	}
+
+	for _, candidate := range candidates {
+		routed, err := s.conversationRepo.RouteTo(ctx, conv.ID, candidate.UserID, rule.TeamID, candidate.Capacity)
+		if err != nil {
+			log.Printf("Failed to route conversation %s: %v", conv.ID, err)
+			return
+		}
+		if !routed {
+			continue
+		}
+
+		if err := s.userRepo.MarkRouted(ctx, candidate.UserID); err != nil {
+			log.Printf("Failed to update round-robin position of %s: %v", candidate.UserID, err)
+		}
+		conv.AssigneeID = candidate.UserID
+		if rule.TeamID != "" {
+			conv.TeamID = rule.TeamID
+		}
+		s.assignments.record(ctx, conv, types.AssignmentRoute)
+		return
+	}
+
+	// Nobody is available; queue it for the team so its members see it
+	if rule.TeamID != "" && conv.TeamID != rule.TeamID {
+		if err := s.conversationRepo.UpdateTeam(ctx, conv.ID, rule.TeamID); err != nil {
+			log.Printf("Failed to queue conversation %s for team %s: %v", conv.ID, rule.TeamID, err)
+			return
+		}
+		conv.TeamID = rule.TeamID
+		s.assignments.record(ctx, conv, types.AssignmentRoute)
+	}
+}
+
+// matchRule returns the first active rule matching conv, a rule for the default
+// strategy, or nil when routing does not apply
+func (s *RoutingService) matchRule(ctx context.Context, conv *types.Conversation) (*types.RoutingRule, error) {
+	rules, err := s.ruleRepo.ListActive(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range rules {
+		if rule.Matches(conv) {
+			return rule, nil
+		}
+	}
+	if s.defaultStrategy != "" {
+		return &types.RoutingRule{Strategy: s.defaultStrategy}, nil
+	}
+	return nil, nil
+}
+
+// ListRules returns all routing rules in evaluation order
+func (s *RoutingService) ListRules(ctx context.Context) ([]*types.RoutingRule, error) {
+	if err := requirePermission(ctx, types.PermAssignConversations); err != nil {
+		return nil, err
+	}
+	return s.ruleRepo.List(ctx)
+}
+
+// CreateRule adds a routing rule
+func (s *RoutingService) CreateRule(ctx context.Context, req *types.RoutingRuleRequest) (*types.RoutingRule, error) {
+	if err := requirePermission(ctx, types.PermAssignConversations); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	rule := &types.RoutingRule{
+		ID:        uuid.New().String(),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.applyRuleRequest(ctx, rule, req); err != nil {
+		return nil, err
+	}
+	if err := s.ruleRepo.Create(ctx, rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// UpdateRule replaces a routing rule
+func (s *RoutingService) UpdateRule(ctx context.Context, id string, req *types.RoutingRuleRequest) (*types.RoutingRule, error) {
+	if err := requirePermission(ctx, types.PermAssignConversations); err != nil {
+		return nil, err
+	}
+
+	rule, err := s.ruleRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.applyRuleRequest(ctx, rule, req); err != nil {
+		return nil, err
+	}
+	if err := s.ruleRepo.Update(ctx, rule); err != nil {
+		return nil, err
+	}
+	return s.ruleRepo.GetByID(ctx, id)
+}
+
+// DeleteRule removes a routing rule
+func (s *RoutingService) DeleteRule(ctx context.Context, id string) error {
+	if err := requirePermission(ctx, types.PermAssignConversations); err != nil {
+		return err
+	}
+	return s.ruleRepo.Delete(ctx, id)
+}
+
+// applyRuleRequest validates req and copies it onto rule
+func (s *RoutingService) applyRuleRequest(ctx context.Context, rule *types.RoutingRule, req *types.RoutingRuleRequest) error {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidInput)
+	}
+	strategy := req.Strategy
+	if strategy == "" {
+		strategy = types.RoutingRoundRobin
+	}
+	if !strategy.Valid() {
+		return fmt.Errorf("%w: unknown strategy %q", ErrInvalidInput, req.Strategy)
+	}
+	if req.TeamID != "" {
+		if _, err := uuid.Parse(req.TeamID); err != nil {
+			return fmt.Errorf("%w: invalid team_id", ErrInvalidInput)
+		}
+		if _, err := s.teamRepo.GetByID(ctx, req.TeamID); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return fmt.Errorf("%w: team not found", ErrInvalidInput)
+			}
+			return err
+		}
+	}
+
+	rule.Name = name
+	rule.Priority = req.Priority
+	rule.Platform = req.Platform
+	rule.Tag = normalizeLabel(req.Tag)
+	rule.TeamID = req.TeamID
+	rule.Skill = normalizeLabel(req.Skill)
+	rule.Strategy = strategy
+	rule.IsActive = req.IsActive == nil || *req.IsActive
+	return nil
+}
+
+// normalizeLabel lowercases and trims a tag or skill so matching is case-insensitive
+func normalizeLabel(label string) string {
+	return strings.ToLower(strings.TrimSpace(label))
+}
+
+// normalizeLabels normalizes and de-duplicates tags or skills, dropping empty ones
+func normalizeLabels(labels []string) []string {
+	seen := make(map[string]bool, len(labels))
+	normalized := make([]string, 0, len(labels))
+	for _, label := range labels {
+		label = normalizeLabel(label)
+		if label == "" || seen[label] {
+			continue
+		}
+		seen[label] = true
+		normalized = append(normalized, label)
+	}
+	return normalized
+}
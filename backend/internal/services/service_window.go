@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/temanbatin/omnichannel/internal/types"
+)
+
+// ErrWindowClosed is returned for free-form WhatsApp sends outside the customer service window
+var ErrWindowClosed = errors.New("WhatsApp customer service window is closed")
+
+// WindowClosedError is a blocked free-form send, with the approved templates that can be sent instead
+type WindowClosedError struct {
+	ConversationID string
+	ExpiredAt      *time.Time // Nil when the customer has never written
+	Templates      []*types.Template
+}
+
+func (e *WindowClosedError) Error() string {
+	if e.ExpiredAt == nil {
+		return fmt.Sprintf("%s: the customer has not messaged yet, send an approved template instead", ErrWindowClosed)
+	}
+	return fmt.Sprintf("%s: it expired at %s, send an approved template instead", ErrWindowClosed, e.ExpiredAt.Format(time.RFC3339))
+}
+
+func (e *WindowClosedError) Unwrap() error {
+	return ErrWindowClosed
+}
+
+// whatsappConversation finds the conversation with a WhatsApp recipient, or nil if there is none
+func (s *MessagingService) whatsappConversation(ctx context.Context, recipientID string) (*types.Conversation, error) {
+	// Meta stores numbers as digits only, so "+62 812..." is the contact "62812..."
+	if id, ok := normalizeWhatsAppID(recipientID); ok {
+		recipientID = id
+	}
+	contact, err := s.contactRepo.GetByWhatsAppID(ctx, recipientID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	conv, err := s.conversationRepo.GetByContactAndPlatform(ctx, contact.ID, types.PlatformWhatsApp)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return conv, err
+}
+
+// requireServiceWindow returns a WindowClosedError unless conv accepts free-form WhatsApp messages
+func (s *MessagingService) requireServiceWindow(ctx context.Context, conv *types.Conversation) error {
+	windowErr := &WindowClosedError{}
+	if conv != nil {
+		conv.SetServiceWindow(time.Now())
+		if conv.WindowOpen {
+			return nil
+		}
+		windowErr.ConversationID = conv.ID
+		windowErr.ExpiredAt = conv.WindowExpiresAt
+	}
+
+	templates, err := s.templateRepo.List(ctx, types.TemplateApproved)
+	if err != nil {
+		log.Printf("Failed to list templates for closed window: %v", err)
+	}
+	windowErr.Templates = templates
+	return windowErr
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/temanbatin/omnichannel/internal/auth"
+	"github.com/temanbatin/omnichannel/internal/repositories"
+	"github.com/temanbatin/omnichannel/internal/types"
+)
+
+// WebhookSubscriptionService manages outgoing webhook subscriptions.
+// Deliveries themselves are queued and sent by the EventDispatcher.
+type WebhookSubscriptionService struct {
+	subscriptionRepo *repositories.WebhookSubscriptionRepository
+	deliveryRepo     *repositories.EventDeliveryRepository
+}
+
+// NewWebhookSubscriptionService creates a new webhook subscription service
+func NewWebhookSubscriptionService(
+	subscriptionRepo *repositories.WebhookSubscriptionRepository,
+	deliveryRepo *repositories.EventDeliveryRepository,
+) *WebhookSubscriptionService {
+	return &WebhookSubscriptionService{
+		subscriptionRepo: subscriptionRepo,
+		deliveryRepo:     deliveryRepo,
+	}
+}
+
+// ListSubscriptions returns all subscriptions without their secrets
+func (s *WebhookSubscriptionService) ListSubscriptions(ctx context.Context) ([]*types.WebhookSubscription, error) {
+	if err := requirePermission(ctx, types.PermManageChannels); err != nil {
+		return nil, err
+	}
+	subs, err := s.subscriptionRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, sub := range subs {
+		sub.Secret = ""
+	}
+	return subs, nil
+}
+
+// GetSubscription returns a subscription without its secret
+func (s *WebhookSubscriptionService) GetSubscription(ctx context.Context, id string) (*types.WebhookSubscription, error) {
+	if err := requirePermission(ctx, types.PermManageChannels); err != nil {
+		return nil, err
+	}
+	sub, err := s.subscriptionRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	sub.Secret = ""
+	return sub, nil
+}
+
+// CreateSubscription registers a new endpoint. The response is the only time the secret is shown.
+func (s *WebhookSubscriptionService) CreateSubscription(ctx context.Context, req *types.CreateWebhookSubscriptionRequest) (*types.WebhookSubscription, error) {
+	if err := requirePermission(ctx, types.PermManageChannels); err != nil {
+		return nil, err
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidInput)
+	}
+	if err := validateSubscriptionURL(req.URL); err != nil {
+		return nil, err
+	}
+	if err := validateEventTypes(req.EventTypes); err != nil {
+		return nil, err
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		var err error
+		if secret, err = auth.NewOpaqueToken(); err != nil {
+			return nil, fmt.Errorf("failed to generate secret: %w", err)
+		}
+	} else if len(secret) < 16 {
+		return nil, fmt.Errorf("%w: secret must be at least 16 characters", ErrInvalidInput)
+	}
+
+	eventTypes := req.EventTypes
+	if eventTypes == nil {
+		eventTypes = []types.EventType{}
+	}
+
+	now := time.Now()
+	sub := &types.WebhookSubscription{
+		ID:         uuid.New().String(),
+		Name:       req.Name,
+		URL:        req.URL,
+		Secret:     secret,
+		EventTypes: eventTypes,
+		IsActive:   true,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := s.subscriptionRepo.Create(ctx, sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// UpdateSubscription edits a subscription. The secret is only returned when rotated.
+func (s *WebhookSubscriptionService) UpdateSubscription(ctx context.Context, id string, req *types.UpdateWebhookSubscriptionRequest) (*types.WebhookSubscription, error) {
+	if err := requirePermission(ctx, types.PermManageChannels); err != nil {
+		return nil, err
+	}
+
+	sub, err := s.subscriptionRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil && strings.TrimSpace(*req.Name) != "" {
+		sub.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.URL != nil {
+		if err := validateSubscriptionURL(*req.URL); err != nil {
+			return nil, err
+		}
+		sub.URL = *req.URL
+	}
+	if req.EventTypes != nil {
+		if err := validateEventTypes(*req.EventTypes); err != nil {
+			return nil, err
+		}
+		sub.EventTypes = *req.EventTypes
+		if sub.EventTypes == nil {
+			sub.EventTypes = []types.EventType{}
+		}
+	}
+	if req.IsActive != nil {
+		sub.IsActive = *req.IsActive
+	}
+	if req.RotateSecret {
+		if sub.Secret, err = auth.NewOpaqueToken(); err != nil {
+			return nil, fmt.Errorf("failed to generate secret: %w", err)
+		}
+	}
+
+	if err := s.subscriptionRepo.Update(ctx, sub); err != nil {
+		return nil, err
+	}
+
+	updated, err := s.subscriptionRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !req.RotateSecret {
+		updated.Secret = ""
+	}
+	return updated, nil
+}
+
+// DeleteSubscription removes a subscription and its delivery history
+func (s *WebhookSubscriptionService) DeleteSubscription(ctx context.Context, id string) error {
+	if err := requirePermission(ctx, types.PermManageChannels); err != nil {
+		return err
+	}
+	return s.subscriptionRepo.Delete(ctx, id)
+}
+
+// ListDeliveries returns the delivery history of one subscription
+func (s *WebhookSubscriptionService) ListDeliveries(ctx context.Context, id string, filter types.EventDeliveryFilter) ([]*types.EventDelivery, int, error) {
+	if err := requirePermission(ctx, types.PermManageChannels); err != nil {
+		return nil, 0, err
+	}
+	if _, err := s.subscriptionRepo.GetByID(ctx, id); err != nil {
+		return nil, 0, err
+	}
+	filter.Target = EventTargetSubscription
+	filter.SubscriptionID = id
+	return s.deliveryRepo.List(ctx, filter)
+}
+
+func validateSubscriptionURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http(s) URL", ErrInvalidInput)
+	}
+	return nil
+}
+
+func validateEventTypes(eventTypes []types.EventType) error {
+	for _, t := range eventTypes {
+		if !t.Valid() {
+			return fmt.Errorf("%w: unknown event type %q", ErrInvalidInput, t)
+		}
+	}
+	return nil
+}
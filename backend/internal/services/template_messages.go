@@ -0,0 +1,406 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/temanbatin/omnichannel/internal/auth"
+	"github.com/temanbatin/omnichannel/internal/types"
+	"github.com/temanbatin/omnichannel/pkg/meta"
+)
+
+// templatePlaceholder matches a {{1}} or {{name}} variable in template text
+var templatePlaceholder = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_]+)\s*\}\}`)
+
+// templateDefinition is the part of a stored Meta component definition that sending depends on
+type templateDefinition struct {
+	Type    string `json:"type"`   // HEADER, BODY, FOOTER or BUTTONS
+	Format  string `json:"format"` // Headers: TEXT, IMAGE, VIDEO, DOCUMENT or LOCATION
+	Text    string `json:"text"`
+	Buttons []struct {
+		Type string `json:"type"` // QUICK_REPLY, URL, PHONE_NUMBER, ...
+		Text string `json:"text"`
+		URL  string `json:"url"`
+	} `json:"buttons"`
+}
+
+// renderedTemplate is a template with its variables filled, ready to send
+type renderedTemplate struct {
+	components []meta.TemplateComponent
+	header     string            // Text header with its variable substituted
+	content    string            // Body text with variables substituted, stored as the message content
+	attachment *types.Attachment // Header media, if any
+}
+
+// SendTemplate sends an approved template into a WhatsApp conversation. Variables are filled
+// from the conversation's contact, and the parameters are checked against the stored
+// template definition before Meta is called.
+func (s *MessagingService) SendTemplate(ctx context.Context, conversationID string, req *types.SendTemplateRequest) (*types.Message, error) {
+	conv, err := s.conversationRepo.GetByID(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if !canAccessConversation(ctx, conv) {
+		return nil, ErrForbidden
+	}
+	if conv.Platform != types.PlatformWhatsApp {
+		return nil, fmt.Errorf("%w: templates can only be sent to WhatsApp conversations", ErrInvalidInput)
+	}
+	if s.whatsappClient == nil {
+		return nil, fmt.Errorf("WhatsApp client not configured")
+	}
+
+	template, err := s.templateRepo.GetByID(ctx, req.TemplateID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w: unknown template", ErrInvalidInput)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	params := &req.Params
+	if hasVariables(params) {
+		contact, err := s.contactRepo.GetByID(ctx, conv.ContactID)
+		if err != nil {
+			return nil, err
+		}
+		params, err = bindVariables(params, contact)
+		var missing *MissingVariableError
+		if errors.As(err, &missing) {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidInput, err)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rendered, err := renderTemplate(template, params)
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := s.sendTemplate(ctx, conv, template, rendered)
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// SendBroadcastTemplate sends a rendered template to a contact, starting a WhatsApp
+// conversation with them if there is none. When sending fails after the message was
+// built, it is returned along with the error: failed if Meta rejected it, sent if only
+// storing it failed.
+func (s *MessagingService) SendBroadcastTemplate(ctx context.Context, contactID, whatsappID string, template *types.Template, rendered *renderedTemplate) (*types.Message, error) {
+	if s.whatsappClient == nil {
+		return nil, fmt.Errorf("WhatsApp client not configured")
+	}
+
+	conv, err := s.getOrCreateConversation(ctx, contactID, types.PlatformWhatsApp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get/create conversation: %w", err)
+	}
+	conv.Contact = &types.Contact{ID: contactID, WhatsAppID: whatsappID}
+	return s.sendTemplate(ctx, conv, template, rendered)
+}
+
+// sendTemplate sends a rendered template into a conversation and records the message
+func (s *MessagingService) sendTemplate(ctx context.Context, conv *types.Conversation, template *types.Template, rendered *renderedTemplate) (*types.Message, error) {
+	if conv.Contact == nil || conv.Contact.WhatsAppID == "" {
+		return nil, fmt.Errorf("%w: the contact has no WhatsApp ID", ErrInvalidInput)
+	}
+
+	var senderID string
+	if user := auth.UserFromContext(ctx); user != nil {
+		senderID = user.ID
+	}
+
+	now := time.Now()
+	msg := &types.Message{
+		ID:             uuid.New().String(),
+		ConversationID: conv.ID,
+		Platform:       types.PlatformWhatsApp,
+		Direction:      types.DirectionOutbound,
+		SenderID:       senderID,
+		Content:        rendered.content,
+		ContentType:    "template",
+		Attachment:     rendered.attachment,
+		Status:         types.StatusPending,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+
+	resp, err := s.whatsappClient.SendTemplateComponents(conv.Contact.WhatsAppID, template.Name, template.Language, rendered.components)
+	if err != nil {
+		msg.Status = types.StatusFailed
+		if createErr := s.messageRepo.Create(ctx, msg); createErr != nil {
+			// Nothing may refer to a message that was never stored
+			log.Printf("Failed to record failed template message: %v", createErr)
+			msg.ID = ""
+		}
+		return msg, fmt.Errorf("failed to send WhatsApp template: %w", err)
+	}
+	if len(resp.Messages) > 0 {
+		msg.ExternalID = resp.Messages[0].ID
+	}
+	msg.Status = types.StatusSent
+
+	// Meta has accepted the message, so it is returned even if it could not be stored
+	if _, err := s.messageRepo.Append(ctx, msg, messagePreview(msg)); err != nil {
+		return msg, fmt.Errorf("failed to save message: %w", err)
+	}
+
+	s.signMedia(msg)
+	s.events.Publish(ctx, types.EventMessageSent, msg)
+	s.pushMessage(ctx, msg)
+
+	return msg, nil
+}
+
+// renderTemplate validates params against the template's definition and builds the
+// components Meta expects
+func renderTemplate(template *types.Template, params *types.TemplateParams) (*renderedTemplate, error) {
+	if template.Status != types.TemplateApproved {
+		return nil, fmt.Errorf("%w: template %s is %s, not approved", ErrInvalidInput, template.Name, template.Status)
+	}
+
+	var definitions []templateDefinition
+	if err := json.Unmarshal(template.Components, &definitions); err != nil {
+		return nil, fmt.Errorf("template %s has an invalid definition: %w", template.Name, err)
+	}
+
+	rendered := &renderedTemplate{}
+	var header, body, buttons *templateDefinition
+	for i := range definitions {
+		switch strings.ToUpper(definitions[i].Type) {
+		case "HEADER":
+			header = &definitions[i]
+		case "BODY":
+			body = &definitions[i]
+		case "BUTTONS":
+			buttons = &definitions[i]
+		}
+	}
+
+	if err := renderHeader(rendered, header, params.Header); err != nil {
+		return nil, err
+	}
+
+	bodyText := ""
+	if body != nil {
+		bodyText = body.Text
+	}
+	placeholders := templatePlaceholders(bodyText)
+	if len(params.Body) != len(placeholders) {
+		return nil, fmt.Errorf("%w: template %s takes %d body parameters, got %d", ErrInvalidInput, template.Name, len(placeholders), len(params.Body))
+	}
+	values := make(map[string]string, len(params.Body))
+	if len(params.Body) > 0 {
+		component := meta.TemplateComponent{Type: "body"}
+		for i := range params.Body {
+			param, display, err := valueParameter(&params.Body[i], fmt.Sprintf("body parameter %d", i+1))
+			if err != nil {
+				return nil, err
+			}
+			component.Parameters = append(component.Parameters, *param)
+
+			key := params.Body[i].Name
+			if key == "" {
+				key = strconv.Itoa(i + 1)
+			}
+			values[key] = display
+		}
+		rendered.components = append(rendered.components, component)
+	}
+	rendered.content = templatePlaceholder.ReplaceAllStringFunc(bodyText, func(match string) string {
+		if value, ok := values[templatePlaceholder.FindStringSubmatch(match)[1]]; ok {
+			return value
+		}
+		return match
+	})
+
+	if err := renderButtons(rendered, buttons, params.Buttons); err != nil {
+		return nil, err
+	}
+	return rendered, nil
+}
+
+// renderHeader adds the header component when the template's header has a variable
+func renderHeader(rendered *renderedTemplate, definition *templateDefinition, param *types.TemplateParam) error {
+	format := ""
+	if definition != nil {
+		format = strings.ToUpper(definition.Format)
+	}
+
+	switch format {
+	case "", "TEXT":
+		if definition == nil || len(templatePlaceholders(definition.Text)) == 0 {
+			if param != nil {
+				return fmt.Errorf("%w: template header takes no parameters", ErrInvalidInput)
+			}
+			if definition != nil {
+				rendered.header = definition.Text
+			}
+			return nil
+		}
+		if param == nil || (param.Type != "" && param.Type != "text") || param.Text == "" {
+			return fmt.Errorf("%w: template header needs a text parameter", ErrInvalidInput)
+		}
+		rendered.header = templatePlaceholder.ReplaceAllLiteralString(definition.Text, param.Text)
+		rendered.components = append(rendered.components, meta.TemplateComponent{
+			Type: "header",
+			Parameters: []meta.TemplateParameter{{
+				Type:          "text",
+				ParameterName: param.Name,
+				Text:          param.Text,
+			}},
+		})
+		return nil
+
+	case "IMAGE", "VIDEO", "DOCUMENT":
+		mediaType := strings.ToLower(format)
+		if param == nil || param.Type != mediaType || param.Media == nil || (param.Media.MediaID == "" && param.Media.URL == "") {
+			return fmt.Errorf("%w: template header needs a %s parameter with a media_id or url", ErrInvalidInput, mediaType)
+		}
+		media := &meta.TemplateMedia{ID: param.Media.MediaID}
+		if media.ID == "" {
+			media.Link = param.Media.URL
+		}
+		parameter := meta.TemplateParameter{Type: mediaType}
+		switch mediaType {
+		case "image":
+			parameter.Image = media
+		case "video":
+			parameter.Video = media
+		case "document":
+			media.Filename = param.Media.Filename
+			parameter.Document = media
+		}
+		rendered.components = append(rendered.components, meta.TemplateComponent{
+			Type:       "header",
+			Parameters: []meta.TemplateParameter{parameter},
+		})
+		rendered.attachment = &types.Attachment{
+			MediaID:  param.Media.MediaID,
+			URL:      param.Media.URL,
+			Filename: param.Media.Filename,
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("%w: %s template headers are not supported", ErrInvalidInput, strings.ToLower(format))
+	}
+}
+
+// renderButtons adds a component for every button parameter, and requires one for each dynamic URL button
+func renderButtons(rendered *renderedTemplate, definition *templateDefinition, params []types.TemplateButtonParam) error {
+	count := 0
+	if definition != nil {
+		count = len(definition.Buttons)
+	}
+
+	provided := make(map[int]bool, len(params))
+	for _, param := range params {
+		if param.Index < 0 || param.Index >= count {
+			return fmt.Errorf("%w: template has no button %d", ErrInvalidInput, param.Index)
+		}
+		if provided[param.Index] {
+			return fmt.Errorf("%w: button %d is given more than once", ErrInvalidInput, param.Index)
+		}
+		provided[param.Index] = true
+
+		button := definition.Buttons[param.Index]
+		component := meta.TemplateComponent{
+			Type:  "button",
+			Index: strconv.Itoa(param.Index),
+		}
+		switch strings.ToUpper(button.Type) {
+		case "QUICK_REPLY":
+			if param.Payload == "" {
+				return fmt.Errorf("%w: quick-reply button %d needs a payload", ErrInvalidInput, param.Index)
+			}
+			component.SubType = "quick_reply"
+			component.Parameters = []meta.TemplateParameter{{Type: "payload", Payload: param.Payload}}
+		case "URL":
+			if len(templatePlaceholders(button.URL)) == 0 {
+				return fmt.Errorf("%w: URL button %d has a fixed URL", ErrInvalidInput, param.Index)
+			}
+			if param.Text == "" {
+				return fmt.Errorf("%w: URL button %d needs a text suffix", ErrInvalidInput, param.Index)
+			}
+			component.SubType = "url"
+			component.Parameters = []meta.TemplateParameter{{Type: "text", Text: param.Text}}
+		default:
+			return fmt.Errorf("%w: button %d takes no parameters", ErrInvalidInput, param.Index)
+		}
+		rendered.components = append(rendered.components, component)
+	}
+
+	for i := 0; i < count; i++ {
+		button := definition.Buttons[i]
+		if strings.EqualFold(button.Type, "URL") && len(templatePlaceholders(button.URL)) > 0 && !provided[i] {
+			return fmt.Errorf("%w: URL button %d needs a text suffix", ErrInvalidInput, i)
+		}
+	}
+	return nil
+}
+
+// valueParameter converts a text, currency or date_time parameter and returns how it reads in the message
+func valueParameter(param *types.TemplateParam, label string) (*meta.TemplateParameter, string, error) {
+	switch param.Type {
+	case "", "text":
+		if param.Text == "" {
+			return nil, "", fmt.Errorf("%w: %s needs text", ErrInvalidInput, label)
+		}
+		return &meta.TemplateParameter{Type: "text", ParameterName: param.Name, Text: param.Text}, param.Text, nil
+
+	case "currency":
+		c := param.Currency
+		if c == nil || len(c.Code) != 3 || c.FallbackValue == "" {
+			return nil, "", fmt.Errorf("%w: %s needs a currency with a 3-letter code, amount_1000 and fallback_value", ErrInvalidInput, label)
+		}
+		return &meta.TemplateParameter{
+			Type:          "currency",
+			ParameterName: param.Name,
+			Currency: &meta.TemplateCurrency{
+				FallbackValue: c.FallbackValue,
+				Code:          strings.ToUpper(c.Code),
+				Amount1000:    c.Amount1000,
+			},
+		}, c.FallbackValue, nil
+
+	case "date_time":
+		if param.DateTime == "" {
+			return nil, "", fmt.Errorf("%w: %s needs a date_time value", ErrInvalidInput, label)
+		}
+		return &meta.TemplateParameter{
+			Type:          "date_time",
+			ParameterName: param.Name,
+			DateTime:      &meta.TemplateDateTime{FallbackValue: param.DateTime},
+		}, param.DateTime, nil
+
+	default:
+		return nil, "", fmt.Errorf("%w: %s has unsupported type %q", ErrInvalidInput, label, param.Type)
+	}
+}
+
+// templatePlaceholders returns the distinct variables in template text, in order of appearance
+func templatePlaceholders(text string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, match := range templatePlaceholder.FindAllStringSubmatch(text, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/temanbatin/omnichannel/internal/types"
+)
+
+const orderTemplateComponents = `[
+	{"type": "HEADER", "format": "TEXT", "text": "Order {{1}}"},
+	{"type": "BODY", "text": "Hi {{1}}, your total is {{2}}, arriving {{3}}."},
+	{"type": "FOOTER", "text": "Reply STOP to opt out"},
+	{"type": "BUTTONS", "buttons": [
+		{"type": "QUICK_REPLY", "text": "Track"},
+		{"type": "URL", "text": "View", "url": "https://shop.example/orders/{{1}}"},
+		{"type": "PHONE_NUMBER", "text": "Call"}
+	]}
+]`
+
+func testTemplate(components string) *types.Template {
+	return &types.Template{
+		Name:       "order_update",
+		Language:   "en",
+		Status:     types.TemplateApproved,
+		Components: json.RawMessage(components),
+	}
+}
+
+func orderParams() *types.TemplateParams {
+	return &types.TemplateParams{
+		Header: &types.TemplateParam{Text: "#1042"},
+		Body: []types.TemplateParam{
+			{Text: "Sari"},
+			{Type: "currency", Currency: &types.TemplateCurrency{Code: "idr", Amount1000: 150000000, FallbackValue: "Rp150.000"}},
+			{Type: "date_time", DateTime: "Friday"},
+		},
+		Buttons: []types.TemplateButtonParam{
+			{Index: 0, Payload: "track-1042"},
+			{Index: 1, Text: "1042"},
+		},
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+	rendered, err := renderTemplate(testTemplate(orderTemplateComponents), orderParams())
+	if err != nil {
+		t.Fatalf("renderTemplate: %v", err)
+	}
+
+	if rendered.header != "Order #1042" {
+		t.Errorf("header = %q", rendered.header)
+	}
+	if want := "Hi Sari, your total is Rp150.000, arriving Friday."; rendered.content != want {
+		t.Errorf("content = %q, want %q", rendered.content, want)
+	}
+
+	got, err := json.Marshal(rendered.components)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[` +
+		`{"type":"header","parameters":[{"type":"text","text":"#1042"}]},` +
+		`{"type":"body","parameters":[` +
+		`{"type":"text","text":"Sari"},` +
+		`{"type":"currency","currency":{"fallback_value":"Rp150.000","code":"IDR","amount_1000":150000000}},` +
+		`{"type":"date_time","date_time":{"fallback_value":"Friday"}}]},` +
+		`{"type":"button","sub_type":"quick_reply","index":"0","parameters":[{"type":"payload","payload":"track-1042"}]},` +
+		`{"type":"button","sub_type":"url","index":"1","parameters":[{"type":"text","text":"1042"}]}` +
+		`]`
+	if string(got) != want {
+		t.Errorf("components =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRenderTemplateMediaHeader(t *testing.T) {
+	template := testTemplate(`[{"type": "HEADER", "format": "DOCUMENT"}, {"type": "BODY", "text": "Your invoice"}]`)
+	params := &types.TemplateParams{
+		Header: &types.TemplateParam{Type: "document", Media: &types.TemplateMediaLink{URL: "https://files.example/inv.pdf", Filename: "inv.pdf"}},
+	}
+
+	rendered, err := renderTemplate(template, params)
+	if err != nil {
+		t.Fatalf("renderTemplate: %v", err)
+	}
+	if len(rendered.components) != 1 || rendered.components[0].Parameters[0].Document == nil {
+		t.Fatalf("components = %+v, want one document header", rendered.components)
+	}
+	if doc := rendered.components[0].Parameters[0].Document; doc.Link != "https://files.example/inv.pdf" || doc.Filename != "inv.pdf" {
+		t.Errorf("document = %+v", doc)
+	}
+	if rendered.attachment == nil || rendered.attachment.URL != "https://files.example/inv.pdf" {
+		t.Errorf("attachment = %+v", rendered.attachment)
+	}
+	if rendered.content != "Your invoice" {
+		t.Errorf("content = %q", rendered.content)
+	}
+}
+
+func TestRenderTemplateNamedParameters(t *testing.T) {
+	template := testTemplate(`[{"type": "BODY", "text": "Hi {{first_name}}, see you {{ day }}"}]`)
+	params := &types.TemplateParams{Body: []types.TemplateParam{
+		{Name: "first_name", Text: "Budi"},
+		{Name: "day", Text: "Monday"},
+	}}
+
+	rendered, err := renderTemplate(template, params)
+	if err != nil {
+		t.Fatalf("renderTemplate: %v", err)
+	}
+	if rendered.content != "Hi Budi, see you Monday" {
+		t.Errorf("content = %q", rendered.content)
+	}
+	if name := rendered.components[0].Parameters[0].ParameterName; name != "first_name" {
+		t.Errorf("parameter_name = %q", name)
+	}
+}
+
+func TestRenderTemplateInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*types.Template, *types.TemplateParams)
+	}{
+		{"not approved", func(tmpl *types.Template, _ *types.TemplateParams) { tmpl.Status = types.TemplatePending }},
+		{"missing header", func(_ *types.Template, p *types.TemplateParams) { p.Header = nil }},
+		{"header of wrong type", func(_ *types.Template, p *types.TemplateParams) { p.Header.Type = "image" }},
+		{"too few body parameters", func(_ *types.Template, p *types.TemplateParams) { p.Body = p.Body[:2] }},
+		{"empty text", func(_ *types.Template, p *types.TemplateParams) { p.Body[0].Text = "" }},
+		{"bad currency code", func(_ *types.Template, p *types.TemplateParams) { p.Body[1].Currency.Code = "RP" }},
+		{"missing date_time", func(_ *types.Template, p *types.TemplateParams) { p.Body[2].DateTime = "" }},
+		{"unknown parameter type", func(_ *types.Template, p *types.TemplateParams) { p.Body[0].Type = "location" }},
+		{"missing URL suffix", func(_ *types.Template, p *types.TemplateParams) { p.Buttons = p.Buttons[:1] }},
+		{"empty payload", func(_ *types.Template, p *types.TemplateParams) { p.Buttons[0].Payload = "" }},
+		{"duplicate button", func(_ *types.Template, p *types.TemplateParams) { p.Buttons[0].Index = 1 }},
+		{"button out of range", func(_ *types.Template, p *types.TemplateParams) {
+			p.Buttons = append(p.Buttons, types.TemplateButtonParam{Index: 3, Text: "x"})
+		}},
+		{"static button", func(_ *types.Template, p *types.TemplateParams) {
+			p.Buttons = append(p.Buttons, types.TemplateButtonParam{Index: 2, Text: "x"})
+		}},
+	}
+	for _, tt := range tests {
+		template, params := testTemplate(orderTemplateComponents), orderParams()
+		tt.modify(template, params)
+		if _, err := renderTemplate(template, params); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%s: err = %v, want ErrInvalidInput", tt.name, err)
+		}
+	}
+}
+
+func TestRenderTemplateFixedHeaderTakesNoParameter(t *testing.T) {
+	template := testTemplate(`[{"type": "HEADER", "format": "TEXT", "text": "Welcome"}, {"type": "BODY", "text": "Hello"}]`)
+
+	rendered, err := renderTemplate(template, &types.TemplateParams{})
+	if err != nil {
+		t.Fatalf("renderTemplate: %v", err)
+	}
+	if rendered.header != "Welcome" || len(rendered.components) != 0 {
+		t.Errorf("header = %q, components = %+v", rendered.header, rendered.components)
+	}
+
+	params := &types.TemplateParams{Header: &types.TemplateParam{Text: "x"}}
+	if _, err := renderTemplate(template, params); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("err = %v, want ErrInvalidInput", err)
+	}
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/temanbatin/omnichannel/internal/types"
+)
+
+// Variables a template parameter can be bound to
+const (
+	variableContactName    = "contact.name"
+	variableContactPhone   = "contact.phone"
+	variableContactEmail   = "contact.email"
+	variableMetadataPrefix = "metadata."
+)
+
+// MissingVariableError is returned when a contact has no value for a variable that has no fallback
+type MissingVariableError struct {
+	Variable string
+}
+
+func (e *MissingVariableError) Error() string {
+	return fmt.Sprintf("contact has no value for %s", e.Variable)
+}
+
+// hasVariables reports whether any parameter is filled from the recipient's contact
+func hasVariables(params *types.TemplateParams) bool {
+	if params.Header != nil && params.Header.Variable != "" {
+		return true
+	}
+	for _, param := range params.Body {
+		if param.Variable != "" {
+			return true
+		}
+	}
+	for _, button := range params.Buttons {
+		if button.Variable != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// bindVariables returns a copy of params with every variable replaced by the contact's
+// value, or its fallback. A nil contact binds each variable to its name in brackets, which
+// is enough to validate the parameters and to preview them without a contact.
+func bindVariables(params *types.TemplateParams, contact *types.Contact) (*types.TemplateParams, error) {
+	var metadata map[string]interface{}
+	if contact != nil && contact.Metadata != "" {
+		decoder := json.NewDecoder(strings.NewReader(contact.Metadata))
+		decoder.UseNumber()
+		// Contacts whose metadata is not a JSON object simply have no metadata values
+		decoder.Decode(&metadata)
+	}
+
+	resolve := func(variable, fallback string) (string, error) {
+		if err := validateVariable(variable); err != nil {
+			return "", err
+		}
+		if contact == nil {
+			return "[" + variable + "]", nil
+		}
+		if value := contactVariable(contact, metadata, variable); value != "" {
+			return value, nil
+		}
+		if fallback != "" {
+			return fallback, nil
+		}
+		return "", &MissingVariableError{Variable: variable}
+	}
+
+	bindParam := func(param types.TemplateParam, label string) (types.TemplateParam, error) {
+		if param.Variable == "" {
+			return param, nil
+		}
+		value, err := resolve(param.Variable, param.Fallback)
+		if err != nil {
+			return param, err
+		}
+		switch param.Type {
+		case "", "text":
+			param.Text = value
+		case "date_time":
+			param.DateTime = value
+		default:
+			return param, fmt.Errorf("%w: %s is a %s and cannot use a variable", ErrInvalidInput, label, param.Type)
+		}
+		param.Variable, param.Fallback = "", ""
+		return param, nil
+	}
+
+	bound := &types.TemplateParams{}
+	if params.Header != nil {
+		header, err := bindParam(*params.Header, "the header parameter")
+		if err != nil {
+			return nil, err
+		}
+		bound.Header = &header
+	}
+	for i, param := range params.Body {
+		param, err := bindParam(param, fmt.Sprintf("body parameter %d", i+1))
+		if err != nil {
+			return nil, err
+		}
+		bound.Body = append(bound.Body, param)
+	}
+	for _, button := range params.Buttons {
+		if button.Variable != "" {
+			value, err := resolve(button.Variable, button.Fallback)
+			if err != nil {
+				return nil, err
+			}
+			// Only the field the button type uses is sent
+			button.Payload, button.Text = value, value
+			button.Variable, button.Fallback = "", ""
+		}
+		bound.Buttons = append(bound.Buttons, button)
+	}
+	return bound, nil
+}
+
+// validateVariable returns ErrInvalidInput unless variable names a contact field or metadata key
+func validateVariable(variable string) error {
+	switch variable {
+	case variableContactName, variableContactPhone, variableContactEmail:
+		return nil
+	}
+	if key := strings.TrimPrefix(variable, variableMetadataPrefix); key != variable && key != "" {
+		return nil
+	}
+	return fmt.Errorf("%w: unknown variable %q, use contact.name, contact.phone, contact.email or metadata.<key>", ErrInvalidInput, variable)
+}
+
+// contactVariable returns the contact's value for a variable, or "" if it has none.
+// Metadata values must be strings, numbers or booleans.
+func contactVariable(contact *types.Contact, metadata map[string]interface{}, variable string) string {
+	switch variable {
+	case variableContactName:
+		// Contacts created from a webhook or import without a name are named by their number
+		if contact.Name == contact.WhatsAppID {
+			return ""
+		}
+		return strings.TrimSpace(contact.Name)
+	case variableContactPhone:
+		if contact.Phone != "" {
+			return contact.Phone
+		}
+		return contact.WhatsAppID
+	case variableContactEmail:
+		return strings.TrimSpace(contact.Email)
+	}
+
+	var value interface{} = metadata
+	for _, key := range strings.Split(strings.TrimPrefix(variable, variableMetadataPrefix), ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		value = object[key]
+	}
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case json.Number:
+		return v.String()
+	case bool:
+		return fmt.Sprint(v)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/temanbatin/omnichannel/internal/types"
+)
+
+func TestContactVariable(t *testing.T) {
+	contact := &types.Contact{
+		Name:       "Sari Dewi",
+		Email:      " sari@example.com ",
+		WhatsAppID: "6281234567890",
+		Metadata:   `{"city": "Bandung", "tier": 2, "vip": true, "order": {"id": "A-17"}, "tags": ["a"]}`,
+	}
+
+	tests := []struct {
+		variable string
+		want     string
+	}{
+		{"contact.name", "Sari Dewi"},
+		{"contact.email", "sari@example.com"},
+		{"contact.phone", "6281234567890"}, // No phone, so the WhatsApp ID
+		{"metadata.city", "Bandung"},
+		{"metadata.tier", "2"},
+		{"metadata.vip", "true"},
+		{"metadata.order.id", "A-17"},
+		{"metadata.order", ""}, // Objects have no text value
+		{"metadata.tags", ""},
+		{"metadata.missing", ""},
+		{"metadata.city.name", ""},
+	}
+	for _, tt := range tests {
+		if got := contactVariable(contact, decodeMetadata(t, contact.Metadata), tt.variable); got != tt.want {
+			t.Errorf("contactVariable(%q) = %q, want %q", tt.variable, got, tt.want)
+		}
+	}
+}
+
+func TestContactVariableNamedByNumber(t *testing.T) {
+	contact := &types.Contact{Name: "6281234567890", Phone: "+62 812-3456-7890", WhatsAppID: "6281234567890"}
+	if got := contactVariable(contact, nil, "contact.name"); got != "" {
+		t.Errorf("contact.name = %q, want empty for a contact named by its number", got)
+	}
+	if got := contactVariable(contact, nil, "contact.phone"); got != "+62 812-3456-7890" {
+		t.Errorf("contact.phone = %q, want the stored phone", got)
+	}
+}
+
+func decodeMetadata(t *testing.T, metadata string) map[string]interface{} {
+	t.Helper()
+	var values map[string]interface{}
+	decoder := json.NewDecoder(strings.NewReader(metadata))
+	decoder.UseNumber()
+	if err := decoder.Decode(&values); err != nil {
+		t.Fatal(err)
+	}
+	return values
+}
+
+func TestBindVariables(t *testing.T) {
+	params := &types.TemplateParams{
+		Header: &types.TemplateParam{Variable: "metadata.order.id"},
+		Body: []types.TemplateParam{
+			{Variable: "contact.name", Fallback: "there"},
+			{Text: "fixed"},
+			{Type: "date_time", Variable: "metadata.delivery"},
+		},
+		Buttons: []types.TemplateButtonParam{
+			{Index: 0, Variable: "metadata.order.id"},
+			{Index: 1, Text: "static"},
+		},
+	}
+	contact := &types.Contact{
+		Name:       "6281234567890",
+		WhatsAppID: "6281234567890",
+		Metadata:   `{"order": {"id": "A-17"}, "delivery": "Friday"}`,
+	}
+
+	bound, err := bindVariables(params, contact)
+	if err != nil {
+		t.Fatalf("bindVariables: %v", err)
+	}
+	if bound.Header.Text != "A-17" || bound.Header.Variable != "" {
+		t.Errorf("header = %+v", bound.Header)
+	}
+	if bound.Body[0].Text != "there" {
+		t.Errorf("body 1 = %q, want the fallback", bound.Body[0].Text)
+	}
+	if bound.Body[1].Text != "fixed" {
+		t.Errorf("body 2 = %q", bound.Body[1].Text)
+	}
+	if bound.Body[2].DateTime != "Friday" {
+		t.Errorf("body 3 = %+v", bound.Body[2])
+	}
+	if b := bound.Buttons[0]; b.Payload != "A-17" || b.Text != "A-17" || b.Variable != "" {
+		t.Errorf("button 0 = %+v", b)
+	}
+	if bound.Buttons[1].Text != "static" {
+		t.Errorf("button 1 = %+v", bound.Buttons[1])
+	}
+	// The broadcast's stored params are shared by every recipient and must not change
+	if params.Body[0].Text != "" || params.Header.Variable == "" {
+		t.Error("bindVariables modified its input")
+	}
+}
+
+func TestBindVariablesSample(t *testing.T) {
+	params := &types.TemplateParams{Body: []types.TemplateParam{{Variable: "metadata.city"}}}
+	bound, err := bindVariables(params, nil)
+	if err != nil {
+		t.Fatalf("bindVariables: %v", err)
+	}
+	if bound.Body[0].Text != "[metadata.city]" {
+		t.Errorf("text = %q, want the variable name in brackets", bound.Body[0].Text)
+	}
+}
+
+func TestBindVariablesErrors(t *testing.T) {
+	contact := &types.Contact{Name: "Sari", Metadata: `not json`}
+	tests := []struct {
+		name    string
+		params  *types.TemplateParams
+		missing bool
+	}{
+		{"missing without fallback", &types.TemplateParams{Body: []types.TemplateParam{{Variable: "contact.email"}}}, true},
+		{"unparseable metadata", &types.TemplateParams{Body: []types.TemplateParam{{Variable: "metadata.city"}}}, true},
+		{"missing button value", &types.TemplateParams{Buttons: []types.TemplateButtonParam{{Variable: "metadata.code"}}}, true},
+		{"unknown variable", &types.TemplateParams{Body: []types.TemplateParam{{Variable: "contact.age"}}}, false},
+		{"empty metadata key", &types.TemplateParams{Body: []types.TemplateParam{{Variable: "metadata."}}}, false},
+		{"variable on currency", &types.TemplateParams{Body: []types.TemplateParam{{Type: "currency", Variable: "contact.name"}}}, false},
+	}
+	for _, tt := range tests {
+		_, err := bindVariables(tt.params, contact)
+		var missing *MissingVariableError
+		switch {
+		case tt.missing && !errors.As(err, &missing):
+			t.Errorf("%s: err = %v, want MissingVariableError", tt.name, err)
+		case !tt.missing && !errors.Is(err, ErrInvalidInput):
+			t.Errorf("%s: err = %v, want ErrInvalidInput", tt.name, err)
+		}
+	}
+}
+
+func TestHasVariables(t *testing.T) {
+	tests := []struct {
+		params *types.TemplateParams
+		want   bool
+	}{
+		{&types.TemplateParams{}, false},
+		{&types.TemplateParams{Body: []types.TemplateParam{{Text: "x"}}}, false},
+		{&types.TemplateParams{Header: &types.TemplateParam{Variable: "contact.name"}}, true},
+		{&types.TemplateParams{Body: []types.TemplateParam{{Text: "x"}, {Variable: "contact.name"}}}, true},
+		{&types.TemplateParams{Buttons: []types.TemplateButtonParam{{Variable: "metadata.code"}}}, true},
+	}
+	for i, tt := range tests {
+		if got := hasVariables(tt.params); got != tt.want {
+			t.Errorf("case %d: hasVariables = %v, want %v", i, got, tt.want)
+		}
+	}
+}
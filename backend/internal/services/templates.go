@@ -0,0 +1,269 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/temanbatin/omnichannel/internal/config"
+	"github.com/temanbatin/omnichannel/internal/repositories"
+	"github.com/temanbatin/omnichannel/internal/types"
+	"github.com/temanbatin/omnichannel/pkg/meta"
+)
+
+// templateNamePattern is what Meta accepts as a template name
+var templateNamePattern = regexp.MustCompile(`^[a-z0-9_]{1,512}$`)
+
+// templateCategories are the categories a new template may be submitted under
+var templateCategories = map[string]bool{
+	"MARKETING":      true,
+	"UTILITY":        true,
+	"AUTHENTICATION": true,
+}
+
+// ErrTemplatesNotConfigured is returned when no WhatsApp Business Account is configured
+var ErrTemplatesNotConfigured = errors.New("WhatsApp Business Account not configured")
+
+// TemplateService manages WhatsApp message templates, mirroring the business account
+type TemplateService struct {
+	templateRepo   *repositories.TemplateRepository
+	whatsappClient *meta.WhatsAppClient
+	syncInterval   time.Duration
+}
+
+// NewTemplateService creates a new template service
+func NewTemplateService(templateRepo *repositories.TemplateRepository, cfg *config.Config) *TemplateService {
+	svc := &TemplateService{
+		templateRepo: templateRepo,
+		syncInterval: cfg.TemplateSyncInterval,
+	}
+	if cfg.MetaAccessToken != "" && cfg.WhatsAppBusinessID != "" {
+		svc.whatsappClient = meta.NewWhatsAppClient(
+			cfg.MetaAccessToken,
+			cfg.WhatsAppPhoneID,
+			cfg.WhatsAppBusinessID,
+		)
+	}
+	return svc
+}
+
+// ListTemplates returns templates, optionally only those with the given status
+func (s *TemplateService) ListTemplates(ctx context.Context, status types.TemplateStatus) ([]*types.Template, error) {
+	return s.templateRepo.List(ctx, status)
+}
+
+// GetTemplate returns a single template
+func (s *TemplateService) GetTemplate(ctx context.Context, id string) (*types.Template, error) {
+	return s.templateRepo.GetByID(ctx, id)
+}
+
+// CreateTemplate submits a template to Meta for approval and stores it with the status Meta returned
+func (s *TemplateService) CreateTemplate(ctx context.Context, req *types.CreateTemplateRequest) (*types.Template, error) {
+	if err := requirePermission(ctx, types.PermManageTemplates); err != nil {
+		return nil, err
+	}
+	if s.whatsappClient == nil {
+		return nil, ErrTemplatesNotConfigured
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	req.Language = strings.TrimSpace(req.Language)
+	req.Category = strings.ToUpper(strings.TrimSpace(req.Category))
+	if !templateNamePattern.MatchString(req.Name) {
+		return nil, fmt.Errorf("%w: name may only contain lowercase letters, digits and underscores", ErrInvalidInput)
+	}
+	if req.Language == "" {
+		return nil, fmt.Errorf("%w: language is required", ErrInvalidInput)
+	}
+	if !templateCategories[req.Category] {
+		return nil, fmt.Errorf("%w: category must be MARKETING, UTILITY or AUTHENTICATION", ErrInvalidInput)
+	}
+	content, err := templateBody(req.Components)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fail before submitting rather than leave a template at Meta we cannot store
+	if _, err := s.templateRepo.GetByNameAndLanguage(ctx, req.Name, req.Language); err == nil {
+		return nil, fmt.Errorf("template %w", repositories.ErrDuplicate)
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	resp, err := s.whatsappClient.CreateTemplate(&meta.MessageTemplate{
+		Name:       req.Name,
+		Language:   req.Language,
+		Category:   req.Category,
+		Components: req.Components,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to submit template: %w", err)
+	}
+
+	category := req.Category
+	if resp.Category != "" {
+		category = resp.Category
+	}
+	now := time.Now()
+	template := &types.Template{
+		ID:         uuid.New().String(),
+		Platform:   types.PlatformWhatsApp,
+		Name:       req.Name,
+		Language:   req.Language,
+		Category:   category,
+		Status:     templateStatus(resp.Status),
+		Content:    content,
+		Components: req.Components,
+		ExternalID: resp.ID,
+		SyncedAt:   &now,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := s.templateRepo.Create(ctx, template); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+// DeleteTemplate deletes a template from the business account and then locally
+func (s *TemplateService) DeleteTemplate(ctx context.Context, id string) error {
+	if err := requirePermission(ctx, types.PermManageTemplates); err != nil {
+		return err
+	}
+
+	template, err := s.templateRepo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if template.ExternalID != "" && template.Status != types.TemplateDeleted {
+		if s.whatsappClient == nil {
+			return ErrTemplatesNotConfigured
+		}
+		if err := s.whatsappClient.DeleteTemplate(template.Name, template.ExternalID); err != nil {
+			return fmt.Errorf("failed to delete template: %w", err)
+		}
+	}
+
+	return s.templateRepo.Delete(ctx, template.ID)
+}
+
+// SyncTemplates pulls every template and its approval status from the business account.
+// Submitted templates that no longer exist there are marked deleted.
+func (s *TemplateService) SyncTemplates(ctx context.Context) (*types.TemplateSyncResult, error) {
+	if err := requirePermission(ctx, types.PermManageTemplates); err != nil {
+		return nil, err
+	}
+	if s.whatsappClient == nil {
+		return nil, ErrTemplatesNotConfigured
+	}
+
+	// Truncated so that rows stored in this run compare equal after a database round trip
+	started := time.Now().Truncate(time.Microsecond)
+	result := &types.TemplateSyncResult{}
+	after := ""
+	for {
+		page, err := s.whatsappClient.ListTemplates(after)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list templates: %w", err)
+		}
+
+		for _, remote := range page.Data {
+			content, _ := templateBody(remote.Components)
+			components := remote.Components
+			if len(components) == 0 {
+				components = json.RawMessage("[]")
+			}
+			template := &types.Template{
+				ID:             uuid.New().String(),
+				Platform:       types.PlatformWhatsApp,
+				Name:           remote.Name,
+				Language:       remote.Language,
+				Category:       remote.Category,
+				Status:         templateStatus(remote.Status),
+				Content:        content,
+				Components:     components,
+				ExternalID:     remote.ID,
+				RejectedReason: remote.RejectedReason,
+				SyncedAt:       &started,
+				CreatedAt:      started,
+				UpdatedAt:      started,
+			}
+			if err := s.templateRepo.Upsert(ctx, template); err != nil {
+				return nil, fmt.Errorf("failed to store template %s: %w", remote.Name, err)
+			}
+			result.Synced++
+		}
+
+		if page.Paging.Next == "" || page.Paging.Cursors.After == "" {
+			break
+		}
+		after = page.Paging.Cursors.After
+	}
+
+	deleted, err := s.templateRepo.MarkDeletedUnless(ctx, started)
+	if err != nil {
+		return nil, err
+	}
+	result.Deleted = deleted
+	return result, nil
+}
+
+// RunSync syncs templates on the configured interval until ctx is cancelled
+func (s *TemplateService) RunSync(ctx context.Context) {
+	if s.whatsappClient == nil || s.syncInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(s.syncInterval)
+	defer ticker.Stop()
+
+	for {
+		if result, err := s.SyncTemplates(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Failed to sync WhatsApp templates: %v", err)
+		} else if result.Deleted > 0 {
+			log.Printf("Synced %d WhatsApp templates, %d no longer exist", result.Synced, result.Deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// templateBody validates template components and returns the BODY text
+func templateBody(components json.RawMessage) (string, error) {
+	var parsed []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(components, &parsed); err != nil {
+		return "", fmt.Errorf("%w: components must be an array of template components", ErrInvalidInput)
+	}
+	for _, component := range parsed {
+		if strings.EqualFold(component.Type, "BODY") && component.Text != "" {
+			return component.Text, nil
+		}
+	}
+	return "", fmt.Errorf("%w: components must include a BODY with text", ErrInvalidInput)
+}
+
+// templateStatus maps a Meta status such as APPROVED to a TemplateStatus
+func templateStatus(status string) types.TemplateStatus {
+	if status == "" {
+		return types.TemplatePending
+	}
+	return types.TemplateStatus(strings.ToLower(status))
+}
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/temanbatin/omnichannel/internal/auth"
+	"github.com/temanbatin/omnichannel/internal/repositories"
+	"github.com/temanbatin/omnichannel/internal/types"
+)
+
+// ErrInvalidInput wraps validation failures of user-supplied data
+var ErrInvalidInput = errors.New("invalid input")
+
+// UserService manages dashboard users and teams
+type UserService struct {
+	userRepo         *repositories.UserRepository
+	teamRepo         *repositories.TeamRepository
+	refreshTokenRepo *repositories.RefreshTokenRepository
+}
+
+// NewUserService creates a new user service
+func NewUserService(
+	userRepo *repositories.UserRepository,
+	teamRepo *repositories.TeamRepository,
+	refreshTokenRepo *repositories.RefreshTokenRepository,
+) *UserService {
+	return &UserService{
+		userRepo:         userRepo,
+		teamRepo:         teamRepo,
+		refreshTokenRepo: refreshTokenRepo,
+	}
+}
+
+// ListUsers returns all users. Supervisors may list users to reassign conversations.
+func (s *UserService) ListUsers(ctx context.Context) ([]*types.User, error) {
+	if err := requireAnyPermission(ctx, types.PermManageUsers, types.PermAssignConversations); err != nil {
+		return nil, err
+	}
+	return s.userRepo.List(ctx)
+}
+
+// CreateUser creates a new dashboard account
+func (s *UserService) CreateUser(ctx context.Context, req *types.CreateUserRequest) (*types.User, error) {
+	if err := requirePermission(ctx, types.PermManageUsers); err != nil {
+		return nil, err
+	}
+
+	req.Email = strings.TrimSpace(req.Email)
+	if req.Email == "" || req.Name == "" {
+		return nil, fmt.Errorf("%w: email and name are required", ErrInvalidInput)
+	}
+	if len(req.Password) < 8 {
+		return nil, fmt.Errorf("%w: password must be at least 8 characters", ErrInvalidInput)
+	}
+	if req.Role == "" {
+		req.Role = types.RoleAgent
+	}
+	if !req.Role.Valid() {
+		return nil, fmt.Errorf("%w: unknown role %q", ErrInvalidInput, req.Role)
+	}
+	if err := s.checkTeam(ctx, req.TeamID); err != nil {
+		return nil, err
+	}
+
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	now := time.Now()
+	user := &types.User{
+		ID:           uuid.New().String(),
+		Email:        req.Email,
+		Name:         req.Name,
+		Role:         req.Role,
+		TeamID:       req.TeamID,
+		PasswordHash: hash,
+		IsActive:     true,
+		Availability: types.AvailabilityOffline,
+		Skills:       []string{},
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// UpdateUser changes a user's profile, role, team, password or active flag.
+// Existing sessions are revoked so the change applies on the next login.
+func (s *UserService) UpdateUser(ctx context.Context, id string, req *types.UpdateUserRequest) (*types.User, error) {
+	if err := requirePermission(ctx, types.PermManageUsers); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil && *req.Name != "" {
+		user.Name = *req.Name
+	}
+	if req.Role != nil {
+		if !req.Role.Valid() {
+			return nil, fmt.Errorf("%w: unknown role %q", ErrInvalidInput, *req.Role)
+		}
+		user.Role = *req.Role
+	}
+	if req.TeamID != nil {
+		if err := s.checkTeam(ctx, *req.TeamID); err != nil {
+			return nil, err
+		}
+		user.TeamID = *req.TeamID
+	}
+	if req.IsActive != nil {
+		user.IsActive = *req.IsActive
+	}
+	if req.MaxConcurrent != nil {
+		if *req.MaxConcurrent < 0 {
+			return nil, fmt.Errorf("%w: max_concurrent cannot be negative", ErrInvalidInput)
+		}
+		user.MaxConcurrent = *req.MaxConcurrent
+	}
+	if req.Skills != nil {
+		user.Skills = normalizeLabels(*req.Skills)
+	}
+	if req.Password != nil {
+		if len(*req.Password) < 8 {
+			return nil, fmt.Errorf("%w: password must be at least 8 characters", ErrInvalidInput)
+		}
+		if user.PasswordHash, err = auth.HashPassword(*req.Password); err != nil {
+			return nil, fmt.Errorf("failed to hash password: %w", err)
+		}
+	}
+
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	if err := s.refreshTokenRepo.RevokeAllForUser(ctx, user.ID); err != nil {
+		return nil, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return user, nil
+}
+
+// UpdateAvailability sets whether the current user receives routed conversations
+func (s *UserService) UpdateAvailability(ctx context.Context, availability types.Availability) (*types.User, error) {
+	current := auth.UserFromContext(ctx)
+	if current == nil {
+		return nil, fmt.Errorf("%w: availability requires a dashboard user", ErrInvalidInput)
+	}
+	if !availability.Valid() {
+		return nil, fmt.Errorf("%w: unknown availability %q", ErrInvalidInput, availability)
+	}
+
+	if err := s.userRepo.UpdateAvailability(ctx, current.ID, availability); err != nil {
+		return nil, err
+	}
+	return s.userRepo.GetByID(ctx, current.ID)
+}
+
+// ListTeams returns all teams
+func (s *UserService) ListTeams(ctx context.Context) ([]*types.Team, error) {
+	if err := requireAnyPermission(ctx, types.PermManageUsers, types.PermAssignConversations); err != nil {
+		return nil, err
+	}
+	return s.teamRepo.List(ctx)
+}
+
+// CreateTeam creates a new team
+func (s *UserService) CreateTeam(ctx context.Context, name string) (*types.Team, error) {
+	if err := requirePermission(ctx, types.PermManageUsers); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(name) == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidInput)
+	}
+
+	now := time.Now()
+	team := &types.Team{
+		ID:        uuid.New().String(),
+		Name:      strings.TrimSpace(name),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.teamRepo.Create(ctx, team); err != nil {
+		return nil, err
+	}
+	return team, nil
+}
+
+func (s *UserService) checkTeam(ctx context.Context, teamID string) error {
+	if teamID == "" {
+		return nil
+	}
+	if _, err := uuid.Parse(teamID); err != nil {
+		return fmt.Errorf("%w: invalid team_id", ErrInvalidInput)
+	}
+	_, err := s.teamRepo.GetByID(ctx, teamID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%w: team not found", ErrInvalidInput)
+	}
+	return err
+}
@@ -0,0 +1,262 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/temanbatin/omnichannel/internal/repositories"
+	"github.com/temanbatin/omnichannel/internal/types"
+)
+
+const (
+	webhookPollInterval  = 2 * time.Second
+	webhookLockTimeout   = 5 * time.Minute
+	webhookEventTimeout  = 2 * time.Minute
+	webhookBaseBackoff   = 5 * time.Second
+	webhookMaxBackoff    = 30 * time.Minute
+	webhookIdleOnDBError = 10 * time.Second
+)
+
+var (
+	// ErrWebhookEventBusy is returned when replaying an event a worker is currently processing
+	ErrWebhookEventBusy = errors.New("webhook event is being processed")
+	// ErrWebhookUnverified is returned when replaying an event whose signature failed verification
+	ErrWebhookUnverified = errors.New("webhook event failed signature verification and cannot be replayed")
+	// ErrWebhookReplayed is returned when enqueueing a signed webhook whose signature was already stored
+	ErrWebhookReplayed = errors.New("webhook signature was already used")
+)
+
+// WebhookProcessor persists inbound webhooks and processes them with a worker pool
+type WebhookProcessor struct {
+	inboxRepo    *repositories.WebhookInboxRepository
+	messagingSvc *MessagingService
+	workers      int
+	maxAttempts  int
+
+	// wake nudges an idle worker when a new event is enqueued
+	wake chan struct{}
+}
+
+// NewWebhookProcessor creates a new webhook processor
+func NewWebhookProcessor(
+	inboxRepo *repositories.WebhookInboxRepository,
+	messagingSvc *MessagingService,
+	workers, maxAttempts int,
+) *WebhookProcessor {
+	if workers < 1 {
+		workers = 1
+	}
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	return &WebhookProcessor{
+		inboxRepo:    inboxRepo,
+		messagingSvc: messagingSvc,
+		workers:      workers,
+		maxAttempts:  maxAttempts,
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// Enqueue stores a raw webhook body. Once it returns nil the event will be processed
+// even if the server restarts, so it is safe to acknowledge the sender.
+// signatureHash is the accepted HMAC of a signed internal webhook, or "" for other sources;
+// if an event with the same hash was already stored it returns ErrWebhookReplayed.
+func (p *WebhookProcessor) Enqueue(ctx context.Context, source types.WebhookSource, body []byte, headers map[string]string, signature types.SignatureStatus, signatureHash string) (*types.WebhookEvent, error) {
+	event := newWebhookEvent(source, body, headers, signature, types.WebhookEventPending)
+	event.SignatureHash = signatureHash
+	err := p.inboxRepo.Create(ctx, event)
+	if errors.Is(err, repositories.ErrDuplicate) {
+		return nil, ErrWebhookReplayed
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to enqueue webhook: %w", err)
+	}
+
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+
+	return event, nil
+}
+
+// Reject records a webhook that will not be processed (bad signature, unparseable body)
+// so it can still be inspected. Only events whose signature did not fail can be replayed.
+func (p *WebhookProcessor) Reject(ctx context.Context, source types.WebhookSource, body []byte, headers map[string]string, signature types.SignatureStatus, reason string) error {
+	event := newWebhookEvent(source, body, headers, signature, types.WebhookEventRejected)
+	event.LastError = reason
+	if err := p.inboxRepo.Create(ctx, event); err != nil {
+		return fmt.Errorf("failed to record rejected webhook: %w", err)
+	}
+	return nil
+}
+
+// ListEvents returns stored webhook events matching the filter
+func (p *WebhookProcessor) ListEvents(ctx context.Context, filter types.WebhookEventFilter) ([]*types.WebhookEvent, int, error) {
+	return p.inboxRepo.List(ctx, filter)
+}
+
+// GetEvent returns a stored webhook event
+func (p *WebhookProcessor) GetEvent(ctx context.Context, id string) (*types.WebhookEvent, error) {
+	return p.inboxRepo.GetByID(ctx, id)
+}
+
+// Replay re-runs processing for a stored event synchronously and returns its new state.
+// A failed replay is dead-lettered rather than retried automatically. Events with an invalid
+// signature are kept for inspection only: their payload is unauthenticated and is never processed.
+func (p *WebhookProcessor) Replay(ctx context.Context, id string) (*types.WebhookEvent, error) {
+	event, err := p.inboxRepo.ClaimByID(ctx, id, webhookLockTimeout)
+	if errors.Is(err, pgx.ErrNoRows) {
+		stored, getErr := p.inboxRepo.GetByID(ctx, id)
+		if getErr != nil {
+			return nil, getErr
+		}
+		if stored.Signature == types.SignatureInvalid {
+			return nil, ErrWebhookUnverified
+		}
+		return nil, ErrWebhookEventBusy
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	eventCtx, cancel := context.WithTimeout(ctx, webhookEventTimeout)
+	processErr := p.process(eventCtx, event)
+	cancel()
+
+	if processErr == nil {
+		err = p.inboxRepo.MarkProcessed(ctx, event.ID)
+	} else {
+		err = p.inboxRepo.MarkDead(ctx, event.ID, processErr.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return p.inboxRepo.GetByID(ctx, event.ID)
+}
+
+func newWebhookEvent(source types.WebhookSource, body []byte, headers map[string]string, signature types.SignatureStatus, status types.WebhookEventStatus) *types.WebhookEvent {
+	now := time.Now()
+	return &types.WebhookEvent{
+		ID:            uuid.New().String(),
+		Source:        source,
+		Payload:       string(body),
+		Headers:       headers,
+		Signature:     signature,
+		Status:        status,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+}
+
+// Run starts the worker pool and blocks until ctx is cancelled and all workers have stopped
+func (p *WebhookProcessor) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < p.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.work(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (p *WebhookProcessor) work(ctx context.Context) {
+	for {
+		event, err := p.inboxRepo.ClaimNext(ctx, webhookLockTimeout)
+		if err == nil {
+			p.handle(ctx, event)
+			continue
+		}
+
+		wait := webhookPollInterval
+		if !errors.Is(err, pgx.ErrNoRows) {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Failed to claim webhook event: %v", err)
+			wait = webhookIdleOnDBError
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.wake:
+		case <-time.After(wait):
+		}
+	}
+}
+
+// handle processes a claimed event and records the outcome
+func (p *WebhookProcessor) handle(ctx context.Context, event *types.WebhookEvent) {
+	eventCtx, cancel := context.WithTimeout(ctx, webhookEventTimeout)
+	err := p.process(eventCtx, event)
+	cancel()
+
+	// Record the outcome even if shutdown cancelled ctx mid-event
+	recordCtx, cancelRecord := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelRecord()
+
+	if err == nil {
+		if err := p.inboxRepo.MarkProcessed(recordCtx, event.ID); err != nil {
+			log.Printf("Failed to mark webhook event %s processed: %v", event.ID, err)
+		}
+		return
+	}
+
+	if event.Attempts >= p.maxAttempts {
+		log.Printf("Webhook event %s dead after %d attempts: %v", event.ID, event.Attempts, err)
+		if err := p.inboxRepo.MarkDead(recordCtx, event.ID, err.Error()); err != nil {
+			log.Printf("Failed to mark webhook event %s dead: %v", event.ID, err)
+		}
+		return
+	}
+
+	next := time.Now().Add(webhookBackoff(event.Attempts))
+	log.Printf("Webhook event %s failed (attempt %d), retrying at %s: %v", event.ID, event.Attempts, next.Format(time.RFC3339), err)
+	if err := p.inboxRepo.MarkRetry(recordCtx, event.ID, err.Error(), next); err != nil {
+		log.Printf("Failed to reschedule webhook event %s: %v", event.ID, err)
+	}
+}
+
+// process dispatches an event to the messaging service based on its source
+func (p *WebhookProcessor) process(ctx context.Context, event *types.WebhookEvent) error {
+	var payload types.WebhookPayload
+	if err := json.Unmarshal([]byte(event.Payload), &payload); err != nil {
+		return fmt.Errorf("invalid payload: %w", err)
+	}
+
+	switch event.Source {
+	case types.WebhookSourceWhatsApp, types.WebhookSourceN8N:
+		return p.messagingSvc.ProcessIncomingWhatsApp(ctx, &payload)
+	case types.WebhookSourceInstagram:
+		return p.messagingSvc.ProcessIncomingInstagram(ctx, &payload)
+	default:
+		return fmt.Errorf("unknown webhook source: %s", event.Source)
+	}
+}
+
+// webhookBackoff returns an exponential delay with jitter for the given attempt number
+func webhookBackoff(attempt int) time.Duration {
+	delay := webhookBaseBackoff
+	for i := 1; i < attempt && delay < webhookMaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > webhookMaxBackoff {
+		delay = webhookMaxBackoff
+	}
+	jitter := time.Duration(rand.Int63n(int64(delay) / 5))
+	return delay + jitter
+}
@@ -1,6 +1,14 @@
 package types
 
-import "time"
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
 
 // Platform represents messaging platform type
 type Platform string
@@ -12,6 +20,15 @@ const (
 	PlatformWeb       Platform = "web"
 )
 
+// Valid reports whether p is a known platform
+func (p Platform) Valid() bool {
+	switch p {
+	case PlatformWhatsApp, PlatformInstagram, PlatformMessenger, PlatformWeb:
+		return true
+	}
+	return false
+}
+
 // MessageDirection represents message direction
 type MessageDirection string
 
@@ -31,20 +48,71 @@ const (
 	StatusFailed    MessageStatus = "failed"
 )
 
+// statusRank orders delivery statuses so callbacks can only move a message forward
+var statusRank = map[MessageStatus]int{
+	StatusPending:   0,
+	StatusSent:      1,
+	StatusDelivered: 2,
+	StatusRead:      3,
+}
+
+// CanTransitionTo reports whether a message in status s may move to next.
+// Statuses only move forward (read is never downgraded to delivered), and
+// failed is only accepted before the message reached the recipient.
+func (s MessageStatus) CanTransitionTo(next MessageStatus) bool {
+	if s == next || s == StatusFailed {
+		return false
+	}
+	if next == StatusFailed {
+		return s == StatusPending || s == StatusSent
+	}
+	nextRank, ok := statusRank[next]
+	if !ok {
+		return false
+	}
+	return nextRank > statusRank[s]
+}
+
+// TransitionSources returns every status a message may move to next from,
+// so the same rule can guard a conditional UPDATE
+func TransitionSources(next MessageStatus) []MessageStatus {
+	var sources []MessageStatus
+	for _, s := range []MessageStatus{StatusPending, StatusSent, StatusDelivered, StatusRead, StatusFailed} {
+		if s.CanTransitionTo(next) {
+			sources = append(sources, s)
+		}
+	}
+	return sources
+}
+
 // Message represents a chat message
 type Message struct {
 	ID             string           `json:"id"`
 	ConversationID string           `json:"conversation_id"`
 	Platform       Platform         `json:"platform"`
 	Direction      MessageDirection `json:"direction"`
+	SenderID       string           `json:"sender_id,omitempty"` // Dashboard user who sent an outbound message
 	Content        string           `json:"content"`
 	ContentType    string           `json:"content_type"` // text, image, video, etc
 	Status         MessageStatus    `json:"status"`
 	ExternalID     string           `json:"external_id"` // Meta message ID
+	Attachment     *Attachment      `json:"attachment,omitempty"`
+	ErrorCode      int              `json:"error_code,omitempty"`
+	ErrorTitle     string           `json:"error_title,omitempty"`
 	CreatedAt      time.Time        `json:"created_at"`
 	UpdatedAt      time.Time        `json:"updated_at"`
 }
 
+// Attachment represents media carried by a message
+type Attachment struct {
+	MediaID  string `json:"media_id,omitempty"` // Meta media ID
+	URL      string `json:"url,omitempty"`      // Where the dashboard can load the file
+	MimeType string `json:"mime_type,omitempty"`
+	Filename string `json:"filename,omitempty"`
+	SHA256   string `json:"sha256,omitempty"`
+	Size     int64  `json:"size,omitempty"`
+}
+
 // Conversation represents a chat conversation
 type Conversation struct {
 	ID              string    `json:"id"`
@@ -54,12 +122,454 @@ type Conversation struct {
 	LastMessageAt   time.Time `json:"last_message_at"`
 	LastMessageText string    `json:"last_message_text"`
 	UnreadCount     int       `json:"unread_count"`
-	CreatedAt       time.Time `json:"created_at"`
-	UpdatedAt       time.Time `json:"updated_at"`
+
+	LastMessageDirection MessageDirection `json:"last_message_direction,omitempty"`
+	LastMessageSenderID  string           `json:"last_message_sender_id,omitempty"`
+	LastInboundAt        *time.Time       `json:"last_inbound_at,omitempty"`
+	LastOutboundAt       *time.Time       `json:"last_outbound_at,omitempty"`
+
+	// Whether free-form replies are allowed; WhatsApp only allows them within
+	// WhatsAppServiceWindow of the customer's last message
+	WindowOpen      bool       `json:"window_open"`
+	WindowExpiresAt *time.Time `json:"window_expires_at,omitempty"`
+
+	AssigneeID string   `json:"assignee_id,omitempty"`
+	TeamID     string   `json:"team_id,omitempty"`
+	Tags       []string `json:"tags"`
+
+	Status       ConversationStatus `json:"status"`
+	SnoozedUntil *time.Time         `json:"snoozed_until,omitempty"`
+	ResolvedAt   *time.Time         `json:"resolved_at,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 
 	// Joined data
-	Contact  *Contact   `json:"contact,omitempty"`
-	Messages []*Message `json:"messages,omitempty"`
+	Contact            *Contact            `json:"contact,omitempty"`
+	Messages           []*Message          `json:"messages,omitempty"`
+	MessagesNextCursor string              `json:"messages_next_cursor,omitempty"` // Loads older messages
+	Reads              []*ConversationRead `json:"reads,omitempty"`
+}
+
+// WhatsAppServiceWindow is how long after a customer's last message WhatsApp accepts
+// free-form replies. Outside it only approved templates can be sent.
+const WhatsAppServiceWindow = 24 * time.Hour
+
+// SetServiceWindow fills WindowOpen and WindowExpiresAt as of now
+func (c *Conversation) SetServiceWindow(now time.Time) {
+	c.WindowExpiresAt = nil
+	if c.Platform != PlatformWhatsApp {
+		c.WindowOpen = true
+		return
+	}
+	if c.LastInboundAt == nil {
+		c.WindowOpen = false
+		return
+	}
+	expires := c.LastInboundAt.Add(WhatsAppServiceWindow)
+	c.WindowExpiresAt = &expires
+	c.WindowOpen = now.Before(expires)
+}
+
+// ConversationRead is how far a dashboard user has read a conversation
+type ConversationRead struct {
+	ConversationID    string    `json:"conversation_id"`
+	UserID            string    `json:"user_id"`
+	UserName          string    `json:"user_name,omitempty"`
+	LastReadMessageID string    `json:"last_read_message_id,omitempty"`
+	LastReadAt        time.Time `json:"last_read_at"`
+}
+
+// MarkReadResponse reports what marking a conversation read acknowledged
+type MarkReadResponse struct {
+	ConversationID    string    `json:"conversation_id"`
+	LastReadMessageID string    `json:"last_read_message_id,omitempty"`
+	LastReadAt        time.Time `json:"last_read_at"`
+	Acknowledged      int       `json:"acknowledged"`
+}
+
+// ConversationStatus represents where a conversation is in its lifecycle
+type ConversationStatus string
+
+const (
+	ConversationOpen     ConversationStatus = "open"
+	ConversationPending  ConversationStatus = "pending" // Waiting on the customer
+	ConversationSnoozed  ConversationStatus = "snoozed" // Hidden until SnoozedUntil
+	ConversationResolved ConversationStatus = "resolved"
+)
+
+// Valid reports whether s is a known conversation status
+func (s ConversationStatus) Valid() bool {
+	switch s {
+	case ConversationOpen, ConversationPending, ConversationSnoozed, ConversationResolved:
+		return true
+	}
+	return false
+}
+
+// UpdateConversationStatusRequest moves a conversation to a new status.
+// SnoozedUntil is required when snoozing.
+type UpdateConversationStatusRequest struct {
+	Status       ConversationStatus `json:"status"`
+	SnoozedUntil *time.Time         `json:"snoozed_until"`
+}
+
+// ConversationStatusChange is the data of a conversation.status_changed event
+type ConversationStatusChange struct {
+	ConversationID string             `json:"conversation_id"`
+	PreviousStatus ConversationStatus `json:"previous_status"`
+	Status         ConversationStatus `json:"status"`
+	SnoozedUntil   *time.Time         `json:"snoozed_until,omitempty"`
+	Reason         string             `json:"reason"` // "manual", "inbound_message" or "snooze_expired"
+}
+
+// ConversationScope restricts conversation queries to those assigned to a user or their team.
+// A nil scope means no restriction.
+type ConversationScope struct {
+	UserID string
+	TeamID string
+}
+
+// ConversationFilter narrows a conversation listing within the caller's scope
+type ConversationFilter struct {
+	Scope      *ConversationScope
+	Mine       bool   // Only conversations assigned to the caller
+	AssigneeID string // Only conversations assigned to this user
+	Unassigned bool   // Only conversations without an assignee
+	TeamID     string
+	Platform   Platform
+	Statuses   []ConversationStatus
+	Tag        string
+	UnreadOnly bool
+	Since      *time.Time // Last message at or after
+	Until      *time.Time // Last message before
+	After      *Cursor    // Continue after this position, ordered by last_message_at
+	Limit      int
+}
+
+// ConversationPage is one page of a conversation list
+type ConversationPage struct {
+	Conversations []*Conversation `json:"conversations"`
+	Total         int             `json:"total"`
+	NextCursor    string          `json:"next_cursor,omitempty"`
+}
+
+// MessageFilter selects a page of a conversation's messages, newest first
+type MessageFilter struct {
+	ConversationID string
+	After          *Cursor // Continue with messages older than this position
+	Limit          int
+}
+
+// MessagePage is one page of a conversation's messages
+type MessagePage struct {
+	Messages   []*Message `json:"messages"`
+	Total      int        `json:"total"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+// ContactFilter selects a page of contacts, newest first
+type ContactFilter struct {
+	Scope *ConversationScope // Only contacts with a conversation in this scope
+	After *Cursor
+	Limit int
+}
+
+// ContactPage is one page of the contact list
+type ContactPage struct {
+	Contacts   []*Contact `json:"contacts"`
+	Total      int        `json:"total"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+// TemplateStatus is a template's review state in the WhatsApp Business Account
+type TemplateStatus string
+
+const (
+	TemplatePending  TemplateStatus = "pending"
+	TemplateApproved TemplateStatus = "approved"
+	TemplateRejected TemplateStatus = "rejected"
+	TemplatePaused   TemplateStatus = "paused"
+	TemplateDisabled TemplateStatus = "disabled"
+	TemplateDeleted  TemplateStatus = "deleted" // No longer in the business account
+)
+
+// Template is a WhatsApp message template
+type Template struct {
+	ID             string          `json:"id"`
+	Platform       Platform        `json:"platform"`
+	Name           string          `json:"name"`
+	Language       string          `json:"language"`
+	Category       string          `json:"category"` // MARKETING, UTILITY or AUTHENTICATION
+	Status         TemplateStatus  `json:"status"`
+	Content        string          `json:"content"`    // Body text, with {{n}} placeholders
+	Components     json.RawMessage `json:"components"` // Meta component definitions
+	ExternalID     string          `json:"external_id,omitempty"`
+	RejectedReason string          `json:"rejected_reason,omitempty"`
+	SyncedAt       *time.Time      `json:"synced_at,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// CreateTemplateRequest submits a new template for approval
+type CreateTemplateRequest struct {
+	Name       string          `json:"name"`
+	Language   string          `json:"language"`
+	Category   string          `json:"category"`
+	Components json.RawMessage `json:"components"`
+}
+
+// TemplateParams fills a template's variables. Body parameters are positional; buttons
+// are addressed by their position in the template.
+type TemplateParams struct {
+	Header  *TemplateParam        `json:"header,omitempty"`
+	Body    []TemplateParam       `json:"body,omitempty"`
+	Buttons []TemplateButtonParam `json:"buttons,omitempty"`
+}
+
+// TemplateParam is the value of one header or body variable
+type TemplateParam struct {
+	Type     string             `json:"type"`           // text (default), currency, date_time, image, document or video
+	Name     string             `json:"name,omitempty"` // For templates with named parameters
+	Text     string             `json:"text,omitempty"`
+	Currency *TemplateCurrency  `json:"currency,omitempty"`
+	DateTime string             `json:"date_time,omitempty"` // Shown as given
+	Media    *TemplateMediaLink `json:"media,omitempty"`
+
+	// Variable fills a text or date_time value from the recipient's contact instead:
+	// contact.name, contact.phone, contact.email or metadata.<key> (dots reach nested keys).
+	// Fallback is used when the contact has no value; without one the recipient is skipped.
+	Variable string `json:"variable,omitempty"`
+	Fallback string `json:"fallback,omitempty"`
+}
+
+// TemplateCurrency is an amount shown in the recipient's locale, or as FallbackValue
+type TemplateCurrency struct {
+	Code          string `json:"code"`        // ISO 4217
+	Amount1000    int64  `json:"amount_1000"` // Amount multiplied by 1000
+	FallbackValue string `json:"fallback_value"`
+}
+
+// TemplateMediaLink is header media, either uploaded through POST /api/media or a public URL
+type TemplateMediaLink struct {
+	MediaID  string `json:"media_id,omitempty"`
+	URL      string `json:"url,omitempty"`
+	Filename string `json:"filename,omitempty"`
+}
+
+// TemplateButtonParam fills a quick-reply payload or the dynamic suffix of a URL button
+type TemplateButtonParam struct {
+	Index    int    `json:"index"`
+	Payload  string `json:"payload,omitempty"`  // Quick-reply buttons
+	Text     string `json:"text,omitempty"`     // URL buttons
+	Variable string `json:"variable,omitempty"` // As on TemplateParam, fills the payload or text
+	Fallback string `json:"fallback,omitempty"`
+}
+
+// SendTemplateRequest sends an approved template into a WhatsApp conversation
+type SendTemplateRequest struct {
+	TemplateID string         `json:"template_id"`
+	Params     TemplateParams `json:"params"`
+}
+
+// TemplateSyncResult reports what a template sync changed
+type TemplateSyncResult struct {
+	Synced  int `json:"synced"`
+	Deleted int `json:"deleted"`
+}
+
+// BroadcastStatus is where a broadcast campaign is in its lifecycle
+type BroadcastStatus string
+
+const (
+	BroadcastDraft     BroadcastStatus = "draft"
+	BroadcastScheduled BroadcastStatus = "scheduled" // Waiting for scheduled_at
+	BroadcastSending   BroadcastStatus = "sending"
+	BroadcastPaused    BroadcastStatus = "paused"
+	BroadcastCompleted BroadcastStatus = "completed"
+	BroadcastCancelled BroadcastStatus = "cancelled"
+	BroadcastFailed    BroadcastStatus = "failed" // Stopped by an error that affects every recipient
+)
+
+// Broadcast sends one approved WhatsApp template to many contacts
+type Broadcast struct {
+	ID              string          `json:"id"`
+	Name            string          `json:"name"`
+	TemplateID      string          `json:"template_id"`
+	Platform        Platform        `json:"platform"`
+	Status          BroadcastStatus `json:"status"`
+	TemplateParams  TemplateParams  `json:"template_params"`
+	TotalRecipients int             `json:"total_recipients"`
+	SentCount       int             `json:"sent_count"`
+	DeliveredCount  int             `json:"delivered_count"`
+	FailedCount     int             `json:"failed_count"`
+	SkippedCount    int             `json:"skipped_count"` // Missing a value for a template variable
+	LastError       string          `json:"last_error,omitempty"`
+	CreatedBy       string          `json:"created_by,omitempty"`
+	ScheduledAt     *time.Time      `json:"scheduled_at,omitempty"`
+	StartedAt       *time.Time      `json:"started_at,omitempty"`
+	CompletedAt     *time.Time      `json:"completed_at,omitempty"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+}
+
+// BroadcastAudience selects a broadcast's recipients. Contact IDs and a tag may be combined;
+// a tag matches contacts whose WhatsApp conversation carries it.
+type BroadcastAudience struct {
+	ContactIDs []string `json:"contact_ids,omitempty"`
+	Tag        string   `json:"tag,omitempty"`
+}
+
+// CreateBroadcastRequest creates a draft broadcast. More recipients can be added with a CSV upload.
+type CreateBroadcastRequest struct {
+	Name       string            `json:"name"`
+	TemplateID string            `json:"template_id"`
+	Params     TemplateParams    `json:"params"`
+	Audience   BroadcastAudience `json:"audience"`
+}
+
+// ScheduleBroadcastRequest starts a draft broadcast at ScheduledAt, or now when it is empty
+type ScheduleBroadcastRequest struct {
+	ScheduledAt *time.Time `json:"scheduled_at"`
+}
+
+// BroadcastAudienceResult reports how an audience was added to a broadcast
+type BroadcastAudienceResult struct {
+	Added     int      `json:"added"`
+	Duplicate int      `json:"duplicate"`         // Already a recipient
+	Invalid   []string `json:"invalid,omitempty"` // Rows or contacts that could not be added, with the reason
+}
+
+// PreviewBroadcastRequest renders a broadcast's message for one contact. It previews an
+// existing broadcast when BroadcastID is set, otherwise TemplateID and Params. The contact
+// is a stored one by ContactID or a sample given inline; with neither, variables show
+// their names.
+type PreviewBroadcastRequest struct {
+	BroadcastID string         `json:"broadcast_id,omitempty"`
+	TemplateID  string         `json:"template_id,omitempty"`
+	Params      TemplateParams `json:"params"`
+	ContactID   string         `json:"contact_id,omitempty"`
+	Contact     *Contact       `json:"contact,omitempty"`
+}
+
+// BroadcastPreview is a broadcast message as one recipient would receive it
+type BroadcastPreview struct {
+	Header     string          `json:"header,omitempty"` // Text headers only
+	Content    string          `json:"content"`
+	Params     *TemplateParams `json:"params,omitempty"`      // With variables filled in
+	Skipped    bool            `json:"skipped"`               // The recipient would be skipped
+	SkipReason string          `json:"skip_reason,omitempty"` // Why
+}
+
+// BroadcastRecipientStatus tracks one recipient's message
+type BroadcastRecipientStatus string
+
+const (
+	RecipientPending   BroadcastRecipientStatus = "pending"
+	RecipientSending   BroadcastRecipientStatus = "sending" // Claimed by the worker
+	RecipientSent      BroadcastRecipientStatus = "sent"
+	RecipientDelivered BroadcastRecipientStatus = "delivered"
+	RecipientRead      BroadcastRecipientStatus = "read"
+	RecipientFailed    BroadcastRecipientStatus = "failed"
+	RecipientCancelled BroadcastRecipientStatus = "cancelled" // Broadcast cancelled before sending
+	RecipientSkipped   BroadcastRecipientStatus = "skipped"   // Contact lacks a value a template variable needs
+)
+
+// BroadcastRecipient is one contact in a broadcast and the message sent to them
+type BroadcastRecipient struct {
+	ID          string                   `json:"id"`
+	BroadcastID string                   `json:"broadcast_id"`
+	ContactID   string                   `json:"contact_id"`
+	ContactName string                   `json:"contact_name,omitempty"`
+	WhatsAppID  string                   `json:"whatsapp_id"`
+	Status      BroadcastRecipientStatus `json:"status"`
+	MessageID   string                   `json:"message_id,omitempty"`
+	ExternalID  string                   `json:"external_id,omitempty"`
+	ErrorCode   int                      `json:"error_code,omitempty"`
+	ErrorTitle  string                   `json:"error_title,omitempty"`
+	SentAt      *time.Time               `json:"sent_at,omitempty"`
+	DeliveredAt *time.Time               `json:"delivered_at,omitempty"`
+	ReadAt      *time.Time               `json:"read_at,omitempty"`
+	CreatedAt   time.Time                `json:"created_at"`
+	UpdatedAt   time.Time                `json:"updated_at"`
+}
+
+// BroadcastRecipientFilter narrows a broadcast's recipient list
+type BroadcastRecipientFilter struct {
+	BroadcastID string
+	Status      BroadcastRecipientStatus
+	After       *Cursor
+	Limit       int
+}
+
+// BroadcastRecipientPage is one page of a broadcast's recipients
+type BroadcastRecipientPage struct {
+	Recipients []*BroadcastRecipient `json:"recipients"`
+	Total      int                   `json:"total"`
+	NextCursor string                `json:"next_cursor,omitempty"`
+}
+
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor is a keyset pagination position: the sort timestamp and ID of the last item
+// on the previous page. Clients treat its encoded form as opaque.
+type Cursor struct {
+	At time.Time `json:"t"`
+	ID string    `json:"id"`
+}
+
+// Encode returns the cursor as a URL-safe token
+func (c *Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a token produced by Cursor.Encode
+func DecodeCursor(token string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if _, err := uuid.Parse(cursor.ID); err != nil || cursor.At.IsZero() {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// AssignmentAction is a change recorded in a conversation's assignment history
+type AssignmentAction string
+
+const (
+	AssignmentAssign   AssignmentAction = "assign"
+	AssignmentUnassign AssignmentAction = "unassign"
+	AssignmentClaim    AssignmentAction = "claim"
+	AssignmentRoute    AssignmentAction = "route" // Automatic routing
+)
+
+// ConversationAssignment is one entry of a conversation's assignment history
+type ConversationAssignment struct {
+	ID             string           `json:"id"`
+	ConversationID string           `json:"conversation_id"`
+	Action         AssignmentAction `json:"action"`
+	AssigneeID     string           `json:"assignee_id,omitempty"`
+	AssigneeName   string           `json:"assignee_name,omitempty"`
+	TeamID         string           `json:"team_id,omitempty"`
+	TeamName       string           `json:"team_name,omitempty"`
+	ActorID        string           `json:"actor_id,omitempty"` // Empty for system actions
+	ActorName      string           `json:"actor_name,omitempty"`
+	CreatedAt      time.Time        `json:"created_at"`
+}
+
+// AssignConversationRequest assigns a conversation to an agent and/or a team.
+// A nil TeamID keeps the current team; an empty one removes it.
+type AssignConversationRequest struct {
+	AssigneeID string  `json:"assignee_id"`
+	TeamID     *string `json:"team_id"`
 }
 
 // Contact represents a customer/contact
@@ -79,10 +589,13 @@ type Contact struct {
 // SendMessageRequest represents outgoing message request
 type SendMessageRequest struct {
 	ConversationID string   `json:"conversation_id"`
-	Platform       Platform `json:"platform"`
-	RecipientID    string   `json:"recipient_id"` // Phone number or IG user ID
-	Content        string   `json:"content"`
+	Platform       Platform `json:"platform"`     // Taken from the conversation when one is given
+	RecipientID    string   `json:"recipient_id"` // Phone number or IG user ID; taken from the conversation when one is given
+	Content        string   `json:"content"`      // Caption when an attachment is sent
 	ContentType    string   `json:"content_type"`
+
+	// Attachment comes from POST /api/media; WhatsApp needs MediaID, Instagram needs URL
+	Attachment *Attachment `json:"attachment,omitempty"`
 }
 
 // WebhookPayload represents incoming Meta webhook
@@ -104,15 +617,8 @@ type WebhookPayload struct {
 					} `json:"profile"`
 					WaID string `json:"wa_id"`
 				} `json:"contacts"`
-				Messages []struct {
-					ID        string `json:"id"`
-					From      string `json:"from"`
-					Timestamp string `json:"timestamp"`
-					Type      string `json:"type"`
-					Text      struct {
-						Body string `json:"body"`
-					} `json:"text"`
-				} `json:"messages"`
+				Messages []WhatsAppMessage `json:"messages"`
+				Statuses []WhatsAppStatus  `json:"statuses"`
 			} `json:"value"`
 			Field string `json:"field"`
 		} `json:"changes"`
@@ -131,3 +637,490 @@ type WebhookPayload struct {
 		} `json:"messaging"`
 	} `json:"entry"`
 }
+
+// WhatsAppMessage represents an inbound WhatsApp message
+type WhatsAppMessage struct {
+	ID        string `json:"id"`
+	From      string `json:"from"`
+	Timestamp string `json:"timestamp"`
+	Type      string `json:"type"`
+	Text      struct {
+		Body string `json:"body"`
+	} `json:"text"`
+	Image    *WhatsAppMedia `json:"image,omitempty"`
+	Audio    *WhatsAppMedia `json:"audio,omitempty"`
+	Video    *WhatsAppMedia `json:"video,omitempty"`
+	Document *WhatsAppMedia `json:"document,omitempty"`
+	Sticker  *WhatsAppMedia `json:"sticker,omitempty"`
+}
+
+// Media returns the media object matching the message type, or nil for non-media messages
+func (m *WhatsAppMessage) Media() *WhatsAppMedia {
+	switch m.Type {
+	case "image":
+		return m.Image
+	case "audio":
+		return m.Audio
+	case "video":
+		return m.Video
+	case "document":
+		return m.Document
+	case "sticker":
+		return m.Sticker
+	}
+	return nil
+}
+
+// SentAt returns when the customer sent the message, from its Unix timestamp. A missing
+// timestamp, or one after now, gives now, so a message can never extend the service window
+// beyond the time it was received.
+func (m *WhatsAppMessage) SentAt(now time.Time) time.Time {
+	seconds, err := strconv.ParseInt(m.Timestamp, 10, 64)
+	if err != nil || seconds <= 0 {
+		return now
+	}
+	if sent := time.Unix(seconds, 0); sent.Before(now) {
+		return sent
+	}
+	return now
+}
+
+// WhatsAppMedia represents the media object of an inbound WhatsApp message
+type WhatsAppMedia struct {
+	ID       string `json:"id"`
+	MimeType string `json:"mime_type"`
+	SHA256   string `json:"sha256"`
+	Caption  string `json:"caption,omitempty"`
+	Filename string `json:"filename,omitempty"`
+}
+
+// WhatsAppStatus represents a delivery status callback for an outbound WhatsApp message
+type WhatsAppStatus struct {
+	ID          string `json:"id"` // Meta message ID of the outbound message
+	Status      string `json:"status"`
+	Timestamp   string `json:"timestamp"`
+	RecipientID string `json:"recipient_id"`
+	Errors      []struct {
+		Code    int    `json:"code"`
+		Title   string `json:"title"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// WebhookSource identifies where an inbound webhook came from
+type WebhookSource string
+
+const (
+	WebhookSourceWhatsApp  WebhookSource = "whatsapp"
+	WebhookSourceInstagram WebhookSource = "instagram"
+	WebhookSourceN8N       WebhookSource = "n8n" // WhatsApp payloads forwarded by n8n
+)
+
+// WebhookEventStatus represents the processing state of an inbox event
+type WebhookEventStatus string
+
+const (
+	WebhookEventPending    WebhookEventStatus = "pending"
+	WebhookEventProcessing WebhookEventStatus = "processing"
+	WebhookEventProcessed  WebhookEventStatus = "processed"
+	WebhookEventDead       WebhookEventStatus = "dead"     // Gave up after max attempts
+	WebhookEventRejected   WebhookEventStatus = "rejected" // Failed verification, never processed
+)
+
+// SignatureStatus records the outcome of webhook signature verification
+type SignatureStatus string
+
+const (
+	SignatureValid   SignatureStatus = "valid"
+	SignatureInvalid SignatureStatus = "invalid"
+	SignatureSkipped SignatureStatus = "skipped" // No secret configured for the source
+)
+
+// WebhookEvent represents a stored inbound webhook
+type WebhookEvent struct {
+	ID            string             `json:"id"`
+	Source        WebhookSource      `json:"source"`
+	Payload       string             `json:"payload,omitempty"` // Raw body as received
+	Headers       map[string]string  `json:"headers,omitempty"`
+	Signature     SignatureStatus    `json:"signature_status"`
+	SignatureHash string             `json:"-"` // Accepted internal HMAC signature, unique to reject replays
+	Status        WebhookEventStatus `json:"status"`
+	Attempts      int                `json:"attempts"`
+	NextAttemptAt time.Time          `json:"next_attempt_at"`
+	LastError     string             `json:"last_error,omitempty"`
+	ProcessedAt   *time.Time         `json:"processed_at,omitempty"`
+	CreatedAt     time.Time          `json:"created_at"`
+	UpdatedAt     time.Time          `json:"updated_at"`
+}
+
+// WebhookEventFilter narrows a webhook event listing
+type WebhookEventFilter struct {
+	Source WebhookSource
+	Status WebhookEventStatus
+	Since  *time.Time
+	Until  *time.Time
+	Limit  int
+	Offset int
+}
+
+// Role represents a dashboard user's role
+type Role string
+
+const (
+	RoleAgent      Role = "agent"
+	RoleSupervisor Role = "supervisor"
+	RoleAdmin      Role = "admin"
+)
+
+// Permission represents an action gated by role
+type Permission string
+
+const (
+	PermViewAllConversations Permission = "conversations:view_all"
+	PermAssignConversations  Permission = "conversations:assign"
+	PermViewReports          Permission = "reports:view"
+	PermManageUsers          Permission = "users:manage"
+	PermManageChannels       Permission = "channels:manage"
+	PermManageTemplates      Permission = "templates:manage"
+	PermManageBroadcasts     Permission = "broadcasts:manage"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleAgent: {},
+	RoleSupervisor: {
+		PermViewAllConversations, PermAssignConversations, PermViewReports,
+	},
+	RoleAdmin: {
+		PermViewAllConversations, PermAssignConversations, PermViewReports,
+		PermManageUsers, PermManageChannels, PermManageTemplates, PermManageBroadcasts,
+	},
+}
+
+// Valid reports whether r is a known role
+func (r Role) Valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// Can reports whether the role grants a permission
+func (r Role) Can(p Permission) bool {
+	for _, granted := range rolePermissions[r] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Team groups agents that share conversations
+type Team struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Availability is whether an agent can receive routed conversations
+type Availability string
+
+const (
+	AvailabilityOnline  Availability = "online"
+	AvailabilityAway    Availability = "away"
+	AvailabilityOffline Availability = "offline"
+)
+
+// Valid reports whether a is a known availability
+func (a Availability) Valid() bool {
+	return a == AvailabilityOnline || a == AvailabilityAway || a == AvailabilityOffline
+}
+
+// User represents a dashboard user account
+type User struct {
+	ID            string       `json:"id"`
+	Email         string       `json:"email"`
+	Name          string       `json:"name"`
+	Role          Role         `json:"role"`
+	TeamID        string       `json:"team_id,omitempty"`
+	PasswordHash  string       `json:"-"`
+	IsActive      bool         `json:"is_active"`
+	Availability  Availability `json:"availability"`
+	MaxConcurrent int          `json:"max_concurrent"` // 0 uses the routing default
+	Skills        []string     `json:"skills"`
+	LastLoginAt   *time.Time   `json:"last_login_at,omitempty"`
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+}
+
+// LoginRequest represents a login attempt
+type LoginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// RefreshRequest carries a refresh token for refresh and logout
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// AuthTokens is returned on login and refresh
+type AuthTokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"` // Access token lifetime in seconds
+	User         *User  `json:"user"`
+}
+
+// CreateUserRequest represents an admin creating a user
+type CreateUserRequest struct {
+	Email    string `json:"email"`
+	Name     string `json:"name"`
+	Password string `json:"password"`
+	Role     Role   `json:"role"`
+	TeamID   string `json:"team_id"`
+}
+
+// UpdateUserRequest represents an admin updating a user; nil fields are left unchanged
+type UpdateUserRequest struct {
+	Name     *string `json:"name"`
+	Password *string `json:"password"`
+	Role     *Role   `json:"role"`
+	TeamID   *string `json:"team_id"` // Empty string removes the user from their team
+	IsActive *bool   `json:"is_active"`
+
+	MaxConcurrent *int      `json:"max_concurrent"`
+	Skills        *[]string `json:"skills"`
+}
+
+// UpdateAvailabilityRequest sets the current user's availability for routing
+type UpdateAvailabilityRequest struct {
+	Availability Availability `json:"availability"`
+}
+
+// UpdateTagsRequest replaces a conversation's tags
+type UpdateTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+// EventType identifies an outbound integration event
+type EventType string
+
+const (
+	EventMessageReceived      EventType = "message.received"
+	EventMessageSent          EventType = "message.sent"
+	EventMessageStatusChanged EventType = "message.status_changed"
+	EventConversationCreated  EventType = "conversation.created"
+	EventContactCreated       EventType = "contact.created"
+
+	EventConversationStatusChanged EventType = "conversation.status_changed"
+)
+
+var eventTypes = map[EventType]bool{
+	EventMessageReceived:      true,
+	EventMessageSent:          true,
+	EventMessageStatusChanged: true,
+	EventConversationCreated:  true,
+	EventContactCreated:       true,
+
+	EventConversationStatusChanged: true,
+}
+
+// Valid reports whether t is a known event type
+func (t EventType) Valid() bool {
+	return eventTypes[t]
+}
+
+// Event is the normalized envelope POSTed to integrations
+type Event struct {
+	ID         string      `json:"id"`
+	Type       EventType   `json:"type"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Data       interface{} `json:"data"`
+}
+
+// MessageStatusChange is the data of a message.status_changed event
+type MessageStatusChange struct {
+	MessageID      string        `json:"message_id"`
+	ExternalID     string        `json:"external_id"`
+	ConversationID string        `json:"conversation_id"`
+	Platform       Platform      `json:"platform"`
+	PreviousStatus MessageStatus `json:"previous_status"`
+	Status         MessageStatus `json:"status"`
+	ErrorCode      int           `json:"error_code,omitempty"`
+	ErrorTitle     string        `json:"error_title,omitempty"`
+}
+
+// DeliveryStatus represents the state of an outbound event delivery
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliverySending   DeliveryStatus = "sending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	DeliveryFailed    DeliveryStatus = "failed" // Gave up after max attempts
+)
+
+// EventDelivery is one attempt series to deliver an event to a target
+type EventDelivery struct {
+	ID             string         `json:"id"`
+	EventID        string         `json:"event_id"`
+	EventType      EventType      `json:"event_type"`
+	Target         string         `json:"target"`
+	SubscriptionID string         `json:"subscription_id,omitempty"`
+	URL            string         `json:"url"`
+	Payload        string         `json:"payload,omitempty"`
+	Status         DeliveryStatus `json:"status"`
+	Attempts       int            `json:"attempts"`
+	NextAttemptAt  time.Time      `json:"next_attempt_at"`
+	ResponseStatus int            `json:"response_status,omitempty"`
+	LastError      string         `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time     `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+// EventDeliveryFilter narrows a delivery listing
+type EventDeliveryFilter struct {
+	Target         string
+	SubscriptionID string
+	Status         DeliveryStatus
+	EventType      EventType
+	Limit          int
+	Offset         int
+}
+
+// WebhookSubscription is an integration endpoint receiving a subset of events
+type WebhookSubscription struct {
+	ID                  string      `json:"id"`
+	Name                string      `json:"name"`
+	URL                 string      `json:"url"`
+	Secret              string      `json:"secret,omitempty"` // Only returned when created or rotated
+	EventTypes          []EventType `json:"event_types"`      // Empty means every event type
+	IsActive            bool        `json:"is_active"`
+	ConsecutiveFailures int         `json:"consecutive_failures"`
+	DisabledAt          *time.Time  `json:"disabled_at,omitempty"`
+	DisabledReason      string      `json:"disabled_reason,omitempty"`
+	CreatedAt           time.Time   `json:"created_at"`
+	UpdatedAt           time.Time   `json:"updated_at"`
+}
+
+// Wants reports whether the subscription selects an event type
+func (s *WebhookSubscription) Wants(eventType EventType) bool {
+	if len(s.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range s.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateWebhookSubscriptionRequest represents a new subscription; a secret is generated if omitted
+type CreateWebhookSubscriptionRequest struct {
+	Name       string      `json:"name"`
+	URL        string      `json:"url"`
+	Secret     string      `json:"secret"`
+	EventTypes []EventType `json:"event_types"`
+}
+
+// UpdateWebhookSubscriptionRequest changes a subscription; nil fields are left unchanged.
+// Re-activating a subscription resets its failure count.
+type UpdateWebhookSubscriptionRequest struct {
+	Name         *string      `json:"name"`
+	URL          *string      `json:"url"`
+	EventTypes   *[]EventType `json:"event_types"`
+	IsActive     *bool        `json:"is_active"`
+	RotateSecret bool         `json:"rotate_secret"`
+}
+
+// RealtimeEventType identifies an event pushed to connected dashboard clients
+type RealtimeEventType string
+
+const (
+	RealtimeMessageCreated      RealtimeEventType = "message.created"
+	RealtimeMessageStatus       RealtimeEventType = "message.status"
+	RealtimeConversationUpdated RealtimeEventType = "conversation.updated"
+	RealtimeTyping              RealtimeEventType = "typing"
+)
+
+// RealtimeEvent is pushed to dashboard clients over the event stream.
+// AssigneeID and TeamID carry the conversation's visibility so each replica can filter per user.
+type RealtimeEvent struct {
+	Type           RealtimeEventType `json:"type"`
+	ConversationID string            `json:"conversation_id"`
+	AssigneeID     string            `json:"assignee_id,omitempty"`
+	TeamID         string            `json:"team_id,omitempty"`
+	Data           json.RawMessage   `json:"data,omitempty"`
+	Truncated      bool              `json:"truncated,omitempty"` // Data was too large to broadcast; refetch it
+}
+
+// TypingIndicator is the data of a typing event
+type TypingIndicator struct {
+	UserID   string `json:"user_id"`
+	UserName string `json:"user_name"`
+}
+
+// RoutingStrategy picks which available agent receives a conversation
+type RoutingStrategy string
+
+const (
+	RoutingRoundRobin RoutingStrategy = "round_robin" // Agent routed to least recently
+	RoutingLeastOpen  RoutingStrategy = "least_open"  // Agent with the fewest assigned conversations
+)
+
+// Valid reports whether s is a known strategy
+func (s RoutingStrategy) Valid() bool {
+	return s == RoutingRoundRobin || s == RoutingLeastOpen
+}
+
+// RoutingRule routes matching conversations to agents of a team or with a skill
+type RoutingRule struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Priority  int             `json:"priority"` // Lower runs first
+	Platform  Platform        `json:"platform,omitempty"`
+	Tag       string          `json:"tag,omitempty"`
+	TeamID    string          `json:"team_id,omitempty"`
+	Skill     string          `json:"skill,omitempty"`
+	Strategy  RoutingStrategy `json:"strategy"`
+	IsActive  bool            `json:"is_active"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// Matches reports whether the rule applies to a conversation
+func (r *RoutingRule) Matches(conv *Conversation) bool {
+	if r.Platform != "" && r.Platform != conv.Platform {
+		return false
+	}
+	if r.Tag == "" {
+		return true
+	}
+	for _, tag := range conv.Tags {
+		if tag == r.Tag {
+			return true
+		}
+	}
+	return false
+}
+
+// RoutingRuleRequest creates or replaces a routing rule
+type RoutingRuleRequest struct {
+	Name     string          `json:"name"`
+	Priority int             `json:"priority"`
+	Platform Platform        `json:"platform"`
+	Tag      string          `json:"tag"`
+	TeamID   string          `json:"team_id"`
+	Skill    string          `json:"skill"`
+	Strategy RoutingStrategy `json:"strategy"`
+	IsActive *bool           `json:"is_active"` // Defaults to true
+}
+
+// RoutingCandidate is an available agent with their current load
+type RoutingCandidate struct {
+	UserID    string
+	OpenCount int
+	Capacity  int // Concurrent conversation cap
+}
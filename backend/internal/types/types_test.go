@@ -0,0 +1,140 @@
+package types
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMessageStatusCanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from, to MessageStatus
+		want     bool
+	}{
+		{StatusPending, StatusSent, true},
+		{StatusPending, StatusDelivered, true},
+		{StatusSent, StatusDelivered, true},
+		{StatusSent, StatusRead, true},
+		{StatusDelivered, StatusRead, true},
+		{StatusRead, StatusDelivered, false},
+		{StatusDelivered, StatusSent, false},
+		{StatusSent, StatusSent, false},
+		{StatusPending, StatusFailed, true},
+		{StatusSent, StatusFailed, true},
+		{StatusDelivered, StatusFailed, false},
+		{StatusRead, StatusFailed, false},
+		{StatusFailed, StatusSent, false},
+		{StatusFailed, StatusRead, false},
+		{StatusSent, MessageStatus("deleted"), false},
+	}
+	for _, tt := range tests {
+		if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+			t.Errorf("%s -> %s = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestTransitionSources(t *testing.T) {
+	tests := []struct {
+		next MessageStatus
+		want []MessageStatus
+	}{
+		{StatusSent, []MessageStatus{StatusPending}},
+		{StatusDelivered, []MessageStatus{StatusPending, StatusSent}},
+		{StatusRead, []MessageStatus{StatusPending, StatusSent, StatusDelivered}},
+		{StatusFailed, []MessageStatus{StatusPending, StatusSent}},
+		{StatusPending, nil},
+	}
+	for _, tt := range tests {
+		if got := TransitionSources(tt.next); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("TransitionSources(%s) = %v, want %v", tt.next, got, tt.want)
+		}
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursor := &Cursor{
+		At: time.Date(2026, 3, 1, 12, 30, 0, 123000000, time.UTC),
+		ID: "7f0c3a2e-5d1b-4c8e-9a6f-2b4d6e8f0a1c",
+	}
+	decoded, err := DecodeCursor(cursor.Encode())
+	if err != nil {
+		t.Fatalf("DecodeCursor: %v", err)
+	}
+	if !decoded.At.Equal(cursor.At) || decoded.ID != cursor.ID {
+		t.Errorf("DecodeCursor = %+v, want %+v", decoded, cursor)
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	tests := map[string]string{
+		"not base64":  "%%%",
+		"not json":    "bm90IGpzb24",
+		"bad id":      (&Cursor{At: time.Now(), ID: "42"}).Encode(),
+		"zero time":   (&Cursor{ID: "7f0c3a2e-5d1b-4c8e-9a6f-2b4d6e8f0a1c"}).Encode(),
+		"empty token": "",
+	}
+	for name, token := range tests {
+		if _, err := DecodeCursor(token); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: err = %v, want ErrInvalidCursor", name, err)
+		}
+	}
+}
+
+func TestConversationSetServiceWindow(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		ts := now.Add(d)
+		return &ts
+	}
+
+	tests := []struct {
+		name        string
+		platform    Platform
+		lastInbound *time.Time
+		wantOpen    bool
+		wantExpires *time.Time
+	}{
+		{"instagram is always open", PlatformInstagram, nil, true, nil},
+		{"never messaged", PlatformWhatsApp, nil, false, nil},
+		{"recent message", PlatformWhatsApp, at(-time.Hour), true, at(23 * time.Hour)},
+		{"just before expiry", PlatformWhatsApp, at(-WhatsAppServiceWindow + time.Second), true, at(time.Second)},
+		{"at expiry", PlatformWhatsApp, at(-WhatsAppServiceWindow), false, at(0)},
+		{"long ago", PlatformWhatsApp, at(-48 * time.Hour), false, at(-24 * time.Hour)},
+	}
+	for _, tt := range tests {
+		conv := &Conversation{Platform: tt.platform, LastInboundAt: tt.lastInbound}
+		conv.SetServiceWindow(now)
+		if conv.WindowOpen != tt.wantOpen {
+			t.Errorf("%s: WindowOpen = %v, want %v", tt.name, conv.WindowOpen, tt.wantOpen)
+		}
+		switch {
+		case tt.wantExpires == nil && conv.WindowExpiresAt != nil:
+			t.Errorf("%s: WindowExpiresAt = %v, want nil", tt.name, conv.WindowExpiresAt)
+		case tt.wantExpires != nil && (conv.WindowExpiresAt == nil || !conv.WindowExpiresAt.Equal(*tt.wantExpires)):
+			t.Errorf("%s: WindowExpiresAt = %v, want %v", tt.name, conv.WindowExpiresAt, tt.wantExpires)
+		}
+	}
+}
+
+func TestWhatsAppMessageSentAt(t *testing.T) {
+	now := time.Unix(1772366400, 0)
+	tests := []struct {
+		timestamp string
+		want      time.Time
+	}{
+		{"1772366000", time.Unix(1772366000, 0)},
+		{"1772366400", now},
+		{"1772369999", now}, // Ahead of our clock
+		{"", now},
+		{"yesterday", now},
+		{"0", now},
+	}
+	for _, tt := range tests {
+		msg := &WhatsAppMessage{Timestamp: tt.timestamp}
+		if got := msg.SentAt(now); !got.Equal(tt.want) {
+			t.Errorf("SentAt(%q) = %v, want %v", tt.timestamp, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,5 @@
+-- Delivery status tracking for outbound messages
+-- Stores the Meta error reported with a 'failed' status callback
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS error_code INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS error_title TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,9 @@
+-- Media attachments on messages
+-- One attachment per message, matching how WhatsApp and Instagram deliver media
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS media_id VARCHAR(255) NOT NULL DEFAULT ''; -- Meta media ID
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS media_url TEXT NOT NULL DEFAULT ''; -- Served or external URL
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS media_mime_type VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS media_filename VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS media_sha256 VARCHAR(128) NOT NULL DEFAULT '';
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS media_size BIGINT NOT NULL DEFAULT 0;
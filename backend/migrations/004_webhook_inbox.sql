@@ -0,0 +1,25 @@
+-- Durable inbox for inbound webhooks
+-- Raw bodies are stored before Meta is acknowledged and processed by a worker pool
+
+CREATE TABLE IF NOT EXISTS webhook_inbox (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    source VARCHAR(20) NOT NULL, -- 'whatsapp', 'instagram', 'n8n'
+    payload TEXT NOT NULL, -- Raw request body, kept byte-for-byte
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- 'pending', 'processing', 'processed', 'dead'
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    locked_at TIMESTAMP WITH TIME ZONE,
+    last_error TEXT NOT NULL DEFAULT '',
+    processed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Workers poll for due pending events
+CREATE INDEX IF NOT EXISTS idx_webhook_inbox_due ON webhook_inbox(next_attempt_at) WHERE status IN ('pending', 'processing');
+CREATE INDEX IF NOT EXISTS idx_webhook_inbox_status ON webhook_inbox(status, created_at DESC);
+
+DROP TRIGGER IF EXISTS update_webhook_inbox_updated_at ON webhook_inbox;
+CREATE TRIGGER update_webhook_inbox_updated_at
+    BEFORE UPDATE ON webhook_inbox
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,14 @@
+-- Idempotent inbound ingestion
+-- Meta retries webhooks and n8n may forward the same payload, so a Meta message ID
+-- may only be stored once per platform
+
+-- Remove duplicates left behind before this constraint existed, keeping the earliest copy
+DELETE FROM messages a
+USING messages b
+WHERE a.platform = b.platform
+  AND a.external_id = b.external_id
+  AND a.external_id <> ''
+  AND (a.created_at, a.id) > (b.created_at, b.id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_messages_platform_external_id
+    ON messages(platform, external_id) WHERE external_id <> '';
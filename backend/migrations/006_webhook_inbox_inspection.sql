@@ -0,0 +1,8 @@
+-- Webhook inspection and replay
+-- Every received webhook is recorded, including ones rejected before processing
+
+ALTER TABLE webhook_inbox ADD COLUMN IF NOT EXISTS headers JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE webhook_inbox ADD COLUMN IF NOT EXISTS signature_status VARCHAR(20) NOT NULL DEFAULT 'skipped'; -- 'valid', 'invalid', 'skipped'
+-- status may now also be 'rejected' (failed verification or unparseable body, never processed)
+
+CREATE INDEX IF NOT EXISTS idx_webhook_inbox_source ON webhook_inbox(source, created_at DESC);
@@ -0,0 +1,28 @@
+-- Outbound event deliveries (n8n and other integrations)
+-- Each row is one event to one target, retried with backoff until delivered or failed
+
+CREATE TABLE IF NOT EXISTS event_deliveries (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    event_id UUID NOT NULL,
+    event_type VARCHAR(50) NOT NULL, -- 'message.received', 'message.sent', ...
+    target VARCHAR(50) NOT NULL, -- 'n8n'
+    url TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- 'pending', 'sending', 'delivered', 'failed'
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    locked_at TIMESTAMP WITH TIME ZONE,
+    response_status INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    delivered_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_deliveries_due ON event_deliveries(next_attempt_at) WHERE status IN ('pending', 'sending');
+CREATE INDEX IF NOT EXISTS idx_event_deliveries_target ON event_deliveries(target, created_at DESC);
+
+DROP TRIGGER IF EXISTS update_event_deliveries_updated_at ON event_deliveries;
+CREATE TRIGGER update_event_deliveries_updated_at
+    BEFORE UPDATE ON event_deliveries
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
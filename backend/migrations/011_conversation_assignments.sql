@@ -0,0 +1,15 @@
+-- Conversation assignment history
+-- One row per assign/unassign/claim so supervisors can see who handled what
+
+CREATE TABLE IF NOT EXISTS conversation_assignments (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    action VARCHAR(20) NOT NULL, -- 'assign', 'unassign', 'claim'
+    assignee_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    team_id UUID REFERENCES teams(id) ON DELETE SET NULL,
+    actor_id UUID REFERENCES users(id) ON DELETE SET NULL, -- NULL for system actions
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_conversation_assignments_conversation ON conversation_assignments(conversation_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_conversation_assignments_assignee ON conversation_assignments(assignee_id, created_at DESC) WHERE assignee_id IS NOT NULL;
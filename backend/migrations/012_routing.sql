@@ -0,0 +1,33 @@
+-- Automatic conversation routing
+-- Agents report availability and have a concurrent conversation cap and skills;
+-- rules match new conversations by platform or tag and pick a strategy and team
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS availability VARCHAR(20) NOT NULL DEFAULT 'offline'; -- 'online', 'away', 'offline'
+ALTER TABLE users ADD COLUMN IF NOT EXISTS max_concurrent INTEGER NOT NULL DEFAULT 0; -- 0 uses ROUTING_MAX_CONCURRENT
+ALTER TABLE users ADD COLUMN IF NOT EXISTS skills TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS last_routed_at TIMESTAMP WITH TIME ZONE; -- Round-robin position
+
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS idx_conversations_tags ON conversations USING GIN(tags);
+
+CREATE TABLE IF NOT EXISTS routing_rules (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(255) NOT NULL,
+    priority INTEGER NOT NULL DEFAULT 0, -- Lower runs first
+    platform VARCHAR(20) NOT NULL DEFAULT '', -- Empty matches any platform
+    tag VARCHAR(100) NOT NULL DEFAULT '', -- Empty matches any tag
+    team_id UUID REFERENCES teams(id) ON DELETE SET NULL, -- Route to members of this team
+    skill VARCHAR(100) NOT NULL DEFAULT '', -- Agents must have this skill
+    strategy VARCHAR(20) NOT NULL DEFAULT 'round_robin', -- 'round_robin', 'least_open'
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_routing_rules_priority ON routing_rules(priority) WHERE is_active;
+
+DROP TRIGGER IF EXISTS update_routing_rules_updated_at ON routing_rules;
+CREATE TRIGGER update_routing_rules_updated_at
+    BEFORE UPDATE ON routing_rules
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
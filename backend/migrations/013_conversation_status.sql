@@ -0,0 +1,9 @@
+-- Conversation lifecycle
+-- Conversations are open, pending (waiting on the customer), snoozed until a time, or resolved
+
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'open'; -- 'open', 'pending', 'snoozed', 'resolved'
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS snoozed_until TIMESTAMP WITH TIME ZONE;
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS resolved_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_conversations_status ON conversations(status, last_message_at DESC);
+CREATE INDEX IF NOT EXISTS idx_conversations_snoozed_until ON conversations(snoozed_until) WHERE status = 'snoozed';
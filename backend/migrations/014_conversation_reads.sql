@@ -0,0 +1,12 @@
+-- Per-agent read positions
+-- Records the last message each dashboard user acknowledged in a conversation
+
+CREATE TABLE IF NOT EXISTS conversation_reads (
+    conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    last_read_message_id UUID REFERENCES messages(id) ON DELETE SET NULL,
+    last_read_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (conversation_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_messages_unread_inbound ON messages(conversation_id, created_at) WHERE direction = 'inbound' AND status <> 'read';
@@ -0,0 +1,24 @@
+-- Direction-aware conversation summary
+-- Records who sent a message and keeps inbound and outbound activity apart
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS sender_id UUID REFERENCES users(id) ON DELETE SET NULL;
+
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS last_message_direction VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS last_message_sender_id UUID REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS last_inbound_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS last_outbound_at TIMESTAMP WITH TIME ZONE;
+
+-- Rebuild existing summaries from the messages table; outbound replies used to count as unread
+UPDATE conversations c SET
+    last_message_direction = COALESCE((
+        SELECT m.direction FROM messages m
+        WHERE m.conversation_id = c.id
+        ORDER BY m.created_at DESC
+        LIMIT 1
+    ), ''),
+    last_inbound_at = (SELECT MAX(m.created_at) FROM messages m WHERE m.conversation_id = c.id AND m.direction = 'inbound'),
+    last_outbound_at = (SELECT MAX(m.created_at) FROM messages m WHERE m.conversation_id = c.id AND m.direction = 'outbound'),
+    unread_count = (
+        SELECT COUNT(*) FROM messages m
+        WHERE m.conversation_id = c.id AND m.direction = 'inbound' AND m.status <> 'read'
+    );
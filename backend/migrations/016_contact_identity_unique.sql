@@ -0,0 +1,77 @@
+-- Race-free contact ingestion
+-- A WhatsApp or Instagram ID identifies exactly one contact, so concurrent webhooks for a
+-- new customer can upsert instead of both inserting
+
+-- Merge duplicates left behind before this constraint existed into the earliest contact.
+-- The keeper and its duplicates keep one conversation per platform, the keeper's own when it
+-- has one and otherwise the earliest; the others' messages are folded into it.
+CREATE TEMP TABLE contact_merges (duplicate_id UUID PRIMARY KEY, keeper_id UUID NOT NULL);
+
+INSERT INTO contact_merges
+SELECT id, keeper_id FROM (
+    SELECT id, FIRST_VALUE(id) OVER (PARTITION BY whatsapp_id ORDER BY created_at, id) AS keeper_id
+    FROM contacts WHERE whatsapp_id <> ''
+) ranked
+WHERE id <> keeper_id;
+
+INSERT INTO contact_merges
+SELECT id, keeper_id FROM (
+    SELECT id, FIRST_VALUE(id) OVER (PARTITION BY instagram_id ORDER BY created_at, id) AS keeper_id
+    FROM contacts WHERE instagram_id <> ''
+) ranked
+WHERE id <> keeper_id
+ON CONFLICT (duplicate_id) DO NOTHING;
+
+-- A keeper that is itself a duplicate resolves to its own keeper
+UPDATE contact_merges m SET keeper_id = k.keeper_id
+FROM contact_merges k
+WHERE m.keeper_id = k.duplicate_id;
+
+CREATE TEMP TABLE conversation_merges (duplicate_id UUID PRIMARY KEY, survivor_id UUID NOT NULL);
+
+INSERT INTO conversation_merges
+SELECT id, survivor_id FROM (
+    SELECT c.id, FIRST_VALUE(c.id) OVER (
+        PARTITION BY owners.keeper_id, c.platform
+        ORDER BY c.contact_id = owners.keeper_id DESC, c.created_at, c.id
+    ) AS survivor_id
+    FROM conversations c
+    JOIN (
+        SELECT duplicate_id AS contact_id, keeper_id FROM contact_merges
+        UNION
+        SELECT keeper_id, keeper_id FROM contact_merges
+    ) owners ON owners.contact_id = c.contact_id
+) ranked
+WHERE id <> survivor_id;
+
+UPDATE messages m SET conversation_id = cm.survivor_id
+FROM conversation_merges cm
+WHERE m.conversation_id = cm.duplicate_id;
+
+DELETE FROM conversations c USING conversation_merges cm WHERE c.id = cm.duplicate_id;
+
+-- Every remaining conversation of a duplicate is the only one on its platform
+UPDATE conversations c SET contact_id = cm.keeper_id
+FROM contact_merges cm
+WHERE c.contact_id = cm.duplicate_id;
+
+DELETE FROM contacts c USING contact_merges cm WHERE c.id = cm.duplicate_id;
+
+-- Rebuild the summaries of conversations that received merged messages
+UPDATE conversations c SET
+    last_message_at = COALESCE((SELECT MAX(m.created_at) FROM messages m WHERE m.conversation_id = c.id), c.last_message_at),
+    last_inbound_at = (SELECT MAX(m.created_at) FROM messages m WHERE m.conversation_id = c.id AND m.direction = 'inbound'),
+    last_outbound_at = (SELECT MAX(m.created_at) FROM messages m WHERE m.conversation_id = c.id AND m.direction = 'outbound'),
+    unread_count = (
+        SELECT COUNT(*) FROM messages m
+        WHERE m.conversation_id = c.id AND m.direction = 'inbound' AND m.status <> 'read'
+    )
+WHERE c.contact_id IN (SELECT DISTINCT keeper_id FROM contact_merges);
+
+DROP TABLE conversation_merges;
+DROP TABLE contact_merges;
+
+DROP INDEX IF EXISTS idx_contacts_whatsapp_id;
+DROP INDEX IF EXISTS idx_contacts_instagram_id;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_contacts_whatsapp_id ON contacts(whatsapp_id) WHERE whatsapp_id <> '';
+CREATE UNIQUE INDEX IF NOT EXISTS idx_contacts_instagram_id ON contacts(instagram_id) WHERE instagram_id <> '';
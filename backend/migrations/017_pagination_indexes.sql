@@ -0,0 +1,7 @@
+-- Keyset pagination
+-- Lists page by (timestamp, id) so every row stays reachable without OFFSET scans
+
+CREATE INDEX IF NOT EXISTS idx_conversations_last_message_id ON conversations(last_message_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_messages_conversation_created_id ON messages(conversation_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_contacts_created_id ON contacts(created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_conversations_tags ON conversations USING GIN (tags);
@@ -0,0 +1,8 @@
+-- WhatsApp template management
+-- Templates mirror the WhatsApp Business Account, including their full component definitions
+
+ALTER TABLE templates ADD COLUMN IF NOT EXISTS components JSONB NOT NULL DEFAULT '[]';
+ALTER TABLE templates ADD COLUMN IF NOT EXISTS rejected_reason TEXT NOT NULL DEFAULT '';
+ALTER TABLE templates ADD COLUMN IF NOT EXISTS synced_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_templates_status ON templates(status);
@@ -0,0 +1,39 @@
+-- Broadcast campaigns
+-- A broadcast sends one approved WhatsApp template to an audience of contacts. Each
+-- contact gets a recipient row that follows its message through sent, delivered and read.
+
+ALTER TABLE broadcasts ADD COLUMN IF NOT EXISTS template_params JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE broadcasts ADD COLUMN IF NOT EXISTS created_by UUID REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE broadcasts ADD COLUMN IF NOT EXISTS last_error TEXT NOT NULL DEFAULT '';
+-- status gains 'paused' and 'cancelled'
+
+CREATE INDEX IF NOT EXISTS idx_broadcasts_active ON broadcasts(scheduled_at) WHERE status IN ('scheduled', 'sending');
+
+CREATE TABLE IF NOT EXISTS broadcast_recipients (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    broadcast_id UUID NOT NULL REFERENCES broadcasts(id) ON DELETE CASCADE,
+    contact_id UUID NOT NULL REFERENCES contacts(id) ON DELETE CASCADE,
+    whatsapp_id VARCHAR(100) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- 'pending', 'sending', 'sent', 'delivered', 'read', 'failed', 'cancelled'
+    message_id UUID REFERENCES messages(id) ON DELETE SET NULL,
+    external_id VARCHAR(255) NOT NULL DEFAULT '', -- WhatsApp message ID, matched by status webhooks
+    error_code INTEGER NOT NULL DEFAULT 0,
+    error_title TEXT NOT NULL DEFAULT '',
+    sent_at TIMESTAMP WITH TIME ZONE,
+    delivered_at TIMESTAMP WITH TIME ZONE,
+    read_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE (broadcast_id, contact_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_broadcast_recipients_list ON broadcast_recipients(broadcast_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_broadcast_recipients_pending ON broadcast_recipients(broadcast_id, created_at, id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_broadcast_recipients_external_id ON broadcast_recipients(external_id) WHERE external_id <> '';
+-- Rolling 24-hour count of contacts messaged, checked against the WhatsApp tier limit
+CREATE INDEX IF NOT EXISTS idx_broadcast_recipients_sent_at ON broadcast_recipients(sent_at) WHERE sent_at IS NOT NULL;
+
+DROP TRIGGER IF EXISTS update_broadcast_recipients_updated_at ON broadcast_recipients;
+CREATE TRIGGER update_broadcast_recipients_updated_at
+    BEFORE UPDATE ON broadcast_recipients
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,6 @@
+-- Per-recipient broadcast personalization
+-- Template parameters may be filled from each recipient's contact; recipients missing a
+-- required value are skipped with the reason in error_title
+
+ALTER TABLE broadcasts ADD COLUMN IF NOT EXISTS skipped_count INTEGER NOT NULL DEFAULT 0;
+-- broadcast_recipients.status gains 'skipped'
@@ -0,0 +1,7 @@
+-- Durable replay protection for signed internal webhooks
+-- Each stored event keeps the HMAC signature it was accepted with; a request whose signature
+-- is already stored is a replay, on every replica and across restarts
+
+ALTER TABLE webhook_inbox ADD COLUMN IF NOT EXISTS signature VARCHAR(64);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_webhook_inbox_signature ON webhook_inbox(signature) WHERE signature IS NOT NULL;
@@ -93,6 +93,96 @@ func (c *InstagramClient) SendText(recipientID, message string) (*IGMessageRespo
 	return &result, nil
 }
 
+// SendAttachment sends an image, audio, video or file attachment by public URL
+func (c *InstagramClient) SendAttachment(recipientID, attachmentType, attachmentURL string) (*IGMessageResponse, error) {
+	payload := map[string]interface{}{
+		"recipient": map[string]string{
+			"id": recipientID,
+		},
+		"message": map[string]interface{}{
+			"attachment": map[string]interface{}{
+				"type": attachmentType,
+				"payload": map[string]string{
+					"url": attachmentURL,
+				},
+			},
+		},
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/%s/messages", instagramAPIURL, c.accountID)
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.accessToken)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API error: %s (status: %d)", string(body), resp.StatusCode)
+	}
+
+	var result IGMessageResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &result, nil
+}
+
+// MarkSeen sends the mark_seen sender action so the user sees their messages as read
+func (c *InstagramClient) MarkSeen(recipientID string) error {
+	payload := map[string]interface{}{
+		"recipient": map[string]string{
+			"id": recipientID,
+		},
+		"sender_action": "mark_seen",
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/%s/messages", instagramAPIURL, c.accountID)
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.accessToken)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("API error: %s (status: %d)", string(body), resp.StatusCode)
+	}
+
+	return nil
+}
+
 // GetConversations retrieves Instagram DM conversations
 func (c *InstagramClient) GetConversations(limit int) ([]map[string]interface{}, error) {
 	url := fmt.Sprintf("%s/%s/conversations?fields=participants,messages{message,from,created_time}&limit=%d",
@@ -5,7 +5,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
+	"net/url"
 	"time"
 )
 
@@ -113,26 +116,49 @@ type TemplateMessage struct {
 	} `json:"template"`
 }
 
+// TemplateComponent fills the variables of one template component
 type TemplateComponent struct {
-	Type       string              `json:"type"`
+	Type       string              `json:"type"`               // header, body or button
+	SubType    string              `json:"sub_type,omitempty"` // quick_reply or url, for buttons
+	Index      string              `json:"index,omitempty"`    // Position of the button, from "0"
 	Parameters []TemplateParameter `json:"parameters,omitempty"`
 }
 
+// TemplateParameter is the value of one template variable
 type TemplateParameter struct {
-	Type string `json:"type"`
-	Text string `json:"text,omitempty"`
+	Type          string            `json:"type"` // text, currency, date_time, image, document, video or payload
+	ParameterName string            `json:"parameter_name,omitempty"`
+	Text          string            `json:"text,omitempty"`
+	Payload       string            `json:"payload,omitempty"`
+	Currency      *TemplateCurrency `json:"currency,omitempty"`
+	DateTime      *TemplateDateTime `json:"date_time,omitempty"`
+	Image         *TemplateMedia    `json:"image,omitempty"`
+	Document      *TemplateMedia    `json:"document,omitempty"`
+	Video         *TemplateMedia    `json:"video,omitempty"`
 }
 
-// SendTemplate sends a template message
-func (c *WhatsAppClient) SendTemplate(to, templateName, languageCode string, params []string) (*SendTextResponse, error) {
-	msg := TemplateMessage{
-		To:               to,
-		Type:             "template",
-		MessagingProduct: "whatsapp",
-	}
-	msg.Template.Name = templateName
-	msg.Template.Language.Code = languageCode
+// TemplateCurrency is a localized amount; Amount1000 is the amount multiplied by 1000
+type TemplateCurrency struct {
+	FallbackValue string `json:"fallback_value"`
+	Code          string `json:"code"`
+	Amount1000    int64  `json:"amount_1000"`
+}
 
+// TemplateDateTime is a date shown as its fallback value
+type TemplateDateTime struct {
+	FallbackValue string `json:"fallback_value"`
+}
+
+// TemplateMedia references header media by uploaded media ID or public link
+type TemplateMedia struct {
+	ID       string `json:"id,omitempty"`
+	Link     string `json:"link,omitempty"`
+	Filename string `json:"filename,omitempty"` // Documents only
+}
+
+// SendTemplate sends a template message with positional text parameters in the body
+func (c *WhatsAppClient) SendTemplate(to, templateName, languageCode string, params []string) (*SendTextResponse, error) {
+	var components []TemplateComponent
 	if len(params) > 0 {
 		var parameters []TemplateParameter
 		for _, p := range params {
@@ -141,13 +167,26 @@ func (c *WhatsAppClient) SendTemplate(to, templateName, languageCode string, par
 				Text: p,
 			})
 		}
-		msg.Template.Components = []TemplateComponent{
+		components = []TemplateComponent{
 			{
 				Type:       "body",
 				Parameters: parameters,
 			},
 		}
 	}
+	return c.SendTemplateComponents(to, templateName, languageCode, components)
+}
+
+// SendTemplateComponents sends a template message with header, body and button parameters
+func (c *WhatsAppClient) SendTemplateComponents(to, templateName, languageCode string, components []TemplateComponent) (*SendTextResponse, error) {
+	msg := TemplateMessage{
+		To:               to,
+		Type:             "template",
+		MessagingProduct: "whatsapp",
+	}
+	msg.Template.Name = templateName
+	msg.Template.Language.Code = languageCode
+	msg.Template.Components = components
 
 	jsonData, err := json.Marshal(msg)
 	if err != nil {
@@ -221,3 +260,310 @@ func (c *WhatsAppClient) MarkAsRead(messageID string) error {
 
 	return nil
 }
+
+// MediaInfo represents media metadata returned by the Graph API
+type MediaInfo struct {
+	ID       string `json:"id"`
+	URL      string `json:"url"`
+	MimeType string `json:"mime_type"`
+	SHA256   string `json:"sha256"`
+	FileSize int64  `json:"file_size"`
+}
+
+// GetMedia retrieves the short-lived download URL and metadata for a media ID
+func (c *WhatsAppClient) GetMedia(mediaID string) (*MediaInfo, error) {
+	url := fmt.Sprintf("%s/%s", whatsappAPIURL, mediaID)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.accessToken)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API error: %s (status: %d)", string(body), resp.StatusCode)
+	}
+
+	var result MediaInfo
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &result, nil
+}
+
+// DownloadMedia downloads the binary content of a media ID.
+// The caller must close the returned reader.
+func (c *WhatsAppClient) DownloadMedia(mediaID string) (io.ReadCloser, *MediaInfo, error) {
+	info, err := c.GetMedia(mediaID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := http.NewRequest("GET", info.URL, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.accessToken)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, nil, fmt.Errorf("media download error: %s (status: %d)", string(body), resp.StatusCode)
+	}
+
+	return resp.Body, info, nil
+}
+
+// UploadMedia uploads a file to WhatsApp and returns its media ID
+func (c *WhatsAppClient) UploadMedia(filename, mimeType string, content io.Reader) (string, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	if err := writer.WriteField("messaging_product", "whatsapp"); err != nil {
+		return "", fmt.Errorf("failed to write form field: %w", err)
+	}
+	if err := writer.WriteField("type", mimeType); err != nil {
+		return "", fmt.Errorf("failed to write form field: %w", err)
+	}
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="%s"`, filename))
+	header.Set("Content-Type", mimeType)
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return "", fmt.Errorf("failed to create form file: %w", err)
+	}
+	if _, err := io.Copy(part, content); err != nil {
+		return "", fmt.Errorf("failed to write form file: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return "", fmt.Errorf("failed to close form: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/%s/media", whatsappAPIURL, c.phoneID)
+	req, err := http.NewRequest("POST", url, &buf)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+c.accessToken)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("API error: %s (status: %d)", string(body), resp.StatusCode)
+	}
+
+	var result struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return result.ID, nil
+}
+
+// MediaObject references uploaded media in an outbound message
+type MediaObject struct {
+	ID       string `json:"id"`
+	Caption  string `json:"caption,omitempty"`
+	Filename string `json:"filename,omitempty"`
+}
+
+// SendMedia sends an image, document, audio or video message using an uploaded media ID.
+// Captions are ignored by WhatsApp for audio; filenames only apply to documents.
+func (c *WhatsAppClient) SendMedia(to, mediaType, mediaID, caption, filename string) (*SendTextResponse, error) {
+	media := MediaObject{ID: mediaID}
+	if mediaType != "audio" {
+		media.Caption = caption
+	}
+	if mediaType == "document" {
+		media.Filename = filename
+	}
+
+	payload := map[string]interface{}{
+		"messaging_product": "whatsapp",
+		"to":                to,
+		"type":              mediaType,
+		mediaType:           media,
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/%s/messages", whatsappAPIURL, c.phoneID)
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.accessToken)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API error: %s (status: %d)", string(body), resp.StatusCode)
+	}
+
+	var result SendTextResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &result, nil
+}
+
+// MessageTemplate is a message template registered in the WhatsApp Business Account
+type MessageTemplate struct {
+	ID             string          `json:"id,omitempty"`
+	Name           string          `json:"name"`
+	Language       string          `json:"language"`
+	Category       string          `json:"category"`
+	Status         string          `json:"status,omitempty"` // APPROVED, PENDING, REJECTED, PAUSED, DISABLED, ...
+	RejectedReason string          `json:"rejected_reason,omitempty"`
+	Components     json.RawMessage `json:"components"`
+}
+
+// MessageTemplateList is one page of templates from the Graph API
+type MessageTemplateList struct {
+	Data   []MessageTemplate `json:"data"`
+	Paging struct {
+		Cursors struct {
+			After string `json:"after"`
+		} `json:"cursors"`
+		Next string `json:"next"`
+	} `json:"paging"`
+}
+
+// CreateTemplateResponse is returned when a template is submitted for review
+type CreateTemplateResponse struct {
+	ID       string `json:"id"`
+	Status   string `json:"status"`
+	Category string `json:"category"`
+}
+
+// ListTemplates returns a page of the business account's templates.
+// Pass the previous page's Paging.Cursors.After to continue; an empty Paging.Next marks the last page.
+func (c *WhatsAppClient) ListTemplates(after string) (*MessageTemplateList, error) {
+	query := url.Values{}
+	query.Set("fields", "id,name,language,category,status,rejected_reason,components")
+	query.Set("limit", "100")
+	if after != "" {
+		query.Set("after", after)
+	}
+
+	var result MessageTemplateList
+	endpoint := fmt.Sprintf("%s/%s/message_templates?%s", whatsappAPIURL, c.businessID, query.Encode())
+	if err := c.graphRequest("GET", endpoint, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// CreateTemplate submits a template for Meta's approval
+func (c *WhatsAppClient) CreateTemplate(template *MessageTemplate) (*CreateTemplateResponse, error) {
+	var result CreateTemplateResponse
+	endpoint := fmt.Sprintf("%s/%s/message_templates", whatsappAPIURL, c.businessID)
+	if err := c.graphRequest("POST", endpoint, template, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// DeleteTemplate deletes a template by name. With a template ID only that language
+// version is deleted, otherwise every language sharing the name is.
+func (c *WhatsAppClient) DeleteTemplate(name, templateID string) error {
+	query := url.Values{}
+	query.Set("name", name)
+	if templateID != "" {
+		query.Set("hsm_id", templateID)
+	}
+
+	endpoint := fmt.Sprintf("%s/%s/message_templates?%s", whatsappAPIURL, c.businessID, query.Encode())
+	return c.graphRequest("DELETE", endpoint, nil, nil)
+}
+
+// graphRequest sends a JSON request to the Graph API and decodes the response into out when non-nil
+func (c *WhatsAppClient) graphRequest(method, endpoint string, payload, out interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		jsonData, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("failed to marshal payload: %w", err)
+		}
+		body = bytes.NewBuffer(jsonData)
+	}
+
+	req, err := http.NewRequest(method, endpoint, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+c.accessToken)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("API error: %s (status: %d)", string(respBody), resp.StatusCode)
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("failed to parse response: %w", err)
+		}
+	}
+	return nil
+}